@@ -0,0 +1,124 @@
+// Package alphanum steers payloads into the alphanumeric mode, which packs 5.5 bits per
+// character rather than the 8 bits of the byte mode, but only holds the digits, the
+// uppercase letters and the characters " $%*+-./:". URLs are normalized into uppercase where
+// their meaning is kept, and binary data is Base45 encoded.
+package alphanum
+
+import (
+	"qr/qr-gen/base45"
+	"qr/qr-gen/qrerr"
+	"qr/qr-gen/versioner"
+)
+
+// Transform turns a payload into the text to encode
+type Transform int
+
+const (
+	// Transform_NONE keeps the payload as it is
+	Transform_NONE Transform = iota
+	// Transform_URL normalizes the URL with NormalizeURL
+	Transform_URL
+	// Transform_BASE45 Base45 encodes the payload
+	Transform_BASE45
+)
+
+var transformNames = map[Transform]string{
+	Transform_NONE:   "none",
+	Transform_URL:    "url",
+	Transform_BASE45: "base45",
+}
+
+func (t Transform) String() string {
+	if name, ok := transformNames[t]; ok {
+		return name
+	}
+	return "unknown"
+}
+
+// Encoded is the text of a payload after its transform
+type Encoded struct {
+	Text      string
+	Transform Transform
+	// Mode is the most compact mode holding the text
+	Mode versioner.QrMode
+	// Bits is the length of the text in its mode, mode and count indicators excluded
+	Bits int
+	// SavedBits is the number of bits saved over the payload encoded in byte mode, negative
+	// when the transform costs bits
+	SavedBits int
+}
+
+// Encode returns the payload as it is or as a normalized URL, whichever is encoded in the
+// fewest bits, the payload as it is on ties. Base45 is never selected: its 3 characters per
+// pair of bytes take 16.5 bits where the byte mode takes 16, so it only pays off where the
+// payload must be alphanumeric, such as next to other alphanumeric segments, and is applied
+// with EncodeWith.
+func Encode(data []byte) (*Encoded, error) {
+	if len(data) == 0 {
+		return nil, qrerr.ErrEmptyInput
+	}
+
+	var best *Encoded
+	for _, transform := range []Transform{Transform_NONE, Transform_URL} {
+		encoded, err := EncodeWith(data, transform)
+		if err != nil {
+			continue
+		}
+		if best == nil || encoded.Bits < best.Bits {
+			best = encoded
+		}
+	}
+	return best, nil
+}
+
+// EncodeWith transforms the payload with the given transform and reports the bits saved.
+// Transform_URL fails on payloads which are not URLs.
+func EncodeWith(data []byte, transform Transform) (*Encoded, error) {
+	if len(data) == 0 {
+		return nil, qrerr.ErrEmptyInput
+	}
+
+	var text string
+	switch transform {
+	case Transform_NONE:
+		text = string(data)
+	case Transform_URL:
+		url, err := NormalizeURL(string(data))
+		if err != nil {
+			return nil, err
+		}
+		text = url
+	case Transform_BASE45:
+		text = base45.Encode(data)
+	default:
+		return nil, qrerr.ErrInvalidInput
+	}
+
+	v := versioner.New()
+	mode, err := v.GetMode(text)
+	if err != nil {
+		// Binary data is held in byte mode, whatever its characters are
+		mode = versioner.QrByteMode
+	}
+
+	bits := v.GetDataLength(text, mode)
+	return &Encoded{
+		Text:      text,
+		Transform: transform,
+		Mode:      mode,
+		Bits:      bits,
+		SavedBits: v.GetDataLength(string(data), versioner.QrByteMode) - bits,
+	}, nil
+}
+
+// Decode returns the payload of the text transformed with the given transform. Normalized
+// URLs are equivalent to the original ones and are returned as they are.
+func Decode(text string, transform Transform) ([]byte, error) {
+	switch transform {
+	case Transform_NONE, Transform_URL:
+		return []byte(text), nil
+	case Transform_BASE45:
+		return base45.Decode(text)
+	}
+	return nil, qrerr.ErrInvalidInput
+}
@@ -0,0 +1,72 @@
+package alphanum
+
+import (
+	"qr/qr-gen/qrerr"
+	"qr/qr-gen/versioner"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestEncode(t *testing.T) {
+	assert := assert.New(t)
+
+	tests := []struct {
+		data      string
+		text      string
+		transform Transform
+		mode      versioner.QrMode
+		savedBits int
+	}{
+		// 19 bytes, 152 bits in byte mode against 105 bits in alphanumeric mode
+		{"https://example.com", "HTTPS://EXAMPLE.COM", Transform_URL, versioner.QrAlphanumericMode, 47},
+		// The lowercase path keeps the URL in byte mode
+		{"https://example.com/a", "https://example.com/a", Transform_NONE, versioner.QrByteMode, 0},
+		{"HELLO WORLD", "HELLO WORLD", Transform_NONE, versioner.QrAlphanumericMode, 27},
+		{"0123", "0123", Transform_NONE, versioner.QrNumericMode, 18},
+		{"\xff\x00", "\xff\x00", Transform_NONE, versioner.QrByteMode, 0},
+	}
+
+	for _, test := range tests {
+		encoded, err := Encode([]byte(test.data))
+		if assert.NoError(err) {
+			assert.Equal(test.text, encoded.Text, "texts should match")
+			assert.Equal(test.transform, encoded.Transform, "transforms should match")
+			assert.Equal(test.mode, encoded.Mode, "modes should match")
+			assert.Equal(test.savedBits, encoded.SavedBits, "saved bits should match")
+
+			decoded, err := Decode(encoded.Text, encoded.Transform)
+			assert.NoError(err)
+			if encoded.Transform == Transform_NONE {
+				assert.Equal(test.data, string(decoded), "decoded payloads should match")
+			}
+		}
+	}
+
+	_, err := Encode(nil)
+	assert.ErrorIs(err, qrerr.ErrEmptyInput)
+}
+
+func TestEncodeWithBase45(t *testing.T) {
+	assert := assert.New(t)
+
+	payload := []byte{0xd9, 0xd9, 0xf7, 0xa1, 0x00}
+	encoded, err := EncodeWith(payload, Transform_BASE45)
+	if assert.NoError(err) {
+		assert.Equal(versioner.QrAlphanumericMode, encoded.Mode, "modes should match")
+		// 8 characters of 5.5 bits against 5 bytes
+		assert.Equal(44, encoded.Bits, "bits should match")
+		assert.Equal(-4, encoded.SavedBits, "saved bits should match")
+
+		decoded, err := Decode(encoded.Text, Transform_BASE45)
+		assert.NoError(err)
+		assert.Equal(payload, decoded, "decoded payloads should match")
+	}
+
+	_, err = EncodeWith([]byte("HELLO"), Transform_URL)
+	assert.ErrorIs(err, qrerr.ErrInvalidInput)
+	_, err = EncodeWith([]byte("HELLO"), Transform(9))
+	assert.ErrorIs(err, qrerr.ErrInvalidInput)
+	_, err = Decode("HELLO", Transform(9))
+	assert.ErrorIs(err, qrerr.ErrInvalidInput)
+}
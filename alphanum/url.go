@@ -0,0 +1,86 @@
+package alphanum
+
+import (
+	"fmt"
+	"qr/qr-gen/qrerr"
+	"strings"
+)
+
+// NormalizeURL upper-cases the ASCII letters of the parts of the URL which are
+// case-insensitive: the scheme, the host and the hexadecimal digits of the percent-encoded
+// octets. The user information, the path, the query and the fragment are kept as they are,
+// so that the URL still points to the same resource. The result only fits the alphanumeric
+// mode when these parts hold no lowercase letter nor other characters out of its character
+// set.
+func NormalizeURL(s string) (string, error) {
+	schemeEnd := strings.Index(s, "://")
+	if schemeEnd <= 0 || !isScheme(s[:schemeEnd]) {
+		return "", fmt.Errorf("%w: %q is not an absolute URL", qrerr.ErrInvalidInput, s)
+	}
+
+	authorityStart := schemeEnd + len("://")
+	authorityEnd := len(s)
+	if i := strings.IndexAny(s[authorityStart:], "/?#"); i >= 0 {
+		authorityEnd = authorityStart + i
+	}
+
+	// The user information precedes the host and is case-sensitive
+	hostStart := authorityStart
+	if i := strings.LastIndexByte(s[authorityStart:authorityEnd], '@'); i >= 0 {
+		hostStart = authorityStart + i + 1
+	}
+
+	var b strings.Builder
+	b.Grow(len(s))
+	b.WriteString(upperASCII(s[:authorityStart]))
+	b.WriteString(upperPercentEncoding(s[authorityStart:hostStart]))
+	b.WriteString(upperASCII(s[hostStart:authorityEnd]))
+	b.WriteString(upperPercentEncoding(s[authorityEnd:]))
+	return b.String(), nil
+}
+
+// A scheme is a letter followed by letters, digits, "+", "-" or "."
+func isScheme(s string) bool {
+	for i, r := range s {
+		isLetter := (r >= 'a' && r <= 'z') || (r >= 'A' && r <= 'Z')
+		if !isLetter && (i == 0 || !((r >= '0' && r <= '9') || r == '+' || r == '-' || r == '.')) {
+			return false
+		}
+	}
+	return true
+}
+
+// Upper-cases the ASCII letters only. Unicode case mappings would change the host of the
+// internationalized domain names, such as "ſ" which is upper-cased into "S".
+func upperASCII(s string) string {
+	b := []byte(s)
+	for i, c := range b {
+		if c >= 'a' && c <= 'z' {
+			b[i] = c - 'a' + 'A'
+		}
+	}
+	return string(b)
+}
+
+// Upper-cases the two hexadecimal digits following every "%"
+func upperPercentEncoding(s string) string {
+	b := []byte(s)
+	for i := 0; i+2 < len(b); i++ {
+		if b[i] == '%' && isHexDigit(b[i+1]) && isHexDigit(b[i+2]) {
+			b[i+1], b[i+2] = upperHexDigit(b[i+1]), upperHexDigit(b[i+2])
+			i += 2
+		}
+	}
+	return string(b)
+}
+
+func isHexDigit(c byte) bool {
+	return (c >= '0' && c <= '9') || (c >= 'a' && c <= 'f') || (c >= 'A' && c <= 'F')
+}
+
+func upperHexDigit(c byte) byte {
+	if c >= 'a' && c <= 'f' {
+		return c - 'a' + 'A'
+	}
+	return c
+}
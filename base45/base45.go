@@ -0,0 +1,80 @@
+// Package base45 implements the Base45 encoding of RFC 9285, whose alphabet is the character
+// set of the alphanumeric mode, so that binary data is encoded in 5.5 bits per character
+// rather than the 8 bits of the byte mode.
+package base45
+
+import (
+	"fmt"
+	"qr/qr-gen/qrerr"
+	"qr/qr-gen/util"
+	"strings"
+)
+
+const alphabet = util.QrAlphanumericCharacters
+
+const base = len(alphabet)
+
+// EncodedLen returns the length of the encoding of n bytes: 3 characters per pair of bytes
+// and 2 for a trailing byte
+func EncodedLen(n int) int {
+	return n/2*3 + n%2*2
+}
+
+// Encode encodes every pair of bytes as 3 characters, the least significant first, and a
+// trailing byte as 2 characters
+func Encode(data []byte) string {
+	var b strings.Builder
+	b.Grow(EncodedLen(len(data)))
+
+	for i := 0; i+1 < len(data); i += 2 {
+		n := int(data[i])<<8 | int(data[i+1])
+		b.WriteByte(alphabet[n%base])
+		b.WriteByte(alphabet[n/base%base])
+		b.WriteByte(alphabet[n/(base*base)])
+	}
+
+	if len(data)%2 == 1 {
+		n := int(data[len(data)-1])
+		b.WriteByte(alphabet[n%base])
+		b.WriteByte(alphabet[n/base])
+	}
+
+	return b.String()
+}
+
+// Decode decodes the Base45 text. Characters out of the alphabet, a length which is not a
+// valid encoding length and groups overflowing their bytes are rejected.
+func Decode(s string) ([]byte, error) {
+	if len(s)%3 == 1 {
+		return nil, fmt.Errorf("%w: invalid Base45 length %d", qrerr.ErrInvalidInput, len(s))
+	}
+
+	data := make([]byte, 0, len(s)/3*2+len(s)%3/2)
+	for i := 0; i < len(s); i += 3 {
+		group := s[i:util.Min(i+3, len(s))]
+
+		n, weight := 0, 1
+		for j := 0; j < len(group); j++ {
+			value := strings.IndexByte(alphabet, group[j])
+			if value < 0 {
+				return nil, &qrerr.ErrInvalidCharacter{Pos: i + j, Rune: rune(group[j]), Mode: "Base45"}
+			}
+			n += value * weight
+			weight *= base
+		}
+
+		if len(group) == 3 {
+			if n > 0xffff {
+				return nil, fmt.Errorf("%w: invalid Base45 group %q at position %d", qrerr.ErrInvalidInput, group, i)
+			}
+			data = append(data, byte(n>>8), byte(n))
+		} else {
+			if n > 0xff {
+				return nil, fmt.Errorf("%w: invalid Base45 group %q at position %d", qrerr.ErrInvalidInput, group, i)
+			}
+			data = append(data, byte(n))
+		}
+	}
+
+	return data, nil
+}
@@ -0,0 +1,66 @@
+package base45
+
+import (
+	"bytes"
+	"qr/qr-gen/qrerr"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// The examples of RFC 9285
+func TestEncode(t *testing.T) {
+	assert := assert.New(t)
+
+	tests := []struct {
+		data    string
+		encoded string
+	}{
+		{"AB", "BB8"},
+		{"Hello!!", "%69 VD92EX0"},
+		{"base-45", "UJCLQE7W581"},
+		{"ietf!", "QED8WEX0"},
+		{"", ""},
+	}
+
+	for _, test := range tests {
+		assert.Equal(test.encoded, Encode([]byte(test.data)), "encodings should match")
+		assert.Equal(len(test.encoded), EncodedLen(len(test.data)), "encoded lengths should match")
+
+		decoded, err := Decode(test.encoded)
+		assert.NoError(err)
+		assert.Equal(test.data, string(decoded), "decoded data should match")
+	}
+}
+
+func TestDecodeErrors(t *testing.T) {
+	assert := assert.New(t)
+
+	_, err := Decode("GGW")
+	assert.ErrorIs(err, qrerr.ErrInvalidInput, "groups above 65535 should be rejected")
+
+	_, err = Decode("BB8A")
+	assert.ErrorIs(err, qrerr.ErrInvalidInput, "lengths of 1 modulo 3 should be rejected")
+
+	_, err = Decode("BB8:Z")
+	assert.ErrorIs(err, qrerr.ErrInvalidInput, "trailing pairs above 255 should be rejected")
+
+	_, err = Decode("bb8")
+	var invalid *qrerr.ErrInvalidCharacter
+	if assert.ErrorAs(err, &invalid) {
+		assert.Equal(qrerr.ErrInvalidCharacter{Pos: 0, Rune: 'b', Mode: "Base45"}, *invalid, "error details should match")
+	}
+}
+
+func FuzzRoundTrip(f *testing.F) {
+	f.Add([]byte("Hello!!"))
+	f.Add([]byte{0xff, 0xff, 0x00})
+
+	f.Fuzz(func(t *testing.T, data []byte) {
+		encoded := Encode(data)
+		decoded, err := Decode(encoded)
+		if err != nil || !bytes.Equal(data, decoded) {
+			t.Fatalf("%x should be decoded back from %q: %v", data, encoded, err)
+		}
+	})
+}
@@ -0,0 +1,125 @@
+package bitbuf
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Buffer is a sequence of bits packed in bytes, the first bit being the most significant
+// bit of the first byte. It replaces the strings of '0' and '1' characters passed between
+// the stages of the pipeline.
+type Buffer struct {
+	data   []byte
+	length int
+}
+
+func New() *Buffer {
+	return &Buffer{}
+}
+
+// FromBytes returns a buffer holding the bits of the given bytes
+func FromBytes(b []byte) *Buffer {
+	data := make([]byte, len(b))
+	copy(data, b)
+	return &Buffer{data: data, length: len(b) * 8}
+}
+
+// FromString parses a string of '0' and '1' characters
+func FromString(s string) (*Buffer, error) {
+	buffer := &Buffer{data: make([]byte, 0, (len(s)+7)/8)}
+
+	if err := buffer.AppendString(s); err != nil {
+		return nil, err
+	}
+	return buffer, nil
+}
+
+// Len returns the number of bits in the buffer
+func (b *Buffer) Len() int {
+	return b.length
+}
+
+// AppendBit appends a single bit, any non zero value being a 1
+func (b *Buffer) AppendBit(bit int) {
+	if b.length%8 == 0 {
+		b.data = append(b.data, 0)
+	}
+
+	if bit != 0 {
+		b.data[b.length/8] |= 0x80 >> (b.length % 8)
+	}
+	b.length += 1
+}
+
+// AppendBits appends the n least significant bits of the value, most significant first
+func (b *Buffer) AppendBits(value int, n int) {
+	for i := n - 1; i >= 0; i-- {
+		b.AppendBit((value >> i) & 1)
+	}
+}
+
+// AppendString appends the bits of a string of '0' and '1' characters. Nothing is
+// appended when the string holds any other character.
+func (b *Buffer) AppendString(s string) error {
+	for i := 0; i < len(s); i++ {
+		if s[i] != '0' && s[i] != '1' {
+			return fmt.Errorf("Invalid bit %q at position %d", s[i], i)
+		}
+	}
+
+	for i := 0; i < len(s); i++ {
+		b.AppendBit(int(s[i] - '0'))
+	}
+	return nil
+}
+
+// AppendBytes appends the eight bits of every byte
+func (b *Buffer) AppendBytes(p []byte) {
+	if b.length%8 == 0 {
+		b.data = append(b.data, p...)
+		b.length += len(p) * 8
+		return
+	}
+
+	for _, v := range p {
+		b.AppendBits(int(v), 8)
+	}
+}
+
+// AppendBuffer appends all the bits of another buffer
+func (b *Buffer) AppendBuffer(other *Buffer) {
+	b.AppendBytes(other.data[:other.length/8])
+
+	for i := other.length / 8 * 8; i < other.length; i++ {
+		b.AppendBit(other.Bit(i))
+	}
+}
+
+// Bit returns the bit at the given position as 0 or 1
+func (b *Buffer) Bit(i int) int {
+	return int(b.data[i/8]>>(7-i%8)) & 1
+}
+
+// Codeword returns the i-th group of eight bits. The bits past the end of an
+// incomplete last codeword are zeros.
+func (b *Buffer) Codeword(i int) byte {
+	return b.data[i]
+}
+
+// Bytes returns the bits grouped in codewords, the last one being padded with zeros.
+// The slice shares the storage of the buffer.
+func (b *Buffer) Bytes() []byte {
+	return b.data
+}
+
+// String returns the bits as a string of '0' and '1' characters
+func (b *Buffer) String() string {
+	var builder strings.Builder
+	builder.Grow(b.length)
+
+	for i := 0; i < b.length; i++ {
+		builder.WriteByte(byte('0' + b.Bit(i)))
+	}
+
+	return builder.String()
+}
@@ -0,0 +1,93 @@
+package bitbuf
+
+import (
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestAppendBits(t *testing.T) {
+	assert := assert.New(t)
+	b := New()
+
+	b.AppendBits(0b0010, 4)
+	b.AppendBits(11, 9)
+	b.AppendBit(1)
+	assert.Equal(14, b.Len(), "lengths should match")
+	assert.Equal("00100000010111", b.String(), "bits should match")
+	assert.Equal([]byte{0b00100000, 0b01011100}, b.Bytes(), "codewords should be padded with zeros")
+
+	b.AppendBytes([]byte{0xff, 0x01})
+	assert.Equal(30, b.Len(), "lengths should match")
+	assert.Equal("00100000010111"+"11111111"+"00000001", b.String(), "bits should match")
+	assert.Equal(byte(0b01011111), b.Codeword(1), "codewords should match")
+	assert.Equal(1, b.Bit(29), "bits should match")
+}
+
+func TestAppendBuffer(t *testing.T) {
+	assert := assert.New(t)
+
+	for _, prefix := range []string{"", "1", "101", "10110011"} {
+		for _, suffix := range []string{"", "0", "011", "1111000011"} {
+			b, _ := FromString(prefix)
+			other, _ := FromString(suffix)
+			b.AppendBuffer(other)
+			assert.Equal(prefix+suffix, b.String(), "bits should match")
+		}
+	}
+}
+
+func TestFromBytesAndString(t *testing.T) {
+	assert := assert.New(t)
+
+	b := FromBytes([]byte{0x40, 0x0a})
+	assert.Equal("0100000000001010", b.String(), "bits should match")
+
+	b, err := FromString("0100000000001")
+	assert.NoError(err)
+	assert.Equal([]byte{0x40, 0x08}, b.Bytes(), "codewords should match")
+
+	_, err = FromString("0102")
+	assert.EqualError(err, "Invalid bit '2' at position 3", "error messages should match")
+}
+
+func BenchmarkAppendBits(b *testing.B) {
+	for n := 0; n < b.N; n++ {
+		buffer := New()
+		for i := 0; i < 1000; i++ {
+			buffer.AppendBits(i, 10)
+		}
+	}
+}
+
+// Appending the same bits to a string, as the pipeline did before the buffer was introduced
+func BenchmarkAppendString(b *testing.B) {
+	for n := 0; n < b.N; n++ {
+		s := ""
+		for i := 0; i < 1000; i++ {
+			bin := strconv.FormatInt(int64(i), 2)
+			s += strings.Repeat("0", 10-len(bin)) + bin
+		}
+	}
+}
+
+func FuzzFromString(f *testing.F) {
+	f.Add("0100000000001")
+	f.Add("0102")
+
+	f.Fuzz(func(t *testing.T, s string) {
+		b, err := FromString(s)
+		if strings.Trim(s, "01") != "" {
+			if err == nil {
+				t.Fatalf("%q should be rejected", s)
+			}
+			return
+		}
+
+		if err != nil || b.String() != s || b.Len() != len(s) || len(b.Bytes()) != (len(s)+7)/8 {
+			t.Fatalf("%q should be parsed back, got %v", s, err)
+		}
+	})
+}
@@ -0,0 +1,371 @@
+// Command qr-gen generates QR codes and answers capacity questions from the command line.
+//
+//	qr-gen generate [-level M] [-o qr.png] [-quiet-zone 4] [-dpi 300 -width-mm 30 [-min-module-mm 0.5]] [-mask penalty|0-7|logo:r,c,w,h|contrast:r,c,w,h] [-masks masks.png] [-compress [-base45] | -alphanumeric] <input> | -file payload.bin
+//	qr-gen capacity [-level Q] [-size 25 | -version 2] [-segment mode:data]... [-table] [input]
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"qr/qr-gen/alphanum"
+	"qr/qr-gen/compress"
+	"qr/qr-gen/generator"
+	"qr/qr-gen/img"
+	"qr/qr-gen/matrix"
+	"qr/qr-gen/moduler"
+	"qr/qr-gen/util"
+	"qr/qr-gen/versioner"
+	"strconv"
+	"strings"
+)
+
+const usage = `Usage:
+  qr-gen generate [-level M] [-o qr.png] [-quiet-zone 4] [-dpi 300 -width-mm 30 [-min-module-mm 0.5]] [-mask penalty|0-7|logo:r,c,w,h|contrast:r,c,w,h] [-masks masks.png] [-compress [-base45] | -alphanumeric] <input> | -file payload.bin
+  qr-gen capacity [-level Q] [-size 25 | -version 2] [-segment mode:data]... [-table] [input]`
+
+var errUsage = errors.New(usage)
+
+// Pixels per module of the mask diagnostics image
+const maskDiagnosticsScale = 4
+
+func main() {
+	if err := run(os.Args[1:], os.Stdout); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+}
+
+func run(args []string, stdout io.Writer) error {
+	if len(args) == 0 {
+		return errUsage
+	}
+
+	switch args[0] {
+	case "generate":
+		return runGenerate(args[1:], stdout)
+	case "capacity":
+		return runCapacity(args[1:], stdout)
+	}
+
+	return fmt.Errorf("Unknown command %q\n%w", args[0], errUsage)
+}
+
+func runGenerate(args []string, stdout io.Writer) error {
+	flags := flag.NewFlagSet("generate", flag.ContinueOnError)
+	level := flags.String("level", "M", "error correction level, L, M, Q or H")
+	output := flags.String("o", "qr.png", "path of the PNG image")
+	quietZone := flags.Int("quiet-zone", moduler.DefaultQuietZone, "width of the quiet zone in modules")
+	dpi := flags.Int("dpi", 0, "resolution of the printer, the image is rendered one pixel per module without it")
+	widthMM := flags.Float64("width-mm", 0, "printed width of the image in millimetres, quiet zone included")
+	minModuleMM := flags.Float64("min-module-mm", 0, "smallest printed module size in millimetres")
+	file := flags.String("file", "", "path of a binary payload encoded in byte mode, - for the standard input")
+	mask := flags.String("mask", "penalty", "mask selection: penalty, a mask reference from 0 to 7, logo:row,col,width,height or contrast:row,col,width,height")
+	masks := flags.String("masks", "", "path of a PNG image of the symbol with every mask and their penalty scores")
+	compressed := flags.Bool("compress", false, "compress the input when it fits a smaller symbol")
+	base45 := flags.Bool("base45", false, "also try the compressed input Base45 encoded in alphanumeric mode, implies -compress")
+	alphanumeric := flags.Bool("alphanumeric", false, "upper-case the scheme and host of a URL input when it fits the alphanumeric mode")
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+
+	if (*file == "") != (flags.NArg() == 1) || flags.NArg() > 1 {
+		return errUsage
+	}
+
+	*compressed = *compressed || *base45
+
+	if *file != "" && *masks != "" {
+		return errors.New("Mask diagnostics of binary payloads are not supported")
+	}
+	if *compressed && *masks != "" {
+		return errors.New("Mask diagnostics of compressed payloads are not supported")
+	}
+	if *alphanumeric && (*compressed || *file != "") {
+		return errors.New("Alphanumeric normalization only applies to uncompressed text inputs")
+	}
+
+	lvl, err := parseLevel(*level)
+	if err != nil {
+		return err
+	}
+
+	input := flags.Arg(0)
+	if *alphanumeric {
+		encoded, err := alphanum.Encode([]byte(input))
+		if err != nil {
+			return err
+		}
+
+		input = encoded.Text
+		fmt.Fprintf(stdout, "Encoded as %q in %s mode, %d bits saved over byte mode\n", input, encoded.Mode, encoded.SavedBits)
+	}
+
+	selector, err := parseMaskSelector(*mask)
+	if err != nil {
+		return err
+	}
+
+	options := moduler.DefaultOptions()
+	options.QuietZone = *quietZone
+	options.Mask.Selector = selector
+	var matrix *matrix.Matrix[util.Module]
+	if *compressed {
+		matrix, err = generateCompressed(*file, input, lvl, options, compress.Options{Base45: *base45}, stdout)
+	} else if *file != "" {
+		matrix, err = generateFile(*file, lvl, options, stdout)
+	} else {
+		matrix, err = generator.NewWithOptions(options).Generate(input, lvl)
+	}
+	if err != nil {
+		return err
+	}
+
+	if *dpi == 0 {
+		if _, err := img.New().CreateImage(*output, matrix); err != nil {
+			return err
+		}
+	} else {
+		sizing, err := img.ComputeSizing(matrix.Width(), *quietZone, img.PrintSpec{WidthMM: *widthMM, DPI: *dpi, MinModuleMM: *minModuleMM})
+		if err != nil {
+			return err
+		}
+
+		if _, err := img.New().CreatePrintImage(*output, matrix, sizing); err != nil {
+			return err
+		}
+
+		fmt.Fprintf(stdout, "Printed at %d DPI: %d pixels per module, %.3fmm modules, %.2fmm wide\n", sizing.DPI, sizing.Scale, sizing.ModuleMM, sizing.WidthMM)
+		for _, warning := range sizing.Warnings {
+			fmt.Fprintf(stdout, "Warning: %s\n", warning)
+		}
+	}
+
+	fmt.Fprintf(stdout, "Wrote %s, %dx%d modules\n", *output, matrix.Width(), matrix.Height())
+
+	if *masks != "" {
+		return writeMaskDiagnostics(*masks, input, lvl, options, stdout)
+	}
+	return nil
+}
+
+// Generates the symbol of a binary payload read from a file or the standard input. Payloads
+// longer than the largest symbol are rejected rather than cut.
+func generateFile(path string, lvl versioner.QrEcLevel, options moduler.Options, stdout io.Writer) (*matrix.Matrix[util.Module], error) {
+	data, err := readPayload(path)
+	if err != nil {
+		return nil, err
+	}
+
+	symbol, err := generator.NewWithOptions(options).GenerateBytes(data, lvl)
+	if err != nil {
+		return nil, err
+	}
+
+	fmt.Fprintf(stdout, "Encoded %d bytes in byte mode\n", len(data))
+	return symbol, nil
+}
+
+// Generates the symbol of the input or of the payload of the file, compressed when it fits
+// a smaller symbol
+func generateCompressed(path, input string, lvl versioner.QrEcLevel, options moduler.Options, compressOptions compress.Options, stdout io.Writer) (*matrix.Matrix[util.Module], error) {
+	data := []byte(input)
+	if path != "" {
+		var err error
+		if data, err = readPayload(path); err != nil {
+			return nil, err
+		}
+	}
+
+	symbol, compressed, err := generator.NewWithOptions(options).GenerateCompressed(data, lvl, compressOptions)
+	if err != nil {
+		return nil, err
+	}
+
+	fmt.Fprintf(stdout, "Encoded %d bytes as %d bytes of %s data in %s mode, version %d\n", len(data), len(compressed.Data), compressed.Variant, compressed.Mode, compressed.Version)
+	return symbol, nil
+}
+
+// Reads a payload from a file, or from the standard input for -
+func readPayload(path string) ([]byte, error) {
+	var r io.Reader = os.Stdin
+	if path != "-" {
+		f, err := os.Open(path)
+		if err != nil {
+			return nil, fmt.Errorf("Error on opening the payload: %w", err)
+		}
+		defer f.Close()
+		r = f
+	}
+
+	data, err := io.ReadAll(r)
+	if err != nil {
+		return nil, fmt.Errorf("Error on reading the payload: %w", err)
+	}
+	return data, nil
+}
+
+// Writes the image of the mask candidates and prints their penalty scores
+func writeMaskDiagnostics(path, input string, lvl versioner.QrEcLevel, options moduler.Options, stdout io.Writer) error {
+	diagnostics, err := generator.NewWithOptions(options).DiagnoseMasks(input, lvl)
+	if err != nil {
+		return err
+	}
+
+	if _, err := img.New().CreateMaskDiagnosticsImage(path, diagnostics, maskDiagnosticsScale); err != nil {
+		return err
+	}
+
+	if err := diagnostics.WriteTable(stdout); err != nil {
+		return err
+	}
+
+	fmt.Fprintf(stdout, "Wrote %s\n", path)
+	return nil
+}
+
+func runCapacity(args []string, stdout io.Writer) error {
+	var segments segmentsFlag
+
+	flags := flag.NewFlagSet("capacity", flag.ContinueOnError)
+	level := flags.String("level", "", "error correction level, L, M, Q or H, all of them by default")
+	size := flags.Int("size", 0, "size of the symbol in modules, quiet zone excluded")
+	version := flags.Int("version", 0, "version of the symbol")
+	table := flags.Bool("table", false, "dump the capacity of every version and level")
+	flags.Var(&segments, "segment", "segment of the input as mode:data, repeatable")
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+
+	if flags.NArg() > 1 || (flags.NArg() == 1 && len(segments) > 0) || (*size != 0 && *version != 0) {
+		return errUsage
+	}
+
+	levels := versioner.QrEcLevels
+	if *level != "" {
+		lvl, err := parseLevel(*level)
+		if err != nil {
+			return err
+		}
+		levels = []versioner.QrEcLevel{lvl}
+	}
+
+	v := versioner.New()
+	var capacity *versioner.Capacity
+	var err error
+	if flags.NArg() == 1 {
+		capacity, err = v.GetCapacity(flags.Arg(0))
+	} else {
+		capacity, err = v.GetSegmentsCapacity(segments)
+	}
+	if err != nil {
+		return err
+	}
+
+	if *table {
+		return capacity.WriteTable(stdout)
+	}
+
+	for _, segment := range capacity.Segments {
+		fmt.Fprintf(stdout, "Segment: %d characters in %s mode\n", len(segment.Data), segment.Mode)
+	}
+	fmt.Fprintf(stdout, "Bits: %d (versions 1-9), %d (versions 10-26), %d (versions 27-40)\n",
+		capacity.BitLength(1), capacity.BitLength(10), capacity.BitLength(27))
+
+	if *size != 0 {
+		symbolVersion, err := versioner.GetVersionForSize(*size)
+		if err != nil {
+			return err
+		}
+		*version = int(symbolVersion)
+	}
+
+	if *version == 0 {
+		for _, lvl := range levels {
+			if minVersion := capacity.MinVersion(lvl); minVersion == -1 {
+				fmt.Fprintf(stdout, "Level %c: too long for any version\n", lvl)
+			} else {
+				fmt.Fprintf(stdout, "Level %c: version %d (%dx%d)\n", lvl, minVersion, minVersion.Size(), minVersion.Size())
+			}
+		}
+		return nil
+	}
+
+	symbolVersion := versioner.QrVersion(*version)
+	if symbolVersion < versioner.MinQrVersion || symbolVersion > versioner.MaxQrVersion {
+		return fmt.Errorf("Invalid version %d", *version)
+	}
+
+	for _, lvl := range levels {
+		fmt.Fprintf(stdout, "%d-%c (%dx%d): ", symbolVersion, lvl, symbolVersion.Size(), symbolVersion.Size())
+		if !capacity.Fits(symbolVersion, lvl) {
+			fmt.Fprintf(stdout, "does not fit, %d bits missing\n", -capacity.RemainingBits(symbolVersion, lvl))
+			continue
+		}
+
+		fmt.Fprintf(stdout, "fits, %d bits left for %d numeric, %d alphanumeric or %d byte characters\n",
+			capacity.RemainingBits(symbolVersion, lvl),
+			capacity.RemainingCharacters(symbolVersion, lvl, versioner.QrNumericMode),
+			capacity.RemainingCharacters(symbolVersion, lvl, versioner.QrAlphanumericMode),
+			capacity.RemainingCharacters(symbolVersion, lvl, versioner.QrByteMode))
+	}
+
+	return nil
+}
+
+func parseLevel(s string) (versioner.QrEcLevel, error) {
+	for _, lvl := range versioner.QrEcLevels {
+		if strings.EqualFold(s, string(lvl)) {
+			return lvl, nil
+		}
+	}
+	return 0, fmt.Errorf("Invalid error correction level %q", s)
+}
+
+// parseMaskSelector parses the -mask flag. The regions are given in modules, quiet zone
+// excluded, and the contrast is measured on a ring of a single module around the region.
+func parseMaskSelector(s string) (moduler.MaskSelector, error) {
+	if s == "penalty" {
+		return moduler.NewPenaltySelector(), nil
+	}
+
+	if mask, err := strconv.Atoi(s); err == nil {
+		if mask < 0 || mask > 7 {
+			return nil, fmt.Errorf("Invalid mask reference %d", mask)
+		}
+		return moduler.NewFixedMaskSelector(mask), nil
+	}
+
+	kind, coords, _ := strings.Cut(s, ":")
+	var region moduler.Region
+	if _, err := fmt.Sscanf(coords, "%d,%d,%d,%d", &region.Row, &region.Col, &region.Width, &region.Height); err != nil {
+		return nil, fmt.Errorf("Invalid mask selection %q", s)
+	}
+
+	switch kind {
+	case "logo":
+		return moduler.NewLogoAreaSelector(region), nil
+	case "contrast":
+		return moduler.NewContrastSelector(region, 1), nil
+	}
+	return nil, fmt.Errorf("Invalid mask selection %q", s)
+}
+
+// segmentsFlag collects the repeated -segment flags
+type segmentsFlag []versioner.Segment
+
+func (f *segmentsFlag) String() string {
+	return fmt.Sprint(*f)
+}
+
+func (f *segmentsFlag) Set(value string) error {
+	mode, data, ok := strings.Cut(value, ":")
+	if !ok {
+		return fmt.Errorf("Invalid segment %q, expected mode:data", value)
+	}
+
+	*f = append(*f, versioner.Segment{Data: data, Mode: versioner.QrMode(mode)})
+	return nil
+}
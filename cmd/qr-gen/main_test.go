@@ -0,0 +1,113 @@
+package main
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"qr/qr-gen/qrerr"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCapacityCommand(t *testing.T) {
+	assert := assert.New(t)
+
+	var out bytes.Buffer
+	assert.NoError(run([]string{"capacity", "-size", "25", "-level", "q", "HELLO WORLD"}, &out))
+	assert.Equal("Segment: 11 characters in alphanumeric mode\n"+
+		"Bits: 74 (versions 1-9), 76 (versions 10-26), 78 (versions 27-40)\n"+
+		"2-Q (25x25): fits, 102 bits left for 26 numeric, 16 alphanumeric or 11 byte characters\n", out.String(), "outputs should match")
+
+	out.Reset()
+	assert.NoError(run([]string{"capacity", "-segment", "numeric:0123", "-segment", "byte:été"}, &out))
+	assert.Contains(out.String(), "Level L: version 1 (21x21)\n", "minimum versions should be listed")
+	assert.Contains(out.String(), "Level H: version 2 (25x25)\n", "minimum versions should be listed")
+
+	out.Reset()
+	assert.NoError(run([]string{"capacity", "-version", "1", "-level", "H", "https://www.qrcode.com/"}, &out))
+	assert.Contains(out.String(), "1-H (21x21): does not fit, 124 bits missing\n", "missing bits should be reported")
+
+	out.Reset()
+	assert.NoError(run([]string{"capacity", "-table", "1234"}, &out))
+	assert.Equal(1+40*4, bytes.Count(out.Bytes(), []byte("\n")), "every version and level should have a line")
+
+	assert.ErrorIs(run([]string{"capacity", "-segment", "numeric:12a"}, &out), qrerr.ErrInvalidInput)
+	assert.ErrorIs(run([]string{"capacity", "-size", "26", "1"}, &out), qrerr.ErrInvalidVersion)
+	assert.Error(run([]string{"capacity", "-level", "X", "1"}, &out))
+	assert.ErrorIs(run([]string{"capacity", "-size", "25", "-version", "3", "1"}, &out), errUsage)
+}
+
+func TestGenerateCommand(t *testing.T) {
+	assert := assert.New(t)
+	path := filepath.Join(t.TempDir(), "qr.png")
+
+	var out bytes.Buffer
+	assert.NoError(run([]string{"generate", "-level", "Q", "-o", path, "HELLO WORLD"}, &out))
+	assert.Equal("Wrote "+path+", 29x29 modules\n", out.String(), "outputs should match")
+
+	_, err := os.Stat(path)
+	assert.NoError(err, "image should be written")
+
+	out.Reset()
+	assert.NoError(run([]string{"generate", "-dpi", "300", "-width-mm", "5", "-min-module-mm", "0.25", "-o", path, "HELLO WORLD"}, &out))
+	assert.Contains(out.String(), "Printed at 300 DPI: 2 pixels per module, 0.169mm modules, 4.91mm wide\n", "sizing should be reported")
+	assert.Contains(out.String(), "Warning: modules are smaller than the minimum module size\n", "warnings should be reported")
+
+	out.Reset()
+	assert.NoError(run([]string{"generate", "-quiet-zone", "1", "-dpi", "300", "-width-mm", "30", "-o", path, "HELLO WORLD"}, &out))
+	assert.Contains(out.String(), "Wrote "+path+", 23x23 modules\n", "quiet zone should be applied")
+	assert.Contains(out.String(), "Warning: quiet zone is narrower than 4 modules\n", "warnings should be reported")
+
+	out.Reset()
+	masks := filepath.Join(t.TempDir(), "masks.png")
+	assert.NoError(run([]string{"generate", "-o", path, "-masks", masks, "HELLO WORLD"}, &out))
+	assert.Contains(out.String(), "mask  adjacent  blocks  finder-like  balance  total", "penalty scores should be reported")
+	assert.Contains(out.String(), "selected: ", "selected mask should be reported")
+	assert.Contains(out.String(), "Wrote "+masks+"\n", "outputs should match")
+	_, err = os.Stat(masks)
+	assert.NoError(err, "mask diagnostics image should be written")
+
+	for _, mask := range []string{"3", "logo:10,10,9,9", "contrast:10,10,9,9"} {
+		out.Reset()
+		assert.NoError(run([]string{"generate", "-o", path, "-mask", mask, "-masks", masks, "HELLO WORLD"}, &out))
+		assert.Contains(out.String(), "selected: chosen by the mask selector\n", "mask selector should be used")
+	}
+	assert.Contains(out.String(), "Mask ", "selected mask should be reported")
+	out.Reset()
+	assert.NoError(run([]string{"generate", "-o", path, "-mask", "5", "-masks", masks, "HELLO WORLD"}, &out))
+	assert.Contains(out.String(), "Mask 5 selected", "fixed mask should be selected")
+	assert.EqualError(run([]string{"generate", "-mask", "8", "HELLO WORLD"}, &out), "Invalid mask reference 8", "error messages should match")
+	assert.EqualError(run([]string{"generate", "-mask", "logo:1,2", "HELLO WORLD"}, &out), `Invalid mask selection "logo:1,2"`, "error messages should match")
+
+	payload := filepath.Join(t.TempDir(), "payload.bin")
+	assert.NoError(os.WriteFile(payload, []byte{0x00, 0xff, 0x10, 0x80}, 0644))
+	out.Reset()
+	assert.NoError(run([]string{"generate", "-o", path, "-file", payload}, &out))
+	assert.Equal("Encoded 4 bytes in byte mode\nWrote "+path+", 29x29 modules\n", out.String(), "outputs should match")
+	assert.ErrorIs(run([]string{"generate", "-o", path, "-file", payload, "HELLO WORLD"}, &out), errUsage)
+	assert.Error(run([]string{"generate", "-o", path, "-file", payload, "-masks", masks}, &out))
+	assert.Error(run([]string{"generate", "-o", path, "-file", filepath.Join(t.TempDir(), "missing.bin")}, &out))
+
+	text := filepath.Join(t.TempDir(), "text.txt")
+	assert.NoError(os.WriteFile(text, []byte(strings.Repeat("https://example.com/a?b=c ", 40)), 0644))
+	out.Reset()
+	assert.NoError(run([]string{"generate", "-o", path, "-compress", "-file", text}, &out))
+	assert.Contains(out.String(), "Encoded 1040 bytes as ", "compression should be reported")
+	assert.Contains(out.String(), " of deflate data in byte mode, version ", "variant should be reported")
+	out.Reset()
+	assert.NoError(run([]string{"generate", "-o", path, "-base45", "HELLO WORLD"}, &out))
+	assert.Equal("Encoded 11 bytes as 11 bytes of raw data in alphanumeric mode, version 1\nWrote "+path+", 29x29 modules\n", out.String(), "outputs should match")
+	assert.Error(run([]string{"generate", "-o", path, "-compress", "-masks", masks, "HELLO WORLD"}, &out))
+
+	out.Reset()
+	assert.NoError(run([]string{"generate", "-o", path, "-alphanumeric", "https://example.com"}, &out))
+	assert.Equal("Encoded as \"HTTPS://EXAMPLE.COM\" in alphanumeric mode, 47 bits saved over byte mode\nWrote "+path+", 29x29 modules\n", out.String(), "outputs should match")
+	assert.Error(run([]string{"generate", "-o", path, "-alphanumeric", "-compress", "https://example.com"}, &out))
+
+	assert.ErrorIs(run([]string{"generate", "-dpi", "300", "HELLO WORLD"}, &out), qrerr.ErrInvalidInput)
+	assert.ErrorIs(run([]string{"generate", ""}, &out), qrerr.ErrEmptyInput)
+	assert.ErrorIs(run(nil, &out), errUsage)
+	assert.Error(run([]string{"decode"}, &out))
+}
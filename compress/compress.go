@@ -0,0 +1,265 @@
+// Package compress shrinks large payloads before they are encoded, so that they fit smaller
+// symbols. The data is compressed with deflate or zlib and, optionally, Base45 encoded so
+// that the compressed bytes are held in alphanumeric mode. The variant needing the smallest
+// version is kept, the data as it is included.
+//
+// The variants are told apart by a leading tag byte in byte mode and by a prefix of
+// alphanumeric characters in alphanumeric mode, which Decompress strips after decoding.
+package compress
+
+import (
+	"bytes"
+	"compress/flate"
+	"compress/zlib"
+	"fmt"
+	"io"
+	"qr/qr-gen/base45"
+	"qr/qr-gen/qrerr"
+	"qr/qr-gen/versioner"
+	"strings"
+)
+
+// Variant is a transform of the data before its encoding
+type Variant int
+
+const (
+	// Variant_RAW keeps the data as it is
+	Variant_RAW Variant = iota
+	// Variant_DEFLATE compresses the data in a raw deflate stream, encoded in byte mode
+	Variant_DEFLATE
+	// Variant_ZLIB compresses the data in a zlib stream, encoded in byte mode
+	Variant_ZLIB
+	// Variant_DEFLATE_BASE45 Base45 encodes the deflate stream, held in alphanumeric mode
+	Variant_DEFLATE_BASE45
+	// Variant_ZLIB_BASE45 Base45 encodes the zlib stream, held in alphanumeric mode
+	Variant_ZLIB_BASE45
+)
+
+var variantNames = map[Variant]string{
+	Variant_RAW:            "raw",
+	Variant_DEFLATE:        "deflate",
+	Variant_ZLIB:           "zlib",
+	Variant_DEFLATE_BASE45: "deflate+base45",
+	Variant_ZLIB_BASE45:    "zlib+base45",
+}
+
+func (v Variant) String() string {
+	if name, ok := variantNames[v]; ok {
+		return name
+	}
+	return "unknown"
+}
+
+// Tags leading the data of the byte mode variants. Raw data starting with a tag byte or a
+// Base45 prefix is escaped, so that it is never taken for compressed data.
+const (
+	tagRaw     byte = 0xf4
+	tagDeflate byte = 0xf5
+	tagZlib    byte = 0xf6
+)
+
+// Prefixes of the Base45 variants, made of alphanumeric characters
+const (
+	prefixDeflate = "D:"
+	prefixZlib    = "Z:"
+)
+
+// MaxDecompressedSize bounds the size of the decompressed data, so that a small symbol
+// cannot expand into an unbounded amount of memory
+const MaxDecompressedSize = 1 << 20
+
+// Options selects the variants tried by Compress
+type Options struct {
+	// Base45 also tries the Base45 encoded variants. Three characters of 5.5 bits holding two
+	// bytes, they only pay off when the shorter count indicator of the alphanumeric mode
+	// makes up for it.
+	Base45 bool
+}
+
+// Compressed is the data of the variant needing the smallest version
+type Compressed struct {
+	Variant Variant
+	// Data is the tagged data to encode
+	Data []byte
+	// Mode is the mode in which the data is encoded, byte mode unless the data only holds
+	// numeric or alphanumeric characters
+	Mode    versioner.QrMode
+	Version versioner.QrVersion
+	// Bits is the length of the encoded data, mode and count indicators included
+	Bits int
+}
+
+// Compress transforms the data with every variant of the options and returns the one
+// needing the smallest version at the level. Ties are broken by the number of bits, then in
+// the order of the variants, so that the raw data is kept unless compressing it pays off.
+func Compress(data []byte, lvl versioner.QrEcLevel, options Options) (*Compressed, error) {
+	if len(data) == 0 {
+		return nil, qrerr.ErrEmptyInput
+	}
+
+	candidates := []*Compressed{raw(data)}
+
+	deflated, err := deflate(data)
+	if err != nil {
+		return nil, err
+	}
+	zlibbed, err := zlibCompress(data)
+	if err != nil {
+		return nil, err
+	}
+
+	candidates = append(candidates,
+		&Compressed{Variant: Variant_DEFLATE, Data: append([]byte{tagDeflate}, deflated...), Mode: versioner.QrByteMode},
+		&Compressed{Variant: Variant_ZLIB, Data: append([]byte{tagZlib}, zlibbed...), Mode: versioner.QrByteMode},
+	)
+	if options.Base45 {
+		candidates = append(candidates,
+			&Compressed{Variant: Variant_DEFLATE_BASE45, Data: []byte(prefixDeflate + base45.Encode(deflated)), Mode: versioner.QrAlphanumericMode},
+			&Compressed{Variant: Variant_ZLIB_BASE45, Data: []byte(prefixZlib + base45.Encode(zlibbed)), Mode: versioner.QrAlphanumericMode},
+		)
+	}
+
+	v := versioner.New()
+	var best *Compressed
+	var firstErr error
+	for _, candidate := range candidates {
+		if err := fit(v, candidate, lvl); err != nil {
+			if firstErr == nil {
+				firstErr = err
+			}
+			continue
+		}
+
+		if best == nil || candidate.Version < best.Version || (candidate.Version == best.Version && candidate.Bits < best.Bits) {
+			best = candidate
+		}
+	}
+
+	if best == nil {
+		return nil, firstErr
+	}
+	return best, nil
+}
+
+// The raw data is kept in numeric or alphanumeric mode when its characters allow it, and is
+// escaped in byte mode when it could be taken for compressed data
+func raw(data []byte) *Compressed {
+	if isTagged(data) {
+		return &Compressed{Variant: Variant_RAW, Data: append([]byte{tagRaw}, data...), Mode: versioner.QrByteMode}
+	}
+
+	mode, err := versioner.New().GetMode(string(data))
+	if err != nil {
+		mode = versioner.QrByteMode
+	}
+	return &Compressed{Variant: Variant_RAW, Data: data, Mode: mode}
+}
+
+// Sets the smallest version holding the candidate and the length of its encoding
+func fit(v versioner.Versioner, c *Compressed, lvl versioner.QrEcLevel) error {
+	var err error
+	if c.Mode == versioner.QrByteMode {
+		c.Version, err = v.GetByteLengthVersion(len(c.Data), lvl, false)
+	} else {
+		c.Version, err = v.GetVersion(string(c.Data), c.Mode, lvl)
+	}
+	if err != nil {
+		return err
+	}
+
+	c.Bits = len(v.GetModeIndicator(c.Mode)) + v.GetCountIndicatorLength(c.Version, c.Mode) + v.GetDataLength(string(c.Data), c.Mode)
+	return nil
+}
+
+func isTagged(data []byte) bool {
+	if data[0] >= tagRaw && data[0] <= tagZlib {
+		return true
+	}
+	s := string(data)
+	return strings.HasPrefix(s, prefixDeflate) || strings.HasPrefix(s, prefixZlib)
+}
+
+func deflate(data []byte) ([]byte, error) {
+	var b bytes.Buffer
+	w, err := flate.NewWriter(&b, flate.BestCompression)
+	if err != nil {
+		return nil, fmt.Errorf("Error on compressing the data: %w", err)
+	}
+	return closeWriter(&b, w, data)
+}
+
+func zlibCompress(data []byte) ([]byte, error) {
+	var b bytes.Buffer
+	w, err := zlib.NewWriterLevel(&b, zlib.BestCompression)
+	if err != nil {
+		return nil, fmt.Errorf("Error on compressing the data: %w", err)
+	}
+	return closeWriter(&b, w, data)
+}
+
+func closeWriter(b *bytes.Buffer, w io.WriteCloser, data []byte) ([]byte, error) {
+	if _, err := w.Write(data); err != nil {
+		return nil, fmt.Errorf("Error on compressing the data: %w", err)
+	}
+	if err := w.Close(); err != nil {
+		return nil, fmt.Errorf("Error on compressing the data: %w", err)
+	}
+	return b.Bytes(), nil
+}
+
+// Decompress reverses Compress on the decoded data of a symbol. Data without tag nor prefix
+// is returned as it is, so that symbols of uncompressed payloads are read unchanged.
+func Decompress(data []byte) ([]byte, error) {
+	if len(data) == 0 {
+		return data, nil
+	}
+
+	switch data[0] {
+	case tagRaw:
+		return data[1:], nil
+	case tagDeflate:
+		return inflate(flate.NewReader(bytes.NewReader(data[1:])))
+	case tagZlib:
+		return inflateZlib(data[1:])
+	}
+
+	s := string(data)
+	switch {
+	case strings.HasPrefix(s, prefixDeflate):
+		compressed, err := base45.Decode(s[len(prefixDeflate):])
+		if err != nil {
+			return nil, err
+		}
+		return inflate(flate.NewReader(bytes.NewReader(compressed)))
+	case strings.HasPrefix(s, prefixZlib):
+		compressed, err := base45.Decode(s[len(prefixZlib):])
+		if err != nil {
+			return nil, err
+		}
+		return inflateZlib(compressed)
+	}
+
+	return data, nil
+}
+
+func inflateZlib(data []byte) ([]byte, error) {
+	r, err := zlib.NewReader(bytes.NewReader(data))
+	if err != nil {
+		return nil, fmt.Errorf("%w: invalid zlib stream: %v", qrerr.ErrInvalidInput, err)
+	}
+	return inflate(r)
+}
+
+// Reads the decompressed data up to MaxDecompressedSize
+func inflate(r io.ReadCloser) ([]byte, error) {
+	defer r.Close()
+
+	data, err := io.ReadAll(io.LimitReader(r, MaxDecompressedSize+1))
+	if err != nil {
+		return nil, fmt.Errorf("%w: invalid compressed data: %v", qrerr.ErrInvalidInput, err)
+	}
+	if len(data) > MaxDecompressedSize {
+		return nil, fmt.Errorf("%w: decompressed data longer than %d bytes", qrerr.ErrInvalidInput, MaxDecompressedSize)
+	}
+	return data, nil
+}
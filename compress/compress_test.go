@@ -0,0 +1,130 @@
+package compress
+
+import (
+	"bytes"
+	"compress/flate"
+	"qr/qr-gen/base45"
+	"qr/qr-gen/qrerr"
+	"qr/qr-gen/versioner"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+var text = []byte(strings.Repeat("The quick brown fox jumps over the lazy dog. ", 20))
+
+func TestCompress(t *testing.T) {
+	assert := assert.New(t)
+
+	tests := []struct {
+		data    []byte
+		options Options
+		variant Variant
+		mode    versioner.QrMode
+	}{
+		{[]byte("HELLO WORLD"), Options{}, Variant_RAW, versioner.QrAlphanumericMode},
+		{[]byte("01234567"), Options{Base45: true}, Variant_RAW, versioner.QrNumericMode},
+		{[]byte("hello"), Options{}, Variant_RAW, versioner.QrByteMode},
+		{text, Options{}, Variant_DEFLATE, versioner.QrByteMode},
+		{text, Options{Base45: true}, Variant_DEFLATE, versioner.QrByteMode},
+	}
+
+	for _, test := range tests {
+		compressed, err := Compress(test.data, versioner.QrEcMedium, test.options)
+		if assert.NoError(err) {
+			assert.Equal(test.variant, compressed.Variant, "variants should match")
+			assert.Equal(test.mode, compressed.Mode, "modes should match")
+
+			decompressed, err := Decompress(compressed.Data)
+			assert.NoError(err)
+			assert.Equal(test.data, decompressed, "decompressed data should match")
+		}
+	}
+
+	compressed, err := Compress(text, versioner.QrEcMedium, Options{})
+	if assert.NoError(err) {
+		raw := raw(text)
+		assert.NoError(fit(versioner.New(), raw, versioner.QrEcMedium))
+		assert.Less(compressed.Version, raw.Version, "the compressed data should need a smaller version")
+	}
+}
+
+func TestDecompressBase45(t *testing.T) {
+	assert := assert.New(t)
+
+	deflated, err := deflate(text)
+	assert.NoError(err)
+	zlibbed, err := zlibCompress(text)
+	assert.NoError(err)
+
+	for _, data := range []string{prefixDeflate + base45.Encode(deflated), prefixZlib + base45.Encode(zlibbed)} {
+		decompressed, err := Decompress([]byte(data))
+		assert.NoError(err)
+		assert.Equal(text, decompressed, "decompressed data should match")
+	}
+}
+
+func TestCompressTaggedData(t *testing.T) {
+	assert := assert.New(t)
+
+	for _, data := range [][]byte{{tagDeflate, 1, 2}, []byte("Z:ABC"), []byte("D:ABC")} {
+		compressed, err := Compress(data, versioner.QrEcLow, Options{Base45: true})
+		if assert.NoError(err) {
+			assert.Equal(Variant_RAW, compressed.Variant, "variants should match")
+			assert.Equal(tagRaw, compressed.Data[0], "the raw data should be escaped")
+
+			decompressed, err := Decompress(compressed.Data)
+			assert.NoError(err)
+			assert.Equal(data, decompressed, "decompressed data should match")
+		}
+	}
+}
+
+func TestCompressErrors(t *testing.T) {
+	assert := assert.New(t)
+
+	_, err := Compress(nil, versioner.QrEcLow, Options{})
+	assert.ErrorIs(err, qrerr.ErrEmptyInput)
+
+	_, err = Compress([]byte("A"), versioner.QrEcLevel('X'), Options{})
+	assert.ErrorIs(err, qrerr.ErrInvalidLevel)
+}
+
+func TestDecompressErrors(t *testing.T) {
+	assert := assert.New(t)
+
+	for _, data := range [][]byte{{tagDeflate, 0xff, 0xff}, {tagZlib, 1, 2, 3}, []byte("Z:BB8"), []byte("D:bb8")} {
+		_, err := Decompress(data)
+		assert.Error(err, "corrupt data should be rejected")
+	}
+
+	var b bytes.Buffer
+	w, _ := flate.NewWriter(&b, flate.BestCompression)
+	w.Write(make([]byte, MaxDecompressedSize+1))
+	w.Close()
+	_, err := Decompress(append([]byte{tagDeflate}, b.Bytes()...))
+	assert.ErrorIs(err, qrerr.ErrInvalidInput, "data decompressed beyond the limit should be rejected")
+
+	data, err := Decompress([]byte("HELLO"))
+	assert.NoError(err)
+	assert.Equal([]byte("HELLO"), data, "untagged data should be returned as it is")
+}
+
+func FuzzRoundTrip(f *testing.F) {
+	f.Add([]byte("HELLO WORLD"), true)
+	f.Add(text, false)
+	f.Add([]byte{tagZlib}, true)
+
+	f.Fuzz(func(t *testing.T, data []byte, withBase45 bool) {
+		compressed, err := Compress(data, versioner.QrEcLow, Options{Base45: withBase45})
+		if err != nil {
+			return
+		}
+
+		decompressed, err := Decompress(compressed.Data)
+		if err != nil || !bytes.Equal(data, decompressed) {
+			t.Fatalf("%x should be decompressed back from %x: %v", data, compressed.Data, err)
+		}
+	})
+}
@@ -0,0 +1,470 @@
+// Package decoder reads back the data of QR symbols given as module matrices, such as the
+// ones built by the generator. It locates the symbol within its quiet zone, reads the format
+// information, unmasks and deinterleaves the codewords and parses the segments.
+//
+// The function patterns, the masks and the placement of the modules are implemented
+// independently of the moduler, after the specification, so that both can be checked
+// against each other. Symbols are expected undamaged: error correction codewords which do
+// not match the data are reported rather than corrected.
+package decoder
+
+import (
+	"fmt"
+	"math/bits"
+	"qr/qr-gen/bitbuf"
+	"qr/qr-gen/compress"
+	"qr/qr-gen/gf256"
+	"qr/qr-gen/matrix"
+	"qr/qr-gen/qrerr"
+	"qr/qr-gen/util"
+	"qr/qr-gen/versioner"
+	"strconv"
+)
+
+type Decoder interface {
+	Decode(symbol *matrix.Matrix[util.Module]) (*Result, error)
+}
+
+// Result holds the data of a symbol and how it was encoded
+type Result struct {
+	Version versioner.QrVersion
+	Level   versioner.QrEcLevel
+	Mask    int
+	// Eci is the character set assignment of the ECI header, or -1 without header
+	Eci      int
+	Segments []versioner.Segment
+	// Data is the concatenated data of the segments
+	Data []byte
+}
+
+// Decompress returns the data of a symbol generated with GenerateCompressed, as it was
+// before its compression. Uncompressed data is returned as it is.
+func (r *Result) Decompress() ([]byte, error) {
+	return compress.Decompress(r.Data)
+}
+
+type QrDecoder struct{}
+
+func New() Decoder {
+	return &QrDecoder{}
+}
+
+// Format information strings differing from the read ones by more bits are rejected
+const maxFormatErrors = 3
+
+var modeIndicators = map[int]versioner.QrMode{
+	0b0001: versioner.QrNumericMode,
+	0b0010: versioner.QrAlphanumericMode,
+	0b0100: versioner.QrByteMode,
+}
+
+const (
+	terminatorIndicator = 0b0000
+	eciIndicator        = 0b0111
+)
+
+var maskFormulas = [8]func(row, col int) bool{
+	func(row, col int) bool { return (row+col)%2 == 0 },
+	func(row, col int) bool { return row%2 == 0 },
+	func(row, col int) bool { return col%3 == 0 },
+	func(row, col int) bool { return (row+col)%3 == 0 },
+	func(row, col int) bool { return (row/2+col/3)%2 == 0 },
+	func(row, col int) bool { return (row*col)%2+(row*col)%3 == 0 },
+	func(row, col int) bool { return ((row*col)%2+(row*col)%3)%2 == 0 },
+	func(row, col int) bool { return ((row+col)%2+(row*col)%3)%2 == 0 },
+}
+
+// Decode reads the data of the symbol. The matrix may hold a quiet zone of any width.
+func (d *QrDecoder) Decode(symbol *matrix.Matrix[util.Module]) (*Result, error) {
+	if symbol == nil {
+		return nil, qrerr.ErrEmptyInput
+	}
+
+	grid, err := locateSymbol(symbol)
+	if err != nil {
+		return nil, err
+	}
+
+	size := grid.Width()
+	version := versioner.QrVersion((size - 17) / 4)
+	if size != version.Size() || version < versioner.MinQrVersion || version > versioner.MaxQrVersion {
+		return nil, fmt.Errorf("%w: no version of size %d", qrerr.ErrUnreadable, size)
+	}
+
+	lvl, mask, err := readFormatInformation(grid)
+	if err != nil {
+		return nil, err
+	}
+
+	info := util.QrEcInfo[util.GetECMappingKey(int(version), string(lvl))]
+	codewords := readCodewords(grid, version, mask)
+	data, err := deinterleave(codewords, info)
+	if err != nil {
+		return nil, err
+	}
+
+	result := &Result{Version: version, Level: lvl, Mask: mask, Eci: -1}
+	if err := parseSegments(bitbuf.FromBytes(data), result); err != nil {
+		return nil, err
+	}
+
+	return result, nil
+}
+
+// Crops the matrix to the bounding box of its dark modules, which is the symbol as the
+// finder patterns occupy three of its corners
+func locateSymbol(symbol *matrix.Matrix[util.Module]) (*matrix.Matrix[bool], error) {
+	top, left, bottom, right := symbol.Height(), symbol.Width(), -1, -1
+	symbol.ForEach(func(row, col int, module util.Module) {
+		if !util.IsModuleLighten(module) {
+			top, left = util.Min(top, row), util.Min(left, col)
+			bottom, right = util.Max(bottom, row), util.Max(right, col)
+		}
+	})
+
+	if bottom == -1 || bottom-top != right-left {
+		return nil, fmt.Errorf("%w: no square symbol found", qrerr.ErrUnreadable)
+	}
+
+	dark := matrix.Map(symbol, func(row, col int, module util.Module) bool {
+		return !util.IsModuleLighten(module)
+	})
+	return dark.SubMatrix(top, left, bottom-top+1, right-left+1)
+}
+
+// Reads both copies of the format information and keeps the level and mask of the closest
+// format information string
+func readFormatInformation(grid *matrix.Matrix[bool]) (versioner.QrEcLevel, int, error) {
+	size := grid.Width()
+	module := func(row, col int) int {
+		if dark, _ := grid.At(row, col); dark {
+			return 1
+		}
+		return 0
+	}
+
+	// The bits are numbered from the least significant one
+	first, second := 0, 0
+	for i := 0; i < 15; i++ {
+		switch {
+		case i < 6:
+			first |= module(i, 8) << i
+		case i < 8:
+			first |= module(i+1, 8) << i
+		case i == 8:
+			first |= module(8, 7) << i
+		default:
+			first |= module(8, 14-i) << i
+		}
+
+		if i < 8 {
+			second |= module(8, size-1-i) << i
+		} else {
+			second |= module(size-15+i, 8) << i
+		}
+	}
+
+	best, bestLvl, bestMask := maxFormatErrors+1, versioner.QrEcLevel(0), 0
+	for _, lvl := range versioner.QrEcLevels {
+		for mask, format := range util.FormatInformationStrings[rune(lvl)] {
+			value, _ := strconv.ParseInt(format, 2, 32)
+			for _, read := range []int{first, second} {
+				if distance := bits.OnesCount(uint(read ^ int(value))); distance < best {
+					best, bestLvl, bestMask = distance, lvl, mask
+				}
+			}
+		}
+	}
+
+	if best > maxFormatErrors {
+		return 0, 0, fmt.Errorf("%w: unreadable format information", qrerr.ErrUnreadable)
+	}
+
+	return bestLvl, bestMask, nil
+}
+
+// Gets the modules of the function patterns and of the format and version information,
+// which hold no data
+func functionModules(version versioner.QrVersion) *matrix.Matrix[bool] {
+	size := version.Size()
+	function := matrix.NewMatrix[bool](size, size)
+	fill := func(top, left, height, width int) {
+		for row := top; row < top+height; row++ {
+			for col := left; col < left+width; col++ {
+				function.Set(row, col, true)
+			}
+		}
+	}
+
+	// Finder patterns with their separators and format information
+	fill(0, 0, 9, 9)
+	fill(0, size-8, 9, 8)
+	fill(size-8, 0, 8, 9)
+
+	// Timing patterns
+	fill(6, 0, 1, size)
+	fill(0, 6, size, 1)
+
+	positions := alignmentPatternPositions(version)
+	last := len(positions) - 1
+	for i, row := range positions {
+		for j, col := range positions {
+			if (i == 0 && j == 0) || (i == 0 && j == last) || (i == last && j == 0) {
+				continue
+			}
+			fill(row-2, col-2, 5, 5)
+		}
+	}
+
+	// Version information
+	if version >= 7 {
+		fill(0, size-11, 6, 3)
+		fill(size-11, 0, 3, 6)
+	}
+
+	return function
+}
+
+// Computes the row and column coordinates of the alignment pattern centers, evenly spaced
+// between the timing pattern and the bottom right corner
+func alignmentPatternPositions(version versioner.QrVersion) []int {
+	if version == 1 {
+		return nil
+	}
+
+	size := version.Size()
+	count := int(version)/7 + 2
+	step := 26
+	if version != 32 {
+		step = (int(version)*4 + count*2 + 1) / (count*2 - 2) * 2
+	}
+
+	positions := make([]int, count)
+	positions[0] = 6
+	for i, pos := count-1, size-7; i >= 1; i, pos = i-1, pos-step {
+		positions[i] = pos
+	}
+	return positions
+}
+
+// Reads the unmasked data modules in the placement order, two columns at a time from the
+// right, alternately upwards and downwards, skipping the vertical timing pattern. The
+// remainder bits past the last codeword are dropped.
+func readCodewords(grid *matrix.Matrix[bool], version versioner.QrVersion, mask int) []byte {
+	size := version.Size()
+	function := functionModules(version)
+	b := bitbuf.New()
+
+	for right := size - 1; right >= 1; right -= 2 {
+		if right == 6 {
+			right = 5
+		}
+
+		upwards := (right+1)&2 == 0
+		for vert := 0; vert < size; vert++ {
+			row := vert
+			if upwards {
+				row = size - 1 - vert
+			}
+
+			for col := right; col > right-2; col-- {
+				if isFunction, _ := function.At(row, col); isFunction {
+					continue
+				}
+
+				dark, _ := grid.At(row, col)
+				if dark != maskFormulas[mask](row, col) {
+					b.AppendBit(1)
+				} else {
+					b.AppendBit(0)
+				}
+			}
+		}
+	}
+
+	return b.Bytes()[:b.Len()/util.QrCodewordSize]
+}
+
+// Splits the interleaved codewords back in blocks, checks the error correction codewords
+// of every block and returns the data codewords in order
+func deinterleave(codewords []byte, info util.QrErrorCorrectionInfo) ([]byte, error) {
+	blocksCount := info.NumBlocksGroup1 + info.NumBlocksGroup2
+	if len(codewords) != info.TotalDataCodewords+blocksCount*info.ECCodewordsPerBlock {
+		return nil, fmt.Errorf("%w: %d codewords read", qrerr.ErrUnreadable, len(codewords))
+	}
+
+	dataBlocks := make([][]byte, blocksCount)
+	ecBlocks := make([][]byte, blocksCount)
+	for j := range dataBlocks {
+		length := info.DataCodeworkdsInGroup1Block
+		if j >= info.NumBlocksGroup1 {
+			length = info.DataCodewordsInGroup2Block
+		}
+		dataBlocks[j] = make([]byte, 0, length)
+	}
+
+	next := 0
+	for i := 0; i < util.Max(info.DataCodeworkdsInGroup1Block, info.DataCodewordsInGroup2Block); i++ {
+		for j := range dataBlocks {
+			if i < cap(dataBlocks[j]) {
+				dataBlocks[j] = append(dataBlocks[j], codewords[next])
+				next += 1
+			}
+		}
+	}
+	for i := 0; i < info.ECCodewordsPerBlock; i++ {
+		for j := range ecBlocks {
+			ecBlocks[j] = append(ecBlocks[j], codewords[next])
+			next += 1
+		}
+	}
+
+	rs, err := gf256.NewEncoder(info.ECCodewordsPerBlock)
+	if err != nil {
+		return nil, err
+	}
+	data := make([]byte, 0, info.TotalDataCodewords)
+	for j, block := range dataBlocks {
+		if string(rs.Encode(block)) != string(ecBlocks[j]) {
+			return nil, fmt.Errorf("%w: error correction codewords of block %d do not match", qrerr.ErrUnreadable, j)
+		}
+		data = append(data, block...)
+	}
+
+	return data, nil
+}
+
+// bitReader reads the data codewords as successive fields
+type bitReader struct {
+	b   *bitbuf.Buffer
+	pos int
+}
+
+func (r *bitReader) remaining() int {
+	return r.b.Len() - r.pos
+}
+
+func (r *bitReader) read(n int) (int, error) {
+	if n > r.remaining() {
+		return 0, fmt.Errorf("%w: %d bits read past the data", qrerr.ErrUnreadable, n-r.remaining())
+	}
+
+	value := 0
+	for i := 0; i < n; i++ {
+		value = value<<1 | r.b.Bit(r.pos)
+		r.pos += 1
+	}
+	return value, nil
+}
+
+// Parses the segments up to the terminator, or up to the end of the data when it holds
+// fewer bits than a terminator
+func parseSegments(data *bitbuf.Buffer, result *Result) error {
+	v := versioner.New()
+	r := &bitReader{b: data}
+
+	for r.remaining() >= 4 {
+		indicator, _ := r.read(4)
+		if indicator == terminatorIndicator {
+			return nil
+		}
+
+		if indicator == eciIndicator {
+			eci, err := readEciDesignator(r)
+			if err != nil {
+				return err
+			}
+			result.Eci = eci
+			continue
+		}
+
+		mode, ok := modeIndicators[indicator]
+		if !ok {
+			return fmt.Errorf("%w: unsupported mode indicator %04b", qrerr.ErrUnreadable, indicator)
+		}
+
+		count, err := r.read(v.GetCountIndicatorLength(result.Version, mode))
+		if err != nil {
+			return err
+		}
+
+		segment, err := readSegmentData(r, mode, count)
+		if err != nil {
+			return err
+		}
+
+		result.Segments = append(result.Segments, versioner.Segment{Data: string(segment), Mode: mode})
+		result.Data = append(result.Data, segment...)
+	}
+
+	return nil
+}
+
+// Reads the ECI designator, on one, two or three codewords depending on its first bits
+func readEciDesignator(r *bitReader) (int, error) {
+	first, err := r.read(8)
+	if err != nil {
+		return 0, err
+	}
+
+	switch {
+	case first&0x80 == 0:
+		return first, nil
+	case first&0xc0 == 0x80:
+		next, err := r.read(8)
+		return (first&0x3f)<<8 | next, err
+	case first&0xe0 == 0xc0:
+		next, err := r.read(16)
+		return (first&0x1f)<<16 | next, err
+	}
+
+	return 0, fmt.Errorf("%w: invalid ECI designator", qrerr.ErrUnreadable)
+}
+
+func readSegmentData(r *bitReader, mode versioner.QrMode, count int) ([]byte, error) {
+	segment := make([]byte, 0, count)
+
+	switch mode {
+	case versioner.QrNumericMode:
+		for len(segment) < count {
+			digits := util.Min(3, count-len(segment))
+			value, err := r.read([]int{0, 4, 7, 10}[digits])
+			if err != nil {
+				return nil, err
+			}
+
+			group := strconv.Itoa(value)
+			if len(group) > digits {
+				return nil, fmt.Errorf("%w: invalid numeric group %d", qrerr.ErrUnreadable, value)
+			}
+			segment = append(segment, util.PadLeft(group, "0", digits)...)
+		}
+	case versioner.QrAlphanumericMode:
+		for len(segment) < count {
+			if count-len(segment) == 1 {
+				value, err := r.read(6)
+				if err != nil || value >= len(util.QrAlphanumericCharacters) {
+					return nil, fmt.Errorf("%w: invalid alphanumeric character", qrerr.ErrUnreadable)
+				}
+				segment = append(segment, util.QrAlphanumericCharacters[value])
+				continue
+			}
+
+			value, err := r.read(11)
+			if err != nil || value >= len(util.QrAlphanumericCharacters)*len(util.QrAlphanumericCharacters) {
+				return nil, fmt.Errorf("%w: invalid alphanumeric pair", qrerr.ErrUnreadable)
+			}
+			segment = append(segment, util.QrAlphanumericCharacters[value/45], util.QrAlphanumericCharacters[value%45])
+		}
+	default:
+		for len(segment) < count {
+			value, err := r.read(8)
+			if err != nil {
+				return nil, err
+			}
+			segment = append(segment, byte(value))
+		}
+	}
+
+	return segment, nil
+}
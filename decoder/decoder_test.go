@@ -0,0 +1,239 @@
+package decoder
+
+import (
+	"errors"
+	"math/rand"
+	"qr/qr-gen/generator"
+	"qr/qr-gen/matrix"
+	"qr/qr-gen/moduler"
+	"qr/qr-gen/qrerr"
+	"qr/qr-gen/util"
+	"qr/qr-gen/versioner"
+	"strings"
+	"testing"
+	"unicode/utf8"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDecode(t *testing.T) {
+	assert := assert.New(t)
+
+	tests := []struct {
+		input   string
+		lvl     versioner.QrEcLevel
+		mode    versioner.QrMode
+		version versioner.QrVersion
+	}{
+		{"01234567", versioner.QrEcMedium, versioner.QrNumericMode, 1},
+		{"HELLO WORLD", versioner.QrEcQuartile, versioner.QrAlphanumericMode, 1},
+		{"https://www.qrcode.com/", versioner.QrEcMedium, versioner.QrByteMode, 2},
+		{strings.Repeat("0123456789", 80), versioner.QrECHigh, versioner.QrNumericMode, 19},
+		{strings.Repeat("Grüße ", 250), versioner.QrEcLow, versioner.QrByteMode, 33},
+	}
+
+	for _, test := range tests {
+		symbol, err := generator.New().Generate(test.input, test.lvl)
+		assert.NoError(err)
+
+		result, err := New().Decode(symbol)
+		if !assert.NoError(err, "%q should be decoded", test.input) {
+			continue
+		}
+		assert.Equal(test.input, string(result.Data), "data should match")
+		assert.Equal(test.version, result.Version, "versions should match")
+		assert.Equal(test.lvl, result.Level, "levels should match")
+		assert.Equal([]versioner.Segment{{Data: test.input, Mode: test.mode}}, result.Segments, "segments should match")
+		assert.Equal(-1, result.Eci, "no ECI header should be read")
+	}
+}
+
+func TestDecodeEci(t *testing.T) {
+	assert := assert.New(t)
+
+	symbol, err := generator.New().GenerateByteMode("12345", versioner.QrEcLow, true)
+	assert.NoError(err)
+
+	result, err := New().Decode(symbol)
+	assert.NoError(err)
+	assert.Equal(versioner.QrEciUTF8, result.Eci, "ECI assignments should match")
+	assert.Equal([]versioner.Segment{{Data: "12345", Mode: versioner.QrByteMode}}, result.Segments, "segments should match")
+}
+
+func TestDecodeQuietZones(t *testing.T) {
+	assert := assert.New(t)
+
+	for _, quietZone := range []int{0, 1, 10} {
+		options := moduler.DefaultOptions()
+		options.QuietZone = quietZone
+		symbol, _ := generator.NewWithOptions(options).Generate("HELLO WORLD", versioner.QrEcLow)
+
+		result, err := New().Decode(symbol)
+		assert.NoError(err)
+		assert.Equal("HELLO WORLD", string(result.Data), "data should match with a quiet zone of %d modules", quietZone)
+	}
+}
+
+func TestDecodeMaskSelectors(t *testing.T) {
+	assert := assert.New(t)
+
+	for mask := 0; mask < 8; mask++ {
+		symbol, err := generator.NewWithMaskSelector(moduler.NewFixedMaskSelector(mask)).Generate("HELLO WORLD", versioner.QrEcMedium)
+		assert.NoError(err)
+
+		result, err := New().Decode(symbol)
+		assert.NoError(err)
+		assert.Equal(mask, result.Mask, "fixed mask should be applied")
+		assert.Equal("HELLO WORLD", string(result.Data), "data should match")
+	}
+
+	region := moduler.Region{Row: 9, Col: 9, Width: 7, Height: 7}
+	for _, selector := range []moduler.MaskSelector{moduler.NewLogoAreaSelector(region), moduler.NewContrastSelector(region, 1)} {
+		symbol, _ := generator.NewWithMaskSelector(selector).Generate("https://www.qrcode.com/", versioner.QrECHigh)
+		result, err := New().Decode(symbol)
+		assert.NoError(err)
+		assert.Equal("https://www.qrcode.com/", string(result.Data), "data should match")
+	}
+}
+
+func TestDecodeErrors(t *testing.T) {
+	assert := assert.New(t)
+
+	_, err := New().Decode(matrix.NewMatrix[util.Module](10, 10))
+	assert.ErrorIs(err, qrerr.ErrUnreadable, "blank matrix should be unreadable")
+
+	rect := matrix.NewMatrix[util.Module](22, 21)
+	rect.Init(util.Module_DARKEN)
+	_, err = New().Decode(rect)
+	assert.ErrorIs(err, qrerr.ErrUnreadable, "rectangular matrix should be unreadable")
+
+	// Toggles the first data module, in the bottom right corner of the symbol
+	symbol, _ := generator.New().Generate("HELLO WORLD", versioner.QrEcLow)
+	corner := symbol.Width() - 1 - moduler.DefaultQuietZone
+	if module, _ := symbol.At(corner, corner); util.IsModuleLighten(module) {
+		symbol.Set(corner, corner, util.Module_DARKEN)
+	} else {
+		symbol.Set(corner, corner, util.Module_LIGHTEN)
+	}
+	_, err = New().Decode(symbol)
+	assert.ErrorIs(err, qrerr.ErrUnreadable, "damaged symbol should be reported")
+}
+
+func TestAlignmentPatternPositions(t *testing.T) {
+	assert := assert.New(t)
+
+	assert.Empty(alignmentPatternPositions(1), "version 1 should have no alignment pattern")
+	assert.Equal([]int{6, 18}, alignmentPatternPositions(2), "positions should match")
+	assert.Equal([]int{6, 22, 38}, alignmentPatternPositions(7), "positions should match")
+	assert.Equal([]int{6, 26, 46, 66}, alignmentPatternPositions(14), "positions should match")
+	assert.Equal([]int{6, 34, 60, 86, 112, 138}, alignmentPatternPositions(32), "positions should match")
+	assert.Equal([]int{6, 24, 50, 76, 102, 128, 154}, alignmentPatternPositions(36), "positions should match")
+	assert.Equal([]int{6, 30, 58, 86, 114, 142, 170}, alignmentPatternPositions(40), "positions should match")
+}
+
+// The property behind the fuzz target, over random payloads of every mode and level
+func TestRoundTrip(t *testing.T) {
+	random := rand.New(rand.NewSource(42))
+	alphabets := []string{"0123456789", util.QrAlphanumericCharacters, "abcdefghijklmnopqrstuvwxyz{}~é€\x00\n"}
+
+	for i := 0; i < 200; i++ {
+		alphabet := []rune(alphabets[i%len(alphabets)])
+		input := make([]rune, 1+random.Intn(300))
+		for j := range input {
+			input[j] = alphabet[random.Intn(len(alphabet))]
+		}
+
+		// Characters beyond ISO 8859-1 are only encoded by the byte mode generation
+		byteMode := i%5 == 0 || strings.ContainsRune(string(input), '€')
+		checkRoundTrip(t, string(input), versioner.QrEcLevels[random.Intn(4)], byteMode)
+	}
+}
+
+func FuzzRoundTrip(f *testing.F) {
+	f.Add("01234567", byte(1), false)
+	f.Add("HELLO WORLD", byte(2), false)
+	f.Add("https://www.qrcode.com/", byte(0), false)
+	f.Add("€uro", byte(3), true)
+
+	f.Fuzz(func(t *testing.T, input string, lvl byte, byteMode bool) {
+		if input == "" || !utf8.ValidString(input) {
+			return
+		}
+		checkRoundTrip(t, input, versioner.QrEcLevels[int(lvl)%4], byteMode)
+	})
+}
+
+func FuzzRoundTripBytes(f *testing.F) {
+	f.Add([]byte{0xd9, 0xd9, 0xf7, 0x00, 0xff}, byte(1))
+
+	f.Fuzz(func(t *testing.T, payload []byte, lvl byte) {
+		symbol, err := generator.New().GenerateBytes(payload, versioner.QrEcLevels[int(lvl)%4])
+		if errors.Is(err, qrerr.ErrEmptyInput) || errors.Is(err, qrerr.ErrCapacityExceeded) {
+			return
+		} else if err != nil {
+			t.Fatalf("%x should be generated: %v", payload, err)
+		}
+
+		result, err := New().Decode(symbol)
+		if err != nil || string(result.Data) != string(payload) {
+			t.Fatalf("%x should be decoded: %v", payload, err)
+		}
+	})
+}
+
+// Checks that the decoded data of the generated symbol is the input. Inputs too long for
+// any symbol, or holding characters the generation rejects, are skipped.
+func checkRoundTrip(t *testing.T, input string, lvl versioner.QrEcLevel, byteMode bool) {
+	var symbol *matrix.Matrix[util.Module]
+	var err error
+	if byteMode {
+		symbol, err = generator.New().GenerateByteMode(input, lvl, true)
+	} else {
+		symbol, err = generator.New().Generate(input, lvl)
+	}
+	if err != nil {
+		if !errors.Is(err, qrerr.ErrCapacityExceeded) && !errors.Is(err, qrerr.ErrInvalidInput) {
+			t.Fatalf("%q should be generated: %v", input, err)
+		}
+		return
+	}
+
+	result, err := New().Decode(symbol)
+	if !assert.NoError(t, err, "%q at level %c should be decoded", input, lvl) {
+		t.FailNow()
+	}
+	assert.Equal(t, input, string(result.Data), "decoded data should match")
+	assert.Equal(t, lvl, result.Level, "levels should match")
+}
+
+// Decoding arbitrary grids, or damaged symbols, fails with an error rather than a panic
+func FuzzDecode(f *testing.F) {
+	f.Add("HELLO WORLD", byte(0), []byte{})
+	f.Add("01234567", byte(21), []byte{0xff, 0x00})
+	f.Add("https://www.qrcode.com/", byte(25), []byte{3, 14, 15, 92, 65})
+
+	f.Fuzz(func(t *testing.T, input string, size byte, damage []byte) {
+		var symbol *matrix.Matrix[util.Module]
+		if size == 0 {
+			symbol, _ = generator.New().Generate(input, versioner.QrEcLow)
+		}
+		if symbol == nil {
+			symbol = matrix.NewMatrix[util.Module](int(size), int(size))
+		}
+
+		// Every pair of bytes toggles a module
+		for i := 0; i+1 < len(damage) && symbol.Width() > 0; i += 2 {
+			row, col := int(damage[i])%symbol.Height(), int(damage[i+1])%symbol.Width()
+			if module, _ := symbol.At(row, col); util.IsModuleLighten(module) {
+				symbol.Set(row, col, util.Module_DARKEN)
+			} else {
+				symbol.Set(row, col, util.Module_LIGHTEN)
+			}
+		}
+
+		result, err := New().Decode(symbol)
+		if err == nil && result == nil {
+			t.Fatal("a result should be returned when no error is")
+		}
+	})
+}
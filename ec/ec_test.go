@@ -1,7 +1,11 @@
 package ec
 
 import (
+	"errors"
+	"qr/qr-gen/bitbuf"
 	"qr/qr-gen/encoder"
+	"qr/qr-gen/gf256"
+	"qr/qr-gen/qrerr"
 	"qr/qr-gen/versioner"
 	"testing"
 
@@ -19,11 +23,11 @@ func TestMessagePolynomialGeneration(t *testing.T) {
 	mode, _ := v.GetMode(input)
 	version, _ := v.GetVersion(input, mode, versioner.QrEcMedium)
 	encoded, _ := e.Encode(input, versioner.QrEcMedium)
-	augmented := e.AugmentEncodedInput(encoded, version, versioner.QrEcMedium)
+	augmented, _ := e.AugmentEncodedInput(encoded, version, versioner.QrEcMedium)
 	assert.Equal("00100000010110110000101101111000110100010111001011011100010011010100001101000000111011000001000111101100000100011110110000010001",
 		augmented, "Augmented encoded input should match binary representation")
 
-	actual := ec.GetMessagePolynomial(augmented)
+	actual, _ := ec.GetMessagePolynomial(augmented)
 	var expected QrPolynomial = []int{17, 236, 17, 236, 17, 236, 64, 67, 77, 220, 114, 209, 120, 11, 91, 32}
 	assert.Equal(expected, actual, "Message polynomial coefficients should match")
 }
@@ -51,9 +55,75 @@ func TestErrorCorrectionCodewordsGenerator(t *testing.T) {
 	mode, _ := v.GetMode(input)
 	version, _ := v.GetVersion(input, mode, versioner.QrEcMedium)
 	encoded, _ := e.Encode(input, versioner.QrEcMedium)
-	augmented := e.AugmentEncodedInput(encoded, version, versioner.QrEcMedium)
+	augmented, _ := e.AugmentEncodedInput(encoded, version, versioner.QrEcMedium)
 
-	actual := ec.GetErrorCorrectionCodewords(augmented, 1, versioner.QrEcMedium)
+	actual, _ := ec.GetErrorCorrectionCodewords(augmented, 1, versioner.QrEcMedium)
 	var expected QrPolynomial = []int{23, 93, 226, 231, 215, 235, 119, 39, 35, 196}
 	assert.Equal(expected, actual, "Error correction codewords should match")
 }
+
+func TestErrorCorrectionBytes(t *testing.T) {
+	assert := assert.New(t)
+	ec := New()
+
+	// Data codewords of "HELLO WORLD" at version 1-M
+	data := []byte{32, 91, 11, 120, 209, 114, 220, 77, 67, 64, 236, 17, 236, 17, 236, 17}
+	actual, err := ec.GetErrorCorrectionBytes(data, 10)
+	assert.Nil(err, "Error correction codewords should be computed")
+	assert.Equal([]byte{196, 35, 39, 119, 235, 215, 231, 226, 93, 23}, actual, "Error correction codewords should start from the highest degree term")
+}
+
+// The message followed by the error correction codewords is a multiple of the generator
+// polynomial, whether computed from the bytes or from the encoded bits
+func FuzzErrorCorrectionCodewords(f *testing.F) {
+	f.Add([]byte{32, 91, 11, 120, 209, 114, 220, 77, 67, 64, 236, 17, 236, 17, 236, 17}, 10)
+	f.Add([]byte{16, 32, 12, 86, 97, 128, 236, 17}, 0)
+	f.Add([]byte{}, 30)
+
+	f.Fuzz(func(t *testing.T, data []byte, degree int) {
+		degree %= 70
+		ec := New()
+
+		codewords, err := ec.GetErrorCorrectionBytes(data, degree)
+		if degree < 1 {
+			if !errors.Is(err, qrerr.ErrInvalidInput) {
+				t.Fatalf("degree %d should be rejected, got %v", degree, err)
+			}
+			return
+		}
+		if err != nil {
+			t.Fatalf("Error on computing the codewords: %v", err)
+		}
+
+		encoded := bitbuf.New()
+		encoded.AppendBytes(data)
+		polynomial, err := ec.GetErrorCorrectionCodewordsOfDegree(encoded.String(), degree)
+		if err != nil {
+			t.Fatalf("Error on computing the polynomial: %v", err)
+		}
+		if len(polynomial) != degree {
+			t.Fatalf("%d coefficients computed instead of %d", len(polynomial), degree)
+		}
+		for i, codeword := range codewords {
+			if polynomial[degree-1-i] != int(codeword) {
+				t.Fatalf("coefficient of degree %d should be the codeword %d", degree-1-i, i)
+			}
+		}
+
+		// Divides the codeword by the generator, whose coefficients are ordered from the
+		// lowest degree term, leaving a zero remainder
+		generator := ec.GetGeneratorPolynomialOfDegree(degree)
+		remainder := append(append([]byte(nil), data...), codewords...)
+		for i := range data {
+			coefficient := remainder[i]
+			for j := 1; j <= degree; j++ {
+				remainder[i+j] ^= gf256.Mul(byte(generator[degree-j]), coefficient)
+			}
+		}
+		for _, coefficient := range remainder[len(data):] {
+			if coefficient != 0 {
+				t.Fatalf("codeword is not a multiple of the generator of degree %d", degree)
+			}
+		}
+	})
+}
@@ -2,190 +2,313 @@ package encoder
 
 import (
 	"fmt"
+	"qr/qr-gen/bitbuf"
+	"qr/qr-gen/qrerr"
 	"qr/qr-gen/util"
 	"qr/qr-gen/versioner"
-	"strconv"
 	"strings"
+	"unicode/utf8"
 )
 
 type Encoder interface {
-	EncodeNumericInput(s string) string
-	EncodeAlphanumericInput(s string) string
+	EncodeNumericInput(s string) (string, error)
+	EncodeAlphanumericInput(s string) (string, error)
 	EncodeByteInput(s string) string
+	EncodeInput(s string, mode versioner.QrMode) (string, error)
+	EncodeInputBits(s string, mode versioner.QrMode) (*bitbuf.Buffer, error)
 	Encode(s string, lvl versioner.QrEcLevel) (string, error)
-	AugmentEncodedInput(s string, version versioner.QrVersion, lvl versioner.QrEcLevel) string
+	EncodeByteMode(s string, lvl versioner.QrEcLevel, eci bool) (string, error)
+	AugmentEncodedInput(s string, version versioner.QrVersion, lvl versioner.QrEcLevel) (string, error)
+	EncodeBits(s string, lvl versioner.QrEcLevel) (*bitbuf.Buffer, error)
+	EncodeVersionBits(s string, mode versioner.QrMode, version versioner.QrVersion) (*bitbuf.Buffer, error)
+	EncodeByteModeBits(s string, lvl versioner.QrEcLevel, eci bool) (*bitbuf.Buffer, error)
+	EncodeBytes(data []byte, lvl versioner.QrEcLevel) (*bitbuf.Buffer, error)
+	AugmentEncodedBits(b *bitbuf.Buffer, version versioner.QrVersion, lvl versioner.QrEcLevel) error
 }
 
-type QrEncoder struct{}
+type QrEncoder struct {
+	versioner versioner.Versioner
+}
 type QrNumericMask int
 type QrAlphanumericMask int
 type QrByteMask int
 type QrPaddingByte int
 
 func New() Encoder {
-	return &QrEncoder{}
+	return NewWithVersioner(versioner.New())
 }
 
-func (e *QrEncoder) Encode(s string, lvl versioner.QrEcLevel) (string, error) {
-	v := versioner.New()
+// NewWithVersioner returns an encoder selecting the modes and versions with the given versioner
+func NewWithVersioner(v versioner.Versioner) Encoder {
+	return &QrEncoder{versioner: v}
+}
 
-	mode, err := v.GetMode(s)
+func (e *QrEncoder) Encode(s string, lvl versioner.QrEcLevel) (string, error) {
+	b, err := e.EncodeBits(s, lvl)
 	if err != nil {
-		return "", fmt.Errorf("Error on computing the encoding mode: %v", err)
+		return "", err
 	}
+	return b.String(), nil
+}
 
-	modeIndicator := v.GetModeIndicator(mode)
+// EncodeBits encodes the input, preceded by its mode and count indicators, in the most compact mode
+func (e *QrEncoder) EncodeBits(s string, lvl versioner.QrEcLevel) (*bitbuf.Buffer, error) {
+	v := e.versioner
 
-	version, err := v.GetVersion(s, mode, lvl)
+	mode, err := v.GetMode(s)
 	if err != nil {
-		return "", fmt.Errorf("Error on computing the encoding version: %v", err)
+		return nil, fmt.Errorf("Error on computing the encoding mode: %w", err)
 	}
 
-	countIndicator, err := v.GetCountIndicator(s, version, mode)
+	version, err := v.GetVersion(s, mode, lvl)
 	if err != nil {
-		return "", fmt.Errorf("Error on computing the encoding count indicator: %v", err)
+		return nil, fmt.Errorf("Error on computing the encoding version: %w", err)
 	}
 
-	encodedInput := e.EncodeInput(s, versioner.QrMode(mode))
-
-	return string(modeIndicator) + countIndicator + encodedInput, nil
+	return e.EncodeVersionBits(s, mode, version)
 }
 
-func (e *QrEncoder) EncodeNumericInput(s string) string {
-	groups := util.SplitInGroups(s, SPLIT_VALUES[versioner.QrMode(versioner.QrNumericMode)])
-	result := make([]string, len(groups))
+// EncodeVersionBits encodes the input in the given mode, preceded by its mode indicator and
+// its count indicator sized for the given version, for callers having selected them already
+func (e *QrEncoder) EncodeVersionBits(s string, mode versioner.QrMode, version versioner.QrVersion) (*bitbuf.Buffer, error) {
+	if version < versioner.MinQrVersion || version > versioner.MaxQrVersion {
+		return nil, fmt.Errorf("%w %d", qrerr.ErrInvalidVersion, version)
+	}
 
-	for index, group := range groups {
-		numericValue, _ := strconv.Atoi(group)
-		binaryString := strconv.FormatInt(int64(numericValue), 2)
+	input, err := e.EncodeInputBits(s, mode)
+	if err != nil {
+		return nil, err
+	}
 
-		switch true {
-		case numericValue <= 9:
-			binaryString = util.PadLeft(binaryString, "0", QR_NUMERIC_MASKS[DIGIT])
-		case 10 <= numericValue && numericValue <= 99:
-			binaryString = util.PadLeft(binaryString, "0", QR_NUMERIC_MASKS[TEN])
-		default:
-			binaryString = util.PadLeft(binaryString, "0", QR_NUMERIC_MASKS[HUNDRED])
-		}
+	v := e.versioner
+	b := bitbuf.New()
+	b.AppendString(v.GetModeIndicator(mode))
+	b.AppendBits(len(s), v.GetCountIndicatorLength(version, mode))
+	b.AppendBuffer(input)
+	return b, nil
+}
 
-		result[index] = binaryString
+// EncodeByteMode encodes the input in byte mode, even when a more compact mode could hold it.
+// When eci is set, the segment is preceded by the UTF-8 ECI header, so that readers do not
+// interpret the bytes as ISO 8859-1.
+func (e *QrEncoder) EncodeByteMode(s string, lvl versioner.QrEcLevel, eci bool) (string, error) {
+	b, err := e.EncodeByteModeBits(s, lvl, eci)
+	if err != nil {
+		return "", err
 	}
+	return b.String(), nil
+}
 
-	return strings.Join(result, "")
+func (e *QrEncoder) EncodeByteModeBits(s string, lvl versioner.QrEcLevel, eci bool) (*bitbuf.Buffer, error) {
+	return e.encodeByteMode([]byte(s), lvl, eci)
 }
 
-func (e *QrEncoder) EncodeAlphanumericInput(s string) string {
-	groups := util.SplitInGroups(s, SPLIT_VALUES[versioner.QrMode(versioner.QrAlphanumericMode)])
-	result := make([]string, len(groups))
+// EncodeBytes encodes a binary payload in byte mode, preceded by its mode indicator and the
+// count of its bytes. No character set is assumed, hence no ECI header.
+func (e *QrEncoder) EncodeBytes(data []byte, lvl versioner.QrEcLevel) (*bitbuf.Buffer, error) {
+	if len(data) == 0 {
+		return nil, qrerr.ErrEmptyInput
+	}
+	return e.encodeByteMode(data, lvl, false)
+}
 
-	for index, group := range groups {
-		var binaryString string
-		var firstCharValue int
-		var secondCharValue int
-		var groupValue int
+func (e *QrEncoder) encodeByteMode(data []byte, lvl versioner.QrEcLevel, eci bool) (*bitbuf.Buffer, error) {
+	v := e.versioner
 
-		if len(group) == 2 {
-			firstCharValue, secondCharValue = ALPHA_NUMERIC_VALUES[group[0]], ALPHA_NUMERIC_VALUES[group[1]]
-			groupValue = QR_ALPHA_NUMERIC_FACTOR*firstCharValue + secondCharValue
-			binaryString = strconv.FormatInt(int64(groupValue), 2)
-			binaryString = util.PadLeft(binaryString, "0", QR_ALPHA_NUMERIC_MASKS[FULL_GROUP])
-		} else {
-			firstCharValue = ALPHA_NUMERIC_VALUES[group[0]]
-			groupValue = firstCharValue
-			binaryString = strconv.FormatInt(int64(groupValue), 2)
-			binaryString = util.PadLeft(binaryString, "0", QR_ALPHA_NUMERIC_MASKS[ONE_ONLY])
-		}
+	version, err := v.GetByteLengthVersion(len(data), lvl, eci)
+	if err != nil {
+		return nil, fmt.Errorf("Error on computing the encoding version: %w", err)
+	}
 
-		result[index] = binaryString
+	b := bitbuf.New()
+	if eci {
+		b.AppendString(v.GetEciHeader(versioner.QrEciUTF8))
 	}
+	b.AppendString(v.GetModeIndicator(versioner.QrByteMode))
+	b.AppendBits(len(data), v.GetCountIndicatorLength(version, versioner.QrByteMode))
+	b.AppendBytes(data)
 
-	return strings.Join(result, "")
+	return b, nil
 }
 
-func (e *QrEncoder) EncodeByteInput(s string) string {
-	groups := util.SplitInGroups(s, SPLIT_VALUES[versioner.QrMode(versioner.QrByteMode)])
-	result := make([]string, len(groups))
+func (e *QrEncoder) EncodeNumericInput(s string) (string, error) {
+	b := bitbuf.New()
+	if err := e.appendNumericInput(b, s); err != nil {
+		return "", err
+	}
+	return b.String(), nil
+}
 
-	for index, group := range groups {
-		hex := strconv.FormatInt(int64(group[0]), 16)
-		hex0, _ := strconv.ParseInt(string(hex[0]), 16, 64)
-		hex1, _ := strconv.ParseInt(string(hex[1]), 16, 64)
+func (e *QrEncoder) EncodeAlphanumericInput(s string) (string, error) {
+	b := bitbuf.New()
+	if err := e.appendAlphanumericInput(b, s); err != nil {
+		return "", err
+	}
+	return b.String(), nil
+}
 
-		bin0 := util.PadLeft(strconv.FormatInt(hex0, 2), "0", QR_BYTE_MASKS[CHAR])
-		bin1 := util.PadLeft(strconv.FormatInt(hex1, 2), "0", QR_BYTE_MASKS[CHAR])
+func (e *QrEncoder) EncodeByteInput(s string) string {
+	b := bitbuf.New()
+	e.appendByteInput(b, s)
+	return b.String()
+}
 
-		result[index] = bin0 + bin1
+func (e *QrEncoder) EncodeInput(s string, mode versioner.QrMode) (string, error) {
+	b, err := e.EncodeInputBits(s, mode)
+	if err != nil {
+		return "", err
 	}
+	return b.String(), nil
+}
 
-	return strings.Join(result, "")
+// EncodeInputBits encodes the input in the given mode, without mode and count indicators
+func (e *QrEncoder) EncodeInputBits(s string, mode versioner.QrMode) (*bitbuf.Buffer, error) {
+	b := bitbuf.New()
+	if err := e.appendInput(b, s, mode); err != nil {
+		return nil, err
+	}
+	return b, nil
 }
 
-func (e *QrEncoder) EncodeInput(s string, mode versioner.QrMode) string {
+func (e *QrEncoder) appendInput(b *bitbuf.Buffer, s string, mode versioner.QrMode) error {
 	switch mode {
 	case versioner.QrMode(versioner.QrNumericMode):
-		return e.EncodeNumericInput(s)
+		return e.appendNumericInput(b, s)
 	case versioner.QrMode(versioner.QrAlphanumericMode):
-		return e.EncodeAlphanumericInput(s)
+		return e.appendAlphanumericInput(b, s)
 	case versioner.QrMode(versioner.QrByteMode):
-		return e.EncodeByteInput(s)
-	default:
-		return ""
+		e.appendByteInput(b, s)
+		return nil
 	}
+	return fmt.Errorf("%w %q", qrerr.ErrInvalidMode, mode)
 }
 
-func (e *QrEncoder) AugmentEncodedInput(s string, version versioner.QrVersion, lvl versioner.QrEcLevel) string {
-	requiredBitsCount := e.getNumberOfRequiredBits(version, lvl)
+// Checks that every character of the input belongs to the character set of the mode,
+// before anything is appended
+func (e *QrEncoder) validateInput(s string, mode versioner.QrMode, valid func(r rune) bool) error {
+	for pos, r := range s {
+		if !valid(r) {
+			return &qrerr.ErrInvalidCharacter{Pos: pos, Rune: r, Mode: string(mode)}
+		}
+	}
+	return nil
+}
 
-	s = e.augmentWithTerminatorBits(s, requiredBitsCount)
-	remainingBitsCount := requiredBitsCount - len(s)
-	if remainingBitsCount == 0 {
-		return s
+func (e *QrEncoder) appendNumericInput(b *bitbuf.Buffer, s string) error {
+	isDigit := func(r rune) bool { return r >= '0' && r <= '9' }
+	if err := e.validateInput(s, versioner.QrNumericMode, isDigit); err != nil {
+		return err
 	}
 
-	s = e.augmentWithZeroBits(s)
-	remainingBitsCount = requiredBitsCount - len(s)
-	if remainingBitsCount == 0 {
-		return s
+	for _, group := range util.SplitInGroups(s, SPLIT_VALUES[versioner.QrMode(versioner.QrNumericMode)]) {
+		numericValue := 0
+		for i := 0; i < len(group); i++ {
+			numericValue = numericValue*10 + int(group[i]-'0')
+		}
+
+		// The bit length depends on the number of digits, so groups with leading zeros keep their width
+		switch len(group) {
+		case 1:
+			b.AppendBits(numericValue, QR_NUMERIC_MASKS[DIGIT])
+		case 2:
+			b.AppendBits(numericValue, QR_NUMERIC_MASKS[TEN])
+		default:
+			b.AppendBits(numericValue, QR_NUMERIC_MASKS[HUNDRED])
+		}
 	}
 
-	return e.augmentWithPaddingBits(s, requiredBitsCount)
+	return nil
 }
 
-func (e *QrEncoder) getNumberOfRequiredBits(version versioner.QrVersion, lvl versioner.QrEcLevel) int {
-	key := util.GetECMappingKey(int(version), string(lvl))
-	return util.QrCodewordSize * util.QrEcInfo[key].TotalDataCodewords
+func (e *QrEncoder) appendAlphanumericInput(b *bitbuf.Buffer, s string) error {
+	isAlphanumeric := func(r rune) bool {
+		return r < utf8.RuneSelf && strings.IndexByte(util.QrAlphanumericCharacters, byte(r)) >= 0
+	}
+	if err := e.validateInput(s, versioner.QrAlphanumericMode, isAlphanumeric); err != nil {
+		return err
+	}
+
+	for _, group := range util.SplitInGroups(s, SPLIT_VALUES[versioner.QrMode(versioner.QrAlphanumericMode)]) {
+		if len(group) == 2 {
+			firstCharValue, secondCharValue := alphanumericValue(group[0]), alphanumericValue(group[1])
+			groupValue := QR_ALPHA_NUMERIC_FACTOR*firstCharValue + secondCharValue
+			b.AppendBits(groupValue, QR_ALPHA_NUMERIC_MASKS[FULL_GROUP])
+		} else {
+			b.AppendBits(alphanumericValue(group[0]), QR_ALPHA_NUMERIC_MASKS[ONE_ONLY])
+		}
+	}
+
+	return nil
+}
+
+func alphanumericValue(c byte) int {
+	return strings.IndexByte(util.QrAlphanumericCharacters, c)
 }
 
-func (e *QrEncoder) augmentWithTerminatorBits(s string, requiredBitsCount int) string {
-	remainingBitsCount := requiredBitsCount - len(s)
+func (e *QrEncoder) appendByteInput(b *bitbuf.Buffer, s string) {
+	b.AppendBytes([]byte(s))
+}
 
-	if remainingBitsCount >= 4 {
-		return util.PadRight(s, "0", len(s)+4)
+func (e *QrEncoder) AugmentEncodedInput(s string, version versioner.QrVersion, lvl versioner.QrEcLevel) (string, error) {
+	b, err := bitbuf.FromString(s)
+	if err != nil {
+		return "", err
 	}
 
-	return util.PadRight(s, "0", len(s)+remainingBitsCount)
+	if err := e.AugmentEncodedBits(b, version, lvl); err != nil {
+		return "", err
+	}
+	return b.String(), nil
 }
 
-func (e *QrEncoder) augmentWithPaddingBits(s string, requiredBitsCount int) string {
-	numberOfPadBytes := (requiredBitsCount - len(s)) / util.QrCodewordSize
-	paddingByteIndex := 0
-	paddingSequence := ""
+// AugmentEncodedBits fills the encoded data up to the capacity of the symbol with the
+// terminator, the zero bits completing the last codeword and the padding codewords.
+// Encoded data exceeding the capacity is rejected.
+func (e *QrEncoder) AugmentEncodedBits(b *bitbuf.Buffer, version versioner.QrVersion, lvl versioner.QrEcLevel) error {
+	key := util.GetECMappingKey(int(version), string(lvl))
+	if _, ok := util.QrEcInfo[key]; !ok {
+		return fmt.Errorf("%w %s", qrerr.ErrInvalidVersion, key)
+	}
+
+	requiredBitsCount := e.getNumberOfRequiredBits(version, lvl)
+	if b.Len() > requiredBitsCount {
+		return &qrerr.ErrDataTooLong{Needed: b.Len(), Max: requiredBitsCount, Level: rune(lvl)}
+	}
 
-	for i := 0; i < numberOfPadBytes; i++ {
-		if paddingByteIndex == 2 {
-			paddingByteIndex = paddingByteIndex % 2
-		}
+	e.augmentWithTerminatorBits(b, requiredBitsCount)
+	if requiredBitsCount == b.Len() {
+		return nil
+	}
 
-		paddingSequence = paddingSequence + QR_PADDING_BYTES[QrPaddingByte(paddingByteIndex)]
-		paddingByteIndex += 1
+	e.augmentWithZeroBits(b)
+	if requiredBitsCount == b.Len() {
+		return nil
 	}
 
-	return s + paddingSequence
+	e.augmentWithPaddingBits(b, requiredBitsCount)
+	return nil
+}
+
+func (e *QrEncoder) getNumberOfRequiredBits(version versioner.QrVersion, lvl versioner.QrEcLevel) int {
+	key := util.GetECMappingKey(int(version), string(lvl))
+	return util.QrCodewordSize * util.QrEcInfo[key].TotalDataCodewords
+}
+
+func (e *QrEncoder) augmentWithTerminatorBits(b *bitbuf.Buffer, requiredBitsCount int) {
+	b.AppendBits(0, util.Min(4, requiredBitsCount-b.Len()))
+}
+
+func (e *QrEncoder) augmentWithPaddingBits(b *bitbuf.Buffer, requiredBitsCount int) {
+	numberOfPadBytes := (requiredBitsCount - b.Len()) / util.QrCodewordSize
+
+	for i := 0; i < numberOfPadBytes; i++ {
+		b.AppendString(QR_PADDING_BYTES[QrPaddingByte(i%2)])
+	}
 }
 
-func (e *QrEncoder) augmentWithZeroBits(s string) string {
-	multiple := util.GetClosestMultiple(len(s), util.QrCodewordSize)
-	return util.PadRight(s, "0", multiple)
+func (e *QrEncoder) augmentWithZeroBits(b *bitbuf.Buffer) {
+	multiple := (b.Len() + util.QrCodewordSize - 1) / util.QrCodewordSize * util.QrCodewordSize
+	b.AppendBits(0, multiple-b.Len())
 }
 
 const QR_ALPHA_NUMERIC_FACTOR = 45
@@ -231,54 +354,6 @@ var QR_BYTE_MASKS = map[QrByteMask]int{
 	CHAR: 4,
 }
 
-var ALPHA_NUMERIC_VALUES = map[byte]int{
-	'0': 0,
-	'1': 1,
-	'2': 2,
-	'3': 3,
-	'4': 4,
-	'5': 5,
-	'6': 6,
-	'7': 7,
-	'8': 8,
-	'9': 9,
-	'A': 10,
-	'B': 11,
-	'C': 12,
-	'D': 13,
-	'E': 14,
-	'F': 15,
-	'G': 16,
-	'H': 17,
-	'I': 18,
-	'J': 19,
-	'K': 20,
-	'L': 21,
-	'M': 22,
-	'N': 23,
-	'O': 24,
-	'P': 25,
-	'Q': 26,
-	'R': 27,
-	'S': 28,
-	'T': 29,
-	'U': 30,
-	'V': 31,
-	'W': 32,
-	'X': 33,
-	'Y': 34,
-	'Z': 35,
-	' ': 36,
-	'$': 37,
-	'%': 38,
-	'*': 39,
-	'+': 40,
-	'-': 41,
-	'.': 42,
-	'/': 43,
-	':': 44,
-}
-
 var QR_PADDING_BYTES = map[QrPaddingByte]string{
 	FIRST:  "11101100",
 	SECOND: "00010001",
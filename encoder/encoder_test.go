@@ -1,7 +1,10 @@
 package encoder
 
 import (
+	"errors"
+	"qr/qr-gen/qrerr"
 	"qr/qr-gen/versioner"
+	"strings"
 	"testing"
 
 	"github.com/stretchr/testify/assert"
@@ -13,12 +16,16 @@ func TestNumericEncoding(t *testing.T) {
 	var input string
 
 	input = "8675309"
-	actual := e.EncodeNumericInput(input)
+	actual, _ := e.EncodeNumericInput(input)
 	assert.Equal("110110001110000100101001", actual, "Input should match binary representation")
 
 	input = "1234"
-	actual = e.EncodeNumericInput(input)
+	actual, _ = e.EncodeNumericInput(input)
 	assert.Equal("00011110110100", actual, "Input should match binary representation")
+
+	input = "01234567"
+	actual, _ = e.EncodeNumericInput(input)
+	assert.Equal("000000110001010110011000011", actual, "Input should match binary representation")
 }
 
 func TestAlphaNumericEncoding(t *testing.T) {
@@ -27,15 +34,15 @@ func TestAlphaNumericEncoding(t *testing.T) {
 	var input string
 
 	input = "HE"
-	actual := e.EncodeAlphanumericInput(input)
+	actual, _ := e.EncodeAlphanumericInput(input)
 	assert.Equal("01100001011", actual, "Input should match binary representation")
 
 	input = "HED"
-	actual = e.EncodeAlphanumericInput(input)
+	actual, _ = e.EncodeAlphanumericInput(input)
 	assert.Equal("01100001011001101", actual, "Input should match binary representation")
 
 	input = "HELLO WORLD"
-	actual = e.EncodeAlphanumericInput(input)
+	actual, _ = e.EncodeAlphanumericInput(input)
 	assert.Equal("0110000101101111000110100010111001011011100010011010100001101", actual, "Input should mathc binary representation")
 }
 
@@ -53,6 +60,71 @@ func TestByteNumericInput(t *testing.T) {
 	actual = e.EncodeByteInput(input)
 	assert.Equal("01001000011001010110110001101100011011110010110000100000011101110110111101110010011011000110010000100001",
 		actual, "Input should match binary representation")
+
+	input = "a\n"
+	actual = e.EncodeByteInput(input)
+	assert.Equal("0110000100001010", actual, "Bytes below 0x10 should keep their leading zeros")
+}
+
+func TestByteModeEncoding(t *testing.T) {
+	assert := assert.New(t)
+	e := New()
+
+	actual, err := e.EncodeByteMode("123", versioner.QrEcLow, false)
+	assert.NoError(err)
+	assert.Equal("0100"+"00000011"+"001100010011001000110011", actual, "Digits should be encoded in byte mode")
+
+	actual, err = e.EncodeByteMode("é", versioner.QrEcLow, true)
+	assert.NoError(err)
+	assert.Equal("011100011010"+"0100"+"00000010"+"1100001110101001", actual, "UTF-8 bytes should follow the ECI header")
+
+	_, err = e.EncodeByteMode(strings.Repeat("a", 2954), versioner.QrEcLow, false)
+	assert.Error(err)
+}
+
+func TestBytesEncoding(t *testing.T) {
+	assert := assert.New(t)
+	e := New()
+
+	// Bytes which are not valid UTF-8 are counted one by one
+	actual, err := e.EncodeBytes([]byte{0xe9, 0x00, 0xff}, versioner.QrEcLow)
+	assert.NoError(err)
+	assert.Equal("0100"+"00000011"+"111010010000000011111111", actual.String(), "bytes should be encoded in byte mode")
+
+	actual, err = e.EncodeBytes([]byte("123"), versioner.QrEcLow)
+	assert.NoError(err)
+	assert.Equal("0100"+"00000011"+"001100010011001000110011", actual.String(), "digits should be encoded in byte mode")
+
+	// Versions 10 and above count the bytes on 16 bits
+	actual, _ = e.EncodeBytes(make([]byte, 300), versioner.QrEcLow)
+	assert.Equal("0100"+"0000000100101100", actual.String()[:20], "count indicators should match")
+
+	_, err = e.EncodeBytes(nil, versioner.QrEcLow)
+	assert.ErrorIs(err, qrerr.ErrEmptyInput)
+
+	_, err = e.EncodeBytes(make([]byte, 2954), versioner.QrEcLow)
+	assert.ErrorIs(err, qrerr.ErrCapacityExceeded)
+}
+
+func TestVersionEncoding(t *testing.T) {
+	assert := assert.New(t)
+	e := New()
+
+	actual, err := e.EncodeVersionBits("HE", versioner.QrAlphanumericMode, 1)
+	assert.NoError(err)
+	assert.Equal("0010"+"000000010"+"01100001011", actual.String(), "input should be encoded in the given mode")
+
+	// The count indicator follows the given version rather than the smallest one
+	actual, err = e.EncodeVersionBits("HE", versioner.QrAlphanumericMode, 10)
+	assert.NoError(err)
+	assert.Equal("0010"+"00000000010"+"01100001011", actual.String(), "count indicators should match")
+
+	_, err = e.EncodeVersionBits("HE", versioner.QrAlphanumericMode, 41)
+	assert.ErrorIs(err, qrerr.ErrInvalidVersion)
+	_, err = e.EncodeVersionBits("HE", "kanji", 1)
+	assert.ErrorIs(err, qrerr.ErrInvalidMode)
+	_, err = e.EncodeVersionBits("he", versioner.QrAlphanumericMode, 1)
+	assert.ErrorIs(err, qrerr.ErrInvalidInput)
 }
 
 func TestEncodedInputAugmentation(t *testing.T) {
@@ -65,7 +137,88 @@ func TestEncodedInputAugmentation(t *testing.T) {
 	mode, _ := v.GetMode(input)
 	version, _ := v.GetVersion(input, mode, versioner.QrEcQuartile)
 	encoded, _ := e.Encode(input, versioner.QrEcQuartile)
-	actual := e.AugmentEncodedInput(encoded, version, versioner.QrEcQuartile)
+	actual, _ := e.AugmentEncodedInput(encoded, version, versioner.QrEcQuartile)
 	assert.Equal("00100000010110110000101101111000110100010111001011011100010011010100001101000000111011000001000111101100",
 		actual, "Augmented encoded input should match binary representation")
 }
+
+func TestEncodingErrors(t *testing.T) {
+	assert := assert.New(t)
+	e := New()
+
+	_, err := e.EncodeNumericInput("12a4")
+	var invalid *qrerr.ErrInvalidCharacter
+	if assert.ErrorAs(err, &invalid) {
+		assert.Equal(qrerr.ErrInvalidCharacter{Pos: 2, Rune: 'a', Mode: "numeric"}, *invalid, "error details should match")
+	}
+
+	_, err = e.EncodeAlphanumericInput("HELLO wORLD")
+	assert.EqualError(err, "Invalid character 'w' at position 6 in alphanumeric mode", "error messages should match")
+	assert.ErrorIs(err, qrerr.ErrInvalidInput)
+
+	_, err = e.EncodeInput("HELLO", "kanji")
+	assert.ErrorIs(err, qrerr.ErrInvalidMode)
+
+	_, err = e.Encode("", versioner.QrEcLow)
+	assert.ErrorIs(err, qrerr.ErrEmptyInput)
+
+	_, err = e.Encode(strings.Repeat("1", 7090), versioner.QrEcLow)
+	var tooLong *qrerr.ErrDataTooLong
+	if assert.ErrorAs(err, &tooLong) {
+		assert.Equal(qrerr.ErrDataTooLong{Needed: 4 + 14 + 23634, Max: 23648, Level: 'L'}, *tooLong, "error details should match")
+	}
+	assert.True(errors.Is(err, qrerr.ErrCapacityExceeded), "too long data should match the sentinel error")
+
+	_, err = e.AugmentEncodedInput(strings.Repeat("0", 153), 1, versioner.QrEcQuartile)
+	assert.ErrorIs(err, qrerr.ErrCapacityExceeded)
+
+	_, err = e.AugmentEncodedInput("0102", 1, versioner.QrEcQuartile)
+	assert.EqualError(err, "Invalid bit '2' at position 3", "error messages should match")
+
+	_, err = e.AugmentEncodedInput("0101", 41, versioner.QrEcQuartile)
+	assert.ErrorIs(err, qrerr.ErrInvalidVersion)
+}
+
+// The encoded input holds the number of bits computed by the versioner, and is augmented up
+// to the capacity of the version chosen for it
+func FuzzEncode(f *testing.F) {
+	f.Add("01234567", byte(1))
+	f.Add("HELLO WORLD", byte(2))
+	f.Add("https://www.qrcode.com/", byte(0))
+	f.Add("\x00\x0f", byte(3))
+
+	f.Fuzz(func(t *testing.T, s string, lvl byte) {
+		v := versioner.New()
+		e := New()
+		level := versioner.QrEcLevels[int(lvl)%len(versioner.QrEcLevels)]
+
+		mode, err := v.GetMode(s)
+		if err != nil {
+			return
+		}
+
+		input, err := e.EncodeInput(s, mode)
+		if err != nil {
+			t.Fatalf("%q should be encoded in %s mode: %v", s, mode, err)
+		}
+		if len(input) != v.GetDataLength(s, mode) {
+			t.Fatalf("%q should be encoded in %d bits, got %d", s, v.GetDataLength(s, mode), len(input))
+		}
+
+		encoded, err := e.Encode(s, level)
+		if errors.Is(err, qrerr.ErrCapacityExceeded) {
+			return
+		} else if err != nil {
+			t.Fatalf("%q should be encoded: %v", s, err)
+		}
+
+		version, _ := v.GetVersion(s, mode, level)
+		augmented, err := e.AugmentEncodedInput(encoded, version, level)
+		if err != nil {
+			t.Fatalf("%q should fit in version %d: %v", s, version, err)
+		}
+		if !strings.HasPrefix(augmented, encoded) || len(augmented) != e.(*QrEncoder).getNumberOfRequiredBits(version, level) {
+			t.Fatalf("%q should be augmented up to the capacity of version %d", s, version)
+		}
+	})
+}
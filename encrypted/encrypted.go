@@ -0,0 +1,174 @@
+// Package encrypted wraps payloads in AES-GCM envelopes, so that the content of a symbol,
+// such as a provisioning secret, is only read by the holders of the key. The header of the
+// envelope package, without fields, is followed by a random nonce and the ciphertext with
+// its 16 bytes tag:
+//
+//	header | nonce (12 bytes) | ciphertext | tag
+//
+// The header is the additional authenticated data of the ciphertext, so that the key ID
+// cannot be altered. Since the nonces are random, a key only seals a bounded number of
+// envelopes, see MaxSealsPerKey.
+package encrypted
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"fmt"
+	"io"
+	"qr/qr-gen/envelope"
+	"qr/qr-gen/qrerr"
+	"sync/atomic"
+)
+
+// Version of the envelope format
+const envelopeVersion byte = 1
+
+const (
+	// NonceSize is the size of the random nonces of the envelopes
+	NonceSize = 12
+	// Overhead is the size of the authentication tag
+	Overhead = 16
+)
+
+// MaxSealsPerKey bounds the number of envelopes sealed by a Sealer, after which Seal fails
+// with ErrKeyExhausted. Random nonces of 96 bits are only unlikely to repeat up to 2^32
+// envelopes under a key. The count is kept by each Sealer: the envelopes sealed with the
+// same key by other sealers, processes or runs are not counted, so the key must be rotated
+// before their total reaches the limit.
+const MaxSealsPerKey = 1 << 32
+
+// TextPrefix precedes the Base45 encoding of the text envelopes, in alphanumeric characters
+const TextPrefix = "ENC1:"
+
+// Sealer encrypts payloads into envelopes with its key
+type Sealer struct {
+	keyID string
+	aead  cipher.AEAD
+	rand  io.Reader
+	seals atomic.Uint64
+}
+
+// NewSealer returns a sealer using the AES key of 16, 24 or 32 bytes, identified by keyID
+// in the envelopes
+func NewSealer(keyID string, key []byte) (*Sealer, error) {
+	if err := envelope.ValidateKeyID(keyID); err != nil {
+		return nil, err
+	}
+
+	aead, err := newAEAD(key)
+	if err != nil {
+		return nil, err
+	}
+	return &Sealer{keyID: keyID, aead: aead, rand: rand.Reader}, nil
+}
+
+func (s *Sealer) KeyID() string {
+	return s.keyID
+}
+
+// Seal returns the envelope of the payload, encrypted under a fresh random nonce, to be
+// encoded in byte mode
+func (s *Sealer) Seal(data []byte) ([]byte, error) {
+	if len(data) == 0 {
+		return nil, qrerr.ErrEmptyInput
+	}
+	if s.seals.Add(1) > MaxSealsPerKey {
+		return nil, fmt.Errorf("%w: key %q sealed %d envelopes, it must be rotated", qrerr.ErrKeyExhausted, s.keyID, uint64(MaxSealsPerKey))
+	}
+
+	header := &envelope.Header{Version: envelopeVersion, KeyID: s.keyID}
+	sealed := header.Append(make([]byte, 0, header.Len()+NonceSize+len(data)+Overhead))
+	sealed = sealed[:header.Len()+NonceSize]
+
+	nonce := sealed[header.Len():]
+	if _, err := io.ReadFull(s.rand, nonce); err != nil {
+		return nil, fmt.Errorf("Error on generating the nonce: %w", err)
+	}
+
+	return s.aead.Seal(sealed, nonce, data, sealed[:header.Len()]), nil
+}
+
+// SealText returns the envelope of the payload Base45 encoded after TextPrefix, to be
+// encoded in alphanumeric mode
+func (s *Sealer) SealText(data []byte) (string, error) {
+	sealed, err := s.Seal(data)
+	if err != nil {
+		return "", err
+	}
+	return envelope.EncodeText(TextPrefix, sealed), nil
+}
+
+// Opened is the payload of an envelope decrypted and authenticated
+type Opened struct {
+	Data  []byte
+	KeyID string
+}
+
+// Opener decrypts the envelopes with the keys of their key IDs. Keys may be added and
+// removed while envelopes are opened by concurrent goroutines.
+type Opener struct {
+	keys *envelope.Keyring[cipher.AEAD]
+}
+
+func NewOpener() *Opener {
+	return &Opener{keys: envelope.NewKeyring[cipher.AEAD]()}
+}
+
+// AddKey registers the AES key of the key ID
+func (o *Opener) AddKey(keyID string, key []byte) error {
+	aead, err := newAEAD(key)
+	if err != nil {
+		return err
+	}
+	return o.keys.Add(keyID, aead)
+}
+
+// RemoveKey retires the key of the key ID, whose envelopes are then rejected
+func (o *Opener) RemoveKey(keyID string) {
+	o.keys.Remove(keyID)
+}
+
+// Open decrypts the envelope, such as the data of a decoded symbol, and returns its payload.
+// Envelopes of unknown keys, or whose header or ciphertext were altered, are rejected.
+func (o *Opener) Open(sealed []byte) (*Opened, error) {
+	header, err := envelope.ParseHeader(sealed, envelopeVersion, 0)
+	if err != nil {
+		return nil, err
+	}
+
+	headerEnd := header.Len()
+	if len(sealed) < headerEnd+NonceSize+Overhead {
+		return nil, fmt.Errorf("%w: envelope too short", qrerr.ErrInvalidInput)
+	}
+
+	aead, err := o.keys.Get(header.KeyID)
+	if err != nil {
+		return nil, err
+	}
+
+	nonce := sealed[headerEnd : headerEnd+NonceSize]
+	data, err := aead.Open(nil, nonce, sealed[headerEnd+NonceSize:], sealed[:headerEnd])
+	if err != nil {
+		return nil, qrerr.ErrDecryptionFailed
+	}
+
+	return &Opened{Data: data, KeyID: header.KeyID}, nil
+}
+
+// OpenText decrypts an envelope returned by SealText
+func (o *Opener) OpenText(text string) (*Opened, error) {
+	sealed, err := envelope.DecodeText(TextPrefix, text)
+	if err != nil {
+		return nil, err
+	}
+	return o.Open(sealed)
+}
+
+func newAEAD(key []byte) (cipher.AEAD, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, fmt.Errorf("%w: invalid AES key length %d", qrerr.ErrInvalidInput, len(key))
+	}
+	return cipher.NewGCM(block)
+}
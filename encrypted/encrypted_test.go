@@ -0,0 +1,149 @@
+package encrypted
+
+import (
+	"bytes"
+	"errors"
+	"qr/qr-gen/qrerr"
+	"qr/qr-gen/versioner"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+var secret = []byte("WIFI:T:WPA;S:lab;P:s3cr3t;;")
+
+var (
+	key2024 = bytes.Repeat([]byte{0x24}, 16)
+	key2025 = bytes.Repeat([]byte{0x25}, 32)
+)
+
+func newOpener(t testing.TB) *Opener {
+	opener := NewOpener()
+	if err := opener.AddKey("k2024", key2024); err != nil {
+		t.Fatal(err)
+	}
+	if err := opener.AddKey("k2025", key2025); err != nil {
+		t.Fatal(err)
+	}
+	return opener
+}
+
+func TestSealAndOpen(t *testing.T) {
+	assert := assert.New(t)
+	opener := newOpener(t)
+
+	for keyID, key := range map[string][]byte{"k2024": key2024, "k2025": key2025} {
+		sealer, err := NewSealer(keyID, key)
+		assert.NoError(err)
+
+		envelope, err := sealer.Seal(secret)
+		assert.NoError(err)
+		assert.Len(envelope, 2+len(keyID)+NonceSize+len(secret)+Overhead, "envelope lengths should match")
+		assert.False(bytes.Contains(envelope, secret), "the payload should be encrypted")
+
+		opened, err := opener.Open(envelope)
+		if assert.NoError(err) {
+			assert.Equal(Opened{Data: secret, KeyID: keyID}, *opened, "opened payloads should match")
+		}
+
+		other, _ := sealer.Seal(secret)
+		assert.NotEqual(envelope, other, "every envelope should get its own nonce")
+
+		text, err := sealer.SealText(secret)
+		assert.NoError(err)
+		mode, err := versioner.New().GetMode(text)
+		assert.NoError(err)
+		assert.Equal(versioner.QrAlphanumericMode, mode, "text envelopes should be alphanumeric")
+
+		opened, err = opener.OpenText(text)
+		if assert.NoError(err) {
+			assert.Equal(secret, opened.Data, "opened payloads should match")
+		}
+	}
+}
+
+func TestOpenErrors(t *testing.T) {
+	assert := assert.New(t)
+	opener := newOpener(t)
+	sealer, _ := NewSealer("k2024", key2024)
+	envelope, _ := sealer.Seal(secret)
+
+	// The header, the nonce, the ciphertext and the tag are all authenticated
+	for _, i := range []int{2, 2 + len("k2024"), 2 + len("k2024") + NonceSize, len(envelope) - 1} {
+		tampered := append([]byte(nil), envelope...)
+		tampered[i] ^= 0x01
+		_, err := opener.Open(tampered)
+		assert.Error(err, "tampering byte %d should be detected", i)
+	}
+
+	// A key registered under another key ID does not open the envelope
+	wrong := NewOpener()
+	assert.NoError(wrong.AddKey("k2024", key2025))
+	_, err := wrong.Open(envelope)
+	assert.ErrorIs(err, qrerr.ErrDecryptionFailed)
+
+	opener.RemoveKey("k2024")
+	_, err = opener.Open(envelope)
+	assert.ErrorIs(err, qrerr.ErrUnknownKey)
+
+	_, err = opener.Open(envelope[:2+len("k2024")+NonceSize+Overhead-1])
+	assert.ErrorIs(err, qrerr.ErrInvalidInput, "envelopes shorter than a nonce and a tag should be rejected")
+}
+
+type failingReader struct{}
+
+func (r failingReader) Read(p []byte) (int, error) {
+	return 0, errors.New("no entropy")
+}
+
+func TestSealErrors(t *testing.T) {
+	assert := assert.New(t)
+
+	_, err := NewSealer("k", make([]byte, 10))
+	assert.ErrorIs(err, qrerr.ErrInvalidInput)
+	_, err = NewSealer("", key2024)
+	assert.ErrorIs(err, qrerr.ErrInvalidInput)
+	assert.ErrorIs(NewOpener().AddKey("k", nil), qrerr.ErrInvalidInput)
+
+	sealer, _ := NewSealer("k", key2024)
+	_, err = sealer.Seal(nil)
+	assert.ErrorIs(err, qrerr.ErrEmptyInput)
+
+	sealer.rand = failingReader{}
+	_, err = sealer.Seal(secret)
+	assert.Error(err, "nonce failures should be reported")
+
+}
+
+func TestSealerExhaustion(t *testing.T) {
+	assert := assert.New(t)
+
+	sealer, _ := NewSealer("k2024", key2024)
+	sealer.seals.Store(MaxSealsPerKey - 1)
+	_, err := sealer.Seal(secret)
+	assert.NoError(err, "the last envelope under the limit should be sealed")
+
+	_, err = sealer.Seal(secret)
+	assert.ErrorIs(err, qrerr.ErrKeyExhausted, "keys should be rotated after the nonce limit")
+	_, err = sealer.SealText(secret)
+	assert.ErrorIs(err, qrerr.ErrKeyExhausted, "exhausted sealers should stay exhausted")
+
+	// The limit is kept per sealer, so a new sealer of the same key starts over
+	other, _ := NewSealer("k2024", key2024)
+	_, err = other.Seal(secret)
+	assert.NoError(err)
+}
+
+func FuzzOpen(f *testing.F) {
+	opener := newOpener(f)
+	sealer, _ := NewSealer("k2025", key2025)
+	envelope, _ := sealer.Seal(secret)
+	f.Add(envelope)
+
+	f.Fuzz(func(t *testing.T, envelope []byte) {
+		// Never panics, and only opens envelopes of the registered keys
+		if opened, err := opener.Open(envelope); err == nil && opened.KeyID != "k2024" && opened.KeyID != "k2025" {
+			t.Fatalf("unexpected key %q", opened.KeyID)
+		}
+	})
+}
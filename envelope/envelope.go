@@ -0,0 +1,126 @@
+// Package envelope holds the framing shared by the signed and encrypted payloads. An
+// envelope starts with a header made of the version of its format, the fixed fields of the
+// format and a key ID prefixed by its length:
+//
+//	version (1 byte) | fields | key ID length (1 byte) | key ID
+//
+// The key ID selects the key of the envelope in a Keyring, among the current and retired
+// ones. Envelopes are encoded in byte mode as they are, or Base45 encoded after a text
+// prefix to be held in alphanumeric mode.
+package envelope
+
+import (
+	"fmt"
+	"qr/qr-gen/base45"
+	"qr/qr-gen/qrerr"
+	"strings"
+	"sync"
+)
+
+// MaxKeyIDLength is the length limit of the key IDs, which are stored in every envelope
+const MaxKeyIDLength = 255
+
+// Header is the header of an envelope
+type Header struct {
+	Version byte
+	// Fields are the fixed fields following the version, such as an algorithm
+	Fields []byte
+	KeyID  string
+}
+
+// ValidateKeyID checks that the key ID fits its length prefix
+func ValidateKeyID(keyID string) error {
+	if len(keyID) == 0 || len(keyID) > MaxKeyIDLength {
+		return fmt.Errorf("%w: key IDs must hold 1 to %d bytes", qrerr.ErrInvalidInput, MaxKeyIDLength)
+	}
+	return nil
+}
+
+// Append appends the header to the envelope. The key ID must be valid.
+func (h *Header) Append(envelope []byte) []byte {
+	envelope = append(envelope, h.Version)
+	envelope = append(envelope, h.Fields...)
+	envelope = append(envelope, byte(len(h.KeyID)))
+	return append(envelope, h.KeyID...)
+}
+
+// Len returns the length of the header in the envelope
+func (h *Header) Len() int {
+	return 2 + len(h.Fields) + len(h.KeyID)
+}
+
+// ParseHeader reads the header of an envelope of the given version, whose format has the
+// given number of fixed fields. The fields share the storage of the envelope.
+func ParseHeader(envelope []byte, version byte, fields int) (*Header, error) {
+	if len(envelope) < 2+fields {
+		return nil, fmt.Errorf("%w: envelope too short", qrerr.ErrInvalidInput)
+	}
+	if envelope[0] != version {
+		return nil, fmt.Errorf("%w: unsupported envelope version %d", qrerr.ErrInvalidInput, envelope[0])
+	}
+
+	keyIDStart := 2 + fields
+	keyIDEnd := keyIDStart + int(envelope[1+fields])
+	if len(envelope) < keyIDEnd {
+		return nil, fmt.Errorf("%w: envelope too short", qrerr.ErrInvalidInput)
+	}
+
+	return &Header{Version: version, Fields: envelope[1 : 1+fields], KeyID: string(envelope[keyIDStart:keyIDEnd])}, nil
+}
+
+// EncodeText returns the envelope Base45 encoded after the prefix, which must only hold
+// alphanumeric characters
+func EncodeText(prefix string, envelope []byte) string {
+	return prefix + base45.Encode(envelope)
+}
+
+// DecodeText returns the envelope of a text returned by EncodeText with the same prefix
+func DecodeText(prefix, text string) ([]byte, error) {
+	if !strings.HasPrefix(text, prefix) {
+		return nil, fmt.Errorf("%w: missing %q prefix", qrerr.ErrInvalidInput, prefix)
+	}
+	return base45.Decode(text[len(prefix):])
+}
+
+// Keyring holds the keys of the key IDs. Keys are added as they are issued and removed once
+// the envelopes using them are retired. It is safe for concurrent use, so that keys can be
+// rotated while envelopes are read.
+type Keyring[K any] struct {
+	mu   sync.RWMutex
+	keys map[string]K
+}
+
+func NewKeyring[K any]() *Keyring[K] {
+	return &Keyring[K]{keys: map[string]K{}}
+}
+
+// Add registers the key of the key ID, replacing its previous key
+func (r *Keyring[K]) Add(keyID string, key K) error {
+	if err := ValidateKeyID(keyID); err != nil {
+		return err
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.keys[keyID] = key
+	return nil
+}
+
+// Remove retires the key of the key ID
+func (r *Keyring[K]) Remove(keyID string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	delete(r.keys, keyID)
+}
+
+// Get returns the key of the key ID, or ErrUnknownKey
+func (r *Keyring[K]) Get(keyID string) (K, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	key, ok := r.keys[keyID]
+	if !ok {
+		return key, fmt.Errorf("%w %q", qrerr.ErrUnknownKey, keyID)
+	}
+	return key, nil
+}
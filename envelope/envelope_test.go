@@ -0,0 +1,161 @@
+package envelope
+
+import (
+	"qr/qr-gen/decoder"
+	"qr/qr-gen/generator"
+	"qr/qr-gen/qrerr"
+	"qr/qr-gen/versioner"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestHeader(t *testing.T) {
+	assert := assert.New(t)
+
+	header := &Header{Version: 1, Fields: []byte{7}, KeyID: "k2025"}
+	envelope := header.Append([]byte{})
+	assert.Equal([]byte{1, 7, 5, 'k', '2', '0', '2', '5'}, envelope, "headers should match")
+	assert.Equal(len(envelope), header.Len(), "header lengths should match")
+
+	parsed, err := ParseHeader(append(envelope, "payload"...), 1, 1)
+	if assert.NoError(err) {
+		assert.Equal(header, parsed, "parsed headers should match")
+	}
+
+	parsed, err = ParseHeader([]byte{1, 2, 'i', 'd'}, 1, 0)
+	if assert.NoError(err) {
+		assert.Equal(&Header{Version: 1, Fields: []byte{}, KeyID: "id"}, parsed, "headers without fields should match")
+	}
+}
+
+func TestParseHeaderErrors(t *testing.T) {
+	assert := assert.New(t)
+
+	for _, envelope := range [][]byte{nil, {1}, {1, 7}, {1, 7, 3, 'k'}, {2, 7, 1, 'k'}} {
+		_, err := ParseHeader(envelope, 1, 1)
+		assert.ErrorIs(err, qrerr.ErrInvalidInput, "%v should be rejected", envelope)
+	}
+}
+
+func TestValidateKeyID(t *testing.T) {
+	assert := assert.New(t)
+
+	assert.NoError(ValidateKeyID("k"))
+	assert.NoError(ValidateKeyID(strings.Repeat("k", MaxKeyIDLength)))
+	assert.ErrorIs(ValidateKeyID(""), qrerr.ErrInvalidInput)
+	assert.ErrorIs(ValidateKeyID(strings.Repeat("k", MaxKeyIDLength+1)), qrerr.ErrInvalidInput)
+}
+
+func TestText(t *testing.T) {
+	assert := assert.New(t)
+	envelope := []byte{1, 2, 'i', 'd', 0x00, 0xff}
+
+	text := EncodeText("ENV1:", envelope)
+	mode, err := versioner.New().GetMode(text)
+	assert.NoError(err)
+	assert.Equal(versioner.QrAlphanumericMode, mode, "text envelopes should be alphanumeric")
+
+	decoded, err := DecodeText("ENV1:", text)
+	assert.NoError(err)
+	assert.Equal(envelope, decoded, "decoded envelopes should match")
+
+	_, err = DecodeText("ENV2:", text)
+	assert.ErrorIs(err, qrerr.ErrInvalidInput, "other prefixes should be rejected")
+	_, err = DecodeText("ENV1:", "ENV1:A")
+	assert.ErrorIs(err, qrerr.ErrInvalidInput, "invalid Base45 should be rejected")
+}
+
+// Envelopes go through symbols in byte mode, and in alphanumeric mode once Base45 encoded
+func TestDecodedSymbols(t *testing.T) {
+	assert := assert.New(t)
+	envelope := (&Header{Version: 1, Fields: []byte{2}, KeyID: "k2025"}).Append([]byte{})
+	envelope = append(envelope, 0x00, 0x80, 0xff)
+	g := generator.New()
+
+	symbol, err := g.GenerateBytes(envelope, versioner.QrEcMedium)
+	assert.NoError(err)
+	result, err := decoder.New().Decode(symbol)
+	if assert.NoError(err) {
+		assert.Equal(envelope, result.Data, "decoded envelopes should match")
+	}
+
+	symbol, err = g.Generate(EncodeText("ENV1:", envelope), versioner.QrEcMedium)
+	assert.NoError(err)
+	result, err = decoder.New().Decode(symbol)
+	if assert.NoError(err) {
+		decoded, err := DecodeText("ENV1:", string(result.Data))
+		assert.NoError(err)
+		assert.Equal(envelope, decoded, "decoded envelopes should match")
+	}
+}
+
+func TestKeyring(t *testing.T) {
+	assert := assert.New(t)
+	keyring := NewKeyring[int]()
+
+	assert.NoError(keyring.Add("k2024", 2024))
+	assert.NoError(keyring.Add("k2025", 2025))
+	assert.ErrorIs(keyring.Add("", 0), qrerr.ErrInvalidInput)
+
+	key, err := keyring.Get("k2024")
+	assert.NoError(err)
+	assert.Equal(2024, key, "keys should match")
+
+	keyring.Remove("k2024")
+	_, err = keyring.Get("k2024")
+	assert.ErrorIs(err, qrerr.ErrUnknownKey, "retired keys should be unknown")
+
+	key, err = keyring.Get("k2025")
+	assert.NoError(err)
+	assert.Equal(2025, key, "other keys should be kept")
+}
+
+// Keys are rotated while envelopes are read, which the race detector checks
+func TestConcurrentKeyring(t *testing.T) {
+	keyring := NewKeyring[int]()
+	assert.NoError(t, keyring.Add("current", 1))
+
+	var wg sync.WaitGroup
+	for n := 0; n < 8; n++ {
+		wg.Add(2)
+		go func() {
+			defer wg.Done()
+			for i := 0; i < 100; i++ {
+				keyring.Add("rotated", i)
+				keyring.Remove("rotated")
+			}
+		}()
+		go func() {
+			defer wg.Done()
+			for i := 0; i < 100; i++ {
+				if _, err := keyring.Get("current"); err != nil {
+					t.Error(err)
+				}
+				keyring.Get("rotated")
+			}
+		}()
+	}
+	wg.Wait()
+}
+
+// Parsing arbitrary envelopes fails with an error rather than a panic, and the key ID stays
+// within the envelope
+func FuzzParseHeader(f *testing.F) {
+	f.Add([]byte{1, 7, 5, 'k', '2', '0', '2', '5'}, 1)
+	f.Add([]byte{1, 255}, 0)
+
+	f.Fuzz(func(t *testing.T, envelope []byte, fields int) {
+		fields %= 4
+		if fields < 0 {
+			fields = -fields
+		}
+
+		header, err := ParseHeader(envelope, 1, fields)
+		if err == nil && header.Len() > len(envelope) {
+			t.Fatalf("header of %d bytes parsed from %d bytes", header.Len(), len(envelope))
+		}
+	})
+}
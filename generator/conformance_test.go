@@ -0,0 +1,214 @@
+package generator
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"qr/qr-gen/encoder"
+	"qr/qr-gen/interleaver"
+	"qr/qr-gen/matrix"
+	"qr/qr-gen/moduler"
+	"qr/qr-gen/util"
+	"qr/qr-gen/versioner"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// The golden files are written by this generator: they catch regressions of the symbols
+// but are no reference of the specification, a wrong symbol being recorded as it is.
+// TestConformanceAnnexSymbol checks a symbol against the specification instead.
+// Regenerate the golden files with: go test ./generator -run TestConformance -update
+var update = flag.Bool("update", false, "regenerate the golden files of the conformance suite")
+
+const goldenDir = "testdata/conformance"
+
+type conformanceCase struct {
+	name    string
+	input   string
+	lvl     versioner.QrEcLevel
+	version versioner.QrVersion
+}
+
+// conformanceCases fills symbols of a spread of versions with every mode and level, the
+// inputs being as long as the symbols allow so that each one lands on its version. The
+// codewords of the example of the annex are checked against the specification separately.
+func conformanceCases() []conformanceCase {
+	cases := []conformanceCase{{"annex-i-01234567-1-M", "01234567", versioner.QrEcMedium, 1}}
+	alphabets := map[versioner.QrMode]string{
+		versioner.QrNumericMode:      "0123456789",
+		versioner.QrAlphanumericMode: "0123456789ABCDEFGHIJKLMNOPQRSTUVWXYZ $%*+-./:",
+		versioner.QrByteMode:         "qr-gen conformance, ISO/IEC 18004 ",
+	}
+
+	empty, _ := versioner.New().GetSegmentsCapacity(nil)
+	for _, mode := range versioner.QrModes {
+		for _, version := range []versioner.QrVersion{1, 2, 7, 14, 40} {
+			for _, lvl := range versioner.QrEcLevels {
+				if version == 40 && lvl != versioner.QrEcMedium {
+					continue
+				}
+
+				alphabet := alphabets[mode]
+				length := empty.RemainingCharacters(version, lvl, mode)
+				input := strings.Repeat(alphabet, length/len(alphabet)+1)[:length]
+				cases = append(cases, conformanceCase{fmt.Sprintf("%s-%d-%c", mode, version, lvl), input, lvl, version})
+			}
+		}
+	}
+
+	return cases
+}
+
+func TestConformance(t *testing.T) {
+	for _, test := range conformanceCases() {
+		t.Run(test.name, func(t *testing.T) {
+			assert := assert.New(t)
+
+			symbol, err := New().Generate(test.input, test.lvl)
+			if !assert.NoError(err) {
+				return
+			}
+			assert.NoError(symbol.Crop(moduler.DefaultQuietZone))
+			assert.Equal(test.version.Size(), symbol.Width(), "symbol should be of version %d", test.version)
+
+			path := filepath.Join(goldenDir, test.name+".txt")
+			actual := formatGrid(symbol)
+
+			if *update {
+				assert.NoError(os.MkdirAll(goldenDir, 0755))
+				assert.NoError(os.WriteFile(path, []byte(actual), 0644))
+				return
+			}
+
+			expected, err := os.ReadFile(path)
+			if !assert.NoError(err, "golden file should exist, regenerate it with -update") {
+				return
+			}
+
+			if diff := diffGrids(string(expected), actual); diff != "" {
+				t.Errorf("symbol does not match %s:\n%s", path, diff)
+			}
+		})
+	}
+}
+
+// The codewords of the example of the annex I of ISO/IEC 18004
+func TestConformanceAnnexCodewords(t *testing.T) {
+	assert := assert.New(t)
+
+	e := encoder.New()
+	encoded, err := e.Encode("01234567", versioner.QrEcMedium)
+	assert.NoError(err)
+	encoded, err = e.AugmentEncodedInput(encoded, 1, versioner.QrEcMedium)
+	assert.NoError(err)
+	message, err := interleaver.New().GetFinalMessage(encoded, 1, versioner.QrEcMedium)
+	assert.NoError(err)
+
+	data := "00010000 00100000 00001100 01010110 01100001 10000000 11101100 00010001 " +
+		"11101100 00010001 11101100 00010001 11101100 00010001 11101100 00010001"
+	ec := "10100101 00100100 11010100 11000001 11101101 00110110 11000111 10000111 00101100 01010101"
+	assert.Equal(strings.ReplaceAll(data+ec, " ", ""), message, "codewords should match the annex")
+}
+
+// The symbol of the example of the annex I of ISO/IEC 18004, built with the mask pattern 010
+// of the annex. The penalty evaluation of this generator selects the mask pattern 000, which
+// is why the golden file of the example differs.
+func TestConformanceAnnexSymbol(t *testing.T) {
+	assert := assert.New(t)
+	expected := []string{
+		"#######..#.##.#######",
+		"#.....#..####.#.....#",
+		"#.###.#.#.....#.###.#",
+		"#.###.#.##....#.###.#",
+		"#.###.#.#.###.#.###.#",
+		"#.....#.#...#.#.....#",
+		"#######.#.#.#.#######",
+		"........#..##........",
+		"#.#####..#..#.#####..",
+		"...#.#.##.#.#..#.##..",
+		"..#...##.#.#.#..#####",
+		"....#....#.....####..",
+		"...######..#.#..#....",
+		"........#.#####..##..",
+		"#######..##.#.##.....",
+		"#.....#.#.#####...#.#",
+		"#.###.#.#...#..#.##..",
+		"#.###.#.##..#..#.....",
+		"#.###.#.#.##.#..#.#..",
+		"#.....#........##.##.",
+		"#######.####.#..#.#..",
+	}
+
+	symbol, err := NewWithMaskSelector(moduler.NewFixedMaskSelector(0b010)).Generate("01234567", versioner.QrEcMedium)
+	if !assert.NoError(err) {
+		return
+	}
+	assert.NoError(symbol.Crop(moduler.DefaultQuietZone))
+
+	if diff := diffGrids(strings.Join(expected, "\n")+"\n", formatGrid(symbol)); diff != "" {
+		t.Errorf("symbol does not match the annex:\n%s", diff)
+	}
+}
+
+// formatGrid renders the modules one character each, # for the dark ones
+func formatGrid(symbol *matrix.Matrix[util.Module]) string {
+	var b strings.Builder
+	symbol.ForEachRow(func(row int, modules []util.Module) {
+		for _, module := range modules {
+			if util.IsModuleLighten(module) {
+				b.WriteByte('.')
+			} else {
+				b.WriteByte('#')
+			}
+		}
+		b.WriteByte('\n')
+	})
+	return b.String()
+}
+
+// diffGrids lists the rows of the grids which differ, with a marker under every module
+// which changed, or an empty string when the grids match
+func diffGrids(expected, actual string) string {
+	expectedRows := strings.Split(strings.TrimSuffix(expected, "\n"), "\n")
+	actualRows := strings.Split(strings.TrimSuffix(actual, "\n"), "\n")
+	if len(expectedRows) != len(actualRows) {
+		return fmt.Sprintf("expected %d rows, got %d", len(expectedRows), len(actualRows))
+	}
+
+	var b strings.Builder
+	changed := 0
+	for i := range expectedRows {
+		if expectedRows[i] == actualRows[i] {
+			continue
+		}
+
+		markers := []byte(strings.Repeat(" ", util.Max(len(expectedRows[i]), len(actualRows[i]))))
+		for j := range markers {
+			if j >= len(expectedRows[i]) || j >= len(actualRows[i]) || expectedRows[i][j] != actualRows[i][j] {
+				markers[j] = '^'
+				changed += 1
+			}
+		}
+
+		fmt.Fprintf(&b, "row %3d expected %s\n        actual   %s\n                 %s\n", i, expectedRows[i], actualRows[i], markers)
+	}
+
+	if changed == 0 {
+		return ""
+	}
+	return fmt.Sprintf("%d modules differ\n%s", changed, b.String())
+}
+
+func TestDiffGrids(t *testing.T) {
+	assert := assert.New(t)
+
+	assert.Empty(diffGrids("#.\n.#\n", "#.\n.#\n"), "matching grids should not differ")
+	assert.Equal("expected 2 rows, got 1", diffGrids("#.\n.#\n", "#.\n"), "diffs should match")
+	assert.Equal("1 modules differ\n"+
+		"row   1 expected .#\n"+
+		"        actual   ##\n"+
+		"                 ^ \n", diffGrids("#.\n.#\n", "#.\n##\n"), "diffs should match")
+}
@@ -0,0 +1,272 @@
+package generator
+
+import (
+	"fmt"
+	"io"
+	"qr/qr-gen/bitbuf"
+	"qr/qr-gen/compress"
+	"qr/qr-gen/encoder"
+	"qr/qr-gen/interleaver"
+	"qr/qr-gen/matrix"
+	"qr/qr-gen/moduler"
+	"qr/qr-gen/qrerr"
+	"qr/qr-gen/util"
+	"qr/qr-gen/versioner"
+)
+
+type Generator interface {
+	Generate(s string, lvl versioner.QrEcLevel) (*matrix.Matrix[util.Module], error)
+	GenerateByteMode(s string, lvl versioner.QrEcLevel, eci bool) (*matrix.Matrix[util.Module], error)
+	GenerateBytes(data []byte, lvl versioner.QrEcLevel) (*matrix.Matrix[util.Module], error)
+	GenerateFromReader(r io.Reader, lvl versioner.QrEcLevel) (*matrix.Matrix[util.Module], int, error)
+	GenerateCompressed(data []byte, lvl versioner.QrEcLevel, options compress.Options) (*matrix.Matrix[util.Module], *compress.Compressed, error)
+	DiagnoseMasks(s string, lvl versioner.QrEcLevel) (*moduler.MaskDiagnostics, error)
+}
+
+type QrGenerator struct {
+	versioner   versioner.Versioner
+	encoder     encoder.Encoder
+	interleaver interleaver.Interleaver
+	moduler     ModulerFactory
+	before      []Hook
+	after       []Hook
+}
+
+func New() Generator {
+	return NewWithOptions(moduler.DefaultOptions())
+}
+
+// NewWithOptions returns a generator building the symbols with the given moduler options,
+// such as the width of the quiet zone
+func NewWithOptions(options moduler.Options) Generator {
+	return NewPipelineBuilder().WithOptions(options).Build()
+}
+
+// NewWithMaskSelector returns a generator choosing the masks of the symbols with the given
+// selector, such as a fixed mask or the one best suited to a logo
+func NewWithMaskSelector(selector moduler.MaskSelector) Generator {
+	options := moduler.DefaultOptions()
+	options.Mask.Selector = selector
+	return NewWithOptions(options)
+}
+
+// Generate runs the whole pipeline on the input: the most compact mode and the smallest
+// version are selected, the input is encoded, augmented with error correction codewords
+// and placed in the module matrix of the symbol.
+func (g *QrGenerator) Generate(s string, lvl versioner.QrEcLevel) (*matrix.Matrix[util.Module], error) {
+	event, err := g.finalMessage(s, lvl)
+	if err != nil {
+		return nil, err
+	}
+
+	return g.createModuleMatrix(event)
+}
+
+// GenerateByteMode runs the pipeline with the input encoded in byte mode, as required by
+// formats which readers parse from the raw bytes, such as payment payloads. When eci is set,
+// the data is marked as UTF-8.
+func (g *QrGenerator) GenerateByteMode(s string, lvl versioner.QrEcLevel, eci bool) (*matrix.Matrix[util.Module], error) {
+	event := &StageEvent{Input: s, Level: lvl}
+
+	return g.generateByteMode(event, len(s), eci, func() (*bitbuf.Buffer, error) {
+		return g.encoder.EncodeByteModeBits(s, lvl, eci)
+	})
+}
+
+// GenerateBytes runs the pipeline on a binary payload, such as CBOR or compressed data,
+// always encoded in byte mode. The bytes are counted as they are, never as characters.
+func (g *QrGenerator) GenerateBytes(data []byte, lvl versioner.QrEcLevel) (*matrix.Matrix[util.Module], error) {
+	if len(data) == 0 {
+		return nil, qrerr.ErrEmptyInput
+	}
+
+	event := &StageEvent{Payload: data, Level: lvl}
+
+	return g.generateByteMode(event, len(data), false, func() (*bitbuf.Buffer, error) {
+		return g.encoder.EncodeBytes(data, lvl)
+	})
+}
+
+// GenerateFromReader reads a binary payload up to the capacity of the largest symbol of the
+// level and generates its symbol as GenerateBytes does. The number of bytes read is
+// returned, the rest of a longer payload being left in the reader for the next symbols. When
+// the reading fails, nothing is encoded and 0 is returned, even if some bytes were consumed.
+func (g *QrGenerator) GenerateFromReader(r io.Reader, lvl versioner.QrEcLevel) (*matrix.Matrix[util.Module], int, error) {
+	capacity, err := g.versioner.GetSegmentsCapacity(nil)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	data, err := io.ReadAll(io.LimitReader(r, int64(capacity.RemainingCharacters(versioner.MaxQrVersion, lvl, versioner.QrByteMode))))
+	if err != nil {
+		return nil, 0, fmt.Errorf("Error on reading the input: %w", err)
+	}
+
+	symbol, err := g.GenerateBytes(data, lvl)
+	return symbol, len(data), err
+}
+
+// GenerateCompressed runs the pipeline on the variant of the data needing the smallest
+// version, compressed or not, as selected by compress.Compress. Readers get the data back
+// with compress.Decompress.
+func (g *QrGenerator) GenerateCompressed(data []byte, lvl versioner.QrEcLevel, options compress.Options) (*matrix.Matrix[util.Module], *compress.Compressed, error) {
+	compressed, err := compress.Compress(data, lvl, options)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	var symbol *matrix.Matrix[util.Module]
+	if compressed.Mode == versioner.QrByteMode {
+		symbol, err = g.GenerateBytes(compressed.Data, lvl)
+	} else {
+		symbol, err = g.Generate(string(compressed.Data), lvl)
+	}
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return symbol, compressed, nil
+}
+
+// Runs the pipeline on the bytes of the event, encoded in byte mode by the encode function
+func (g *QrGenerator) generateByteMode(event *StageEvent, length int, eci bool, encode func() (*bitbuf.Buffer, error)) (*matrix.Matrix[util.Module], error) {
+	err := g.runStage(event, Stage_VERSION, func() error {
+		version, err := g.versioner.GetByteLengthVersion(length, event.Level, eci)
+		if err != nil {
+			return fmt.Errorf("Error on computing the encoding version: %w", err)
+		}
+
+		event.Version = version
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	err = g.runStage(event, Stage_ENCODE, func() error {
+		encoded, err := encode()
+		if err != nil {
+			return err
+		}
+
+		return g.augment(event, encoded)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	if err := g.interleave(event); err != nil {
+		return nil, err
+	}
+
+	return g.createModuleMatrix(event)
+}
+
+// DiagnoseMasks runs the pipeline as Generate does, but reports the symbol built with each
+// of the eight masks and their penalty scores rather than the selected symbol only
+func (g *QrGenerator) DiagnoseMasks(s string, lvl versioner.QrEcLevel) (*moduler.MaskDiagnostics, error) {
+	event, err := g.finalMessage(s, lvl)
+	if err != nil {
+		return nil, err
+	}
+
+	var diagnostics *moduler.MaskDiagnostics
+	err = g.runStage(event, Stage_MODULE, func() error {
+		diagnostics, err = g.moduler(event.Version, lvl).DiagnoseMasks(event.Data)
+		if err != nil {
+			return fmt.Errorf("Error on placing the modules: %w", err)
+		}
+
+		event.Symbol = diagnostics.SelectedCandidate().Symbol
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return diagnostics, nil
+}
+
+// Encodes the input in the most compact mode and computes the final message of the
+// smallest version holding it
+func (g *QrGenerator) finalMessage(s string, lvl versioner.QrEcLevel) (*StageEvent, error) {
+	event := &StageEvent{Input: s, Level: lvl}
+
+	var mode versioner.QrMode
+	err := g.runStage(event, Stage_VERSION, func() error {
+		var err error
+		mode, err = g.versioner.GetMode(s)
+		if err != nil {
+			return fmt.Errorf("Error on computing the encoding mode: %w", err)
+		}
+
+		version, err := g.versioner.GetVersion(s, mode, lvl)
+		if err != nil {
+			return fmt.Errorf("Error on computing the encoding version: %w", err)
+		}
+
+		event.Version = version
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	// The data is encoded for the version of the stage, so that the count indicator and the
+	// padding match the symbol even when the encoder would select another version
+	err = g.runStage(event, Stage_ENCODE, func() error {
+		encoded, err := g.encoder.EncodeVersionBits(s, mode, event.Version)
+		if err != nil {
+			return err
+		}
+
+		return g.augment(event, encoded)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	if err := g.interleave(event); err != nil {
+		return nil, err
+	}
+
+	return event, nil
+}
+
+func (g *QrGenerator) augment(event *StageEvent, encoded *bitbuf.Buffer) error {
+	if err := g.encoder.AugmentEncodedBits(encoded, event.Version, event.Level); err != nil {
+		return fmt.Errorf("Error on augmenting the encoded data: %w", err)
+	}
+
+	event.Data = encoded
+	return nil
+}
+
+func (g *QrGenerator) interleave(event *StageEvent) error {
+	return g.runStage(event, Stage_INTERLEAVE, func() error {
+		data, err := g.interleaver.GetFinalMessageBits(event.Data, event.Version, event.Level)
+		if err != nil {
+			return fmt.Errorf("Error on computing the final message: %w", err)
+		}
+
+		event.Data = data
+		return nil
+	})
+}
+
+func (g *QrGenerator) createModuleMatrix(event *StageEvent) (*matrix.Matrix[util.Module], error) {
+	err := g.runStage(event, Stage_MODULE, func() error {
+		matrix, _, err := g.moduler(event.Version, event.Level).CreateModuleMatrixFromBits(event.Data)
+		if err != nil {
+			return fmt.Errorf("Error on placing the modules: %w", err)
+		}
+
+		event.Symbol = matrix
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return event.Symbol, nil
+}
@@ -0,0 +1,309 @@
+package generator
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"qr/qr-gen/compress"
+	"qr/qr-gen/decoder"
+	"qr/qr-gen/encoder"
+	"qr/qr-gen/interleaver"
+	"qr/qr-gen/matrix"
+	"qr/qr-gen/moduler"
+	"qr/qr-gen/qrerr"
+	"qr/qr-gen/util"
+	"qr/qr-gen/versioner"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestGenerate(t *testing.T) {
+	assert := assert.New(t)
+	g := New()
+
+	tests := []struct {
+		input    string
+		lvl      versioner.QrEcLevel
+		expected int
+	}{
+		{"1", versioner.QrEcLow, 21},
+		{"HELLO WORLD", versioner.QrEcQuartile, 21},
+		{"https://www.qrcode.com/", versioner.QrEcMedium, 25},
+		{strings.Repeat("A", 62), versioner.QrEcMedium, 33},
+		{strings.Repeat("a", 40), versioner.QrECHigh, 37},
+	}
+
+	for _, test := range tests {
+		matrix, err := g.Generate(test.input, test.lvl)
+		assert.NoError(err)
+		assert.Equal(test.expected+8, len(matrix.GetMatrix()), "symbol sizes should match")
+	}
+
+	_, err := g.Generate(strings.Repeat("a", 1274), versioner.QrECHigh)
+	assert.Error(err, "input should not fit in any supported version")
+}
+
+func TestGenerateErrors(t *testing.T) {
+	assert := assert.New(t)
+	g := New()
+
+	_, err := g.Generate(strings.Repeat("a", 1274), versioner.QrECHigh)
+	var tooLong *qrerr.ErrDataTooLong
+	if assert.ErrorAs(err, &tooLong, "errors should be wrapped by the generator") {
+		assert.Equal(qrerr.ErrDataTooLong{Needed: 4 + 16 + 1274*8, Max: 10208, Level: 'H'}, *tooLong, "error details should match")
+	}
+	assert.EqualError(err, "Error on computing the encoding version: Data too long: 10212 bits needed, 10208 bits available at level H", "error messages should match")
+
+	_, err = g.Generate("日本", versioner.QrEcLow)
+	var invalid *qrerr.ErrInvalidCharacter
+	if assert.ErrorAs(err, &invalid, "errors should be wrapped by the generator") {
+		assert.Equal(qrerr.ErrInvalidCharacter{Pos: 0, Rune: '日', Mode: "byte"}, *invalid, "error details should match")
+	}
+
+	_, err = g.Generate("", versioner.QrEcLow)
+	assert.ErrorIs(err, qrerr.ErrEmptyInput)
+
+	_, err = g.Generate("HELLO", 'X')
+	assert.ErrorIs(err, qrerr.ErrInvalidLevel)
+
+	_, err = g.GenerateByteMode(strings.Repeat("a", 2954), versioner.QrEcLow, false)
+	assert.ErrorIs(err, qrerr.ErrCapacityExceeded)
+}
+
+func TestGenerateByteMode(t *testing.T) {
+	assert := assert.New(t)
+	g := New()
+
+	// 17 digits fill a version 1 symbol in byte mode, the ECI header needs a larger one
+	matrix, err := g.GenerateByteMode(strings.Repeat("1", 17), versioner.QrEcLow, false)
+	assert.NoError(err)
+	assert.Equal(21+8, len(matrix.GetMatrix()), "symbol sizes should match")
+
+	matrix, err = g.GenerateByteMode(strings.Repeat("1", 17), versioner.QrEcLow, true)
+	assert.NoError(err)
+	assert.Equal(25+8, len(matrix.GetMatrix()), "symbol sizes should match")
+
+	matrix, err = g.GenerateByteMode("Zoë\nŁódź", versioner.QrEcMedium, true)
+	assert.NoError(err)
+	assert.NotNil(matrix)
+
+	_, err = g.GenerateByteMode(strings.Repeat("a", 2954), versioner.QrEcLow, false)
+	assert.Error(err, "input should not fit in any supported version")
+}
+
+func TestGenerateAllLengths(t *testing.T) {
+	assert := assert.New(t)
+	g := New()
+
+	for _, lvl := range []versioner.QrEcLevel{versioner.QrEcLow, versioner.QrEcMedium, versioner.QrEcQuartile, versioner.QrECHigh} {
+		for _, char := range []string{"7", "Z", "z"} {
+			for n := 1; n <= 106; n++ {
+				assert.NotPanics(func() { g.Generate(strings.Repeat(char, n), lvl) }, "generation should not panic for %d characters", n)
+			}
+		}
+	}
+}
+
+// Generates thousands of codes from concurrent goroutines sharing the same generator and compares
+// them with the serial output, run with -race to detect shared state between generations
+func TestConcurrentGeneration(t *testing.T) {
+	assert := assert.New(t)
+	g := New()
+	levels := []versioner.QrEcLevel{versioner.QrEcLow, versioner.QrEcMedium, versioner.QrEcQuartile, versioner.QrECHigh}
+
+	count := 2000
+	if testing.Short() {
+		count = 200
+	}
+
+	inputs := make([]string, 100)
+	expected := make([][][]util.Module, len(inputs))
+	for i := range inputs {
+		inputs[i] = strings.Repeat(strconv.Itoa(i)+"qr", 1+i%10)
+		matrix, err := g.Generate(inputs[i], levels[i%len(levels)])
+		assert.NoError(err)
+		expected[i] = matrix.GetMatrix()
+	}
+
+	actual := make([][][]util.Module, count)
+	var wg sync.WaitGroup
+	for n := 0; n < count; n++ {
+		wg.Add(1)
+		go func(n int) {
+			defer wg.Done()
+			i := n % len(inputs)
+			if matrix, err := g.Generate(inputs[i], levels[i%len(levels)]); err == nil {
+				actual[n] = matrix.GetMatrix()
+			}
+		}(n)
+	}
+	wg.Wait()
+
+	for n := range actual {
+		assert.Equal(expected[n%len(inputs)], actual[n], "concurrent and serial symbols should match")
+	}
+}
+
+// Runs the pipeline through the string based adapters of every stage
+func generateWithStrings(s string, lvl versioner.QrEcLevel) *matrix.Matrix[util.Module] {
+	v := versioner.New()
+	mode, _ := v.GetMode(s)
+	version, _ := v.GetVersion(s, mode, lvl)
+
+	e := encoder.New()
+	encoded, _ := e.Encode(s, lvl)
+	encoded, _ = e.AugmentEncodedInput(encoded, version, lvl)
+
+	data, _ := interleaver.New().GetFinalMessage(encoded, version, lvl)
+	matrix, _, _ := moduler.New(version, lvl).CreateModuleMatrix(data)
+
+	return matrix
+}
+
+func TestStringAdapters(t *testing.T) {
+	assert := assert.New(t)
+	g := New()
+
+	for _, lvl := range []versioner.QrEcLevel{versioner.QrEcLow, versioner.QrEcMedium, versioner.QrEcQuartile, versioner.QrECHigh} {
+		for _, input := range []string{"01234567", "HELLO WORLD", "https://www.qrcode.com/", strings.Repeat("a\n", 20)} {
+			expected, err := g.Generate(input, lvl)
+			assert.NoError(err)
+			assert.Equal(expected.GetMatrix(), generateWithStrings(input, lvl).GetMatrix(), "both pipelines should produce the same symbol")
+		}
+	}
+}
+
+func TestDiagnoseMasks(t *testing.T) {
+	assert := assert.New(t)
+	g := New()
+
+	symbol, _ := g.Generate("https://www.qrcode.com/", versioner.QrEcMedium)
+	diagnostics, err := g.DiagnoseMasks("https://www.qrcode.com/", versioner.QrEcMedium)
+	assert.NoError(err)
+	assert.Len(diagnostics.Candidates, 8, "every mask should be reported")
+	assert.Equal(symbol.GetMatrix(), diagnostics.SelectedCandidate().Symbol.GetMatrix(), "selected candidate should be the generated symbol")
+
+	_, err = g.DiagnoseMasks("", versioner.QrEcMedium)
+	assert.ErrorIs(err, qrerr.ErrEmptyInput)
+}
+
+func TestGenerateBytes(t *testing.T) {
+	assert := assert.New(t)
+	g := New()
+
+	payload := []byte{0xd9, 0xd9, 0xf7, 0xa1, 0x00, 0xff, 0xfe, 'q', 'r'}
+	symbol, err := g.GenerateBytes(payload, versioner.QrEcMedium)
+	assert.NoError(err)
+
+	result, err := decoder.New().Decode(symbol)
+	assert.NoError(err)
+	assert.Equal(payload, result.Data, "decoded bytes should match")
+	assert.Equal([]versioner.Segment{{Data: string(payload), Mode: versioner.QrByteMode}}, result.Segments, "payload should be a single byte mode segment")
+
+	// Digits are still encoded in byte mode
+	symbol, _ = g.GenerateBytes([]byte("0123456789"), versioner.QrEcMedium)
+	result, _ = decoder.New().Decode(symbol)
+	assert.Equal(versioner.QrByteMode, result.Segments[0].Mode, "modes should match")
+
+	_, err = g.GenerateBytes(nil, versioner.QrEcMedium)
+	assert.ErrorIs(err, qrerr.ErrEmptyInput)
+
+	_, err = g.GenerateBytes(make([]byte, 2332), versioner.QrEcMedium)
+	assert.ErrorIs(err, qrerr.ErrCapacityExceeded)
+}
+
+func TestGenerateCompressed(t *testing.T) {
+	assert := assert.New(t)
+	g := New()
+
+	tests := []struct {
+		data    []byte
+		variant compress.Variant
+	}{
+		{[]byte(strings.Repeat("https://example.com/catalogue?item=42&colour=blue ", 30)), compress.Variant_DEFLATE},
+		{[]byte("HELLO WORLD"), compress.Variant_RAW},
+	}
+
+	for _, test := range tests {
+		symbol, compressed, err := g.GenerateCompressed(test.data, versioner.QrEcQuartile, compress.Options{Base45: true})
+		if !assert.NoError(err) {
+			continue
+		}
+		assert.Equal(test.variant, compressed.Variant, "variants should match")
+
+		result, err := decoder.New().Decode(symbol)
+		assert.NoError(err)
+		assert.Equal(compressed.Version, result.Version, "versions should match")
+
+		data, err := result.Decompress()
+		assert.NoError(err)
+		assert.Equal(test.data, data, "decompressed data should match")
+	}
+
+	_, _, err := g.GenerateCompressed(nil, versioner.QrEcLow, compress.Options{})
+	assert.ErrorIs(err, qrerr.ErrEmptyInput)
+}
+
+type failingReader struct{}
+
+func (r failingReader) Read(p []byte) (int, error) {
+	return 0, errors.New("broken pipe")
+}
+
+func TestGenerateFromReader(t *testing.T) {
+	assert := assert.New(t)
+	g := New()
+
+	payload := make([]byte, 5000)
+	for i := range payload {
+		payload[i] = byte(i * 7)
+	}
+
+	// The largest symbol of level M holds 2331 bytes, the rest is left in the reader
+	r := bytes.NewReader(payload)
+	symbol, n, err := g.GenerateFromReader(r, versioner.QrEcMedium)
+	assert.NoError(err)
+	assert.Equal(2331, n, "reader should be read up to the capacity")
+	assert.Equal(5000-2331, r.Len(), "rest of the payload should be left in the reader")
+
+	result, err := decoder.New().Decode(symbol)
+	assert.NoError(err)
+	assert.Equal(payload[:n], result.Data, "decoded bytes should match")
+	assert.Equal(versioner.QrVersion(40), result.Version, "versions should match")
+
+	symbol, n, err = g.GenerateFromReader(bytes.NewReader(payload[:20]), versioner.QrEcLow)
+	assert.NoError(err)
+	assert.Equal(20, n, "short payloads should be read entirely")
+	result, _ = decoder.New().Decode(symbol)
+	assert.Equal(versioner.QrVersion(2), result.Version, "smallest version should be selected")
+
+	_, _, err = g.GenerateFromReader(bytes.NewReader(nil), versioner.QrEcLow)
+	assert.ErrorIs(err, qrerr.ErrEmptyInput)
+
+	_, _, err = g.GenerateFromReader(failingReader{}, versioner.QrEcLow)
+	assert.EqualError(err, "Error on reading the input: broken pipe", "error messages should match")
+
+	// The bytes read before the failure are not encoded, hence not counted
+	symbol, n, err = g.GenerateFromReader(io.MultiReader(bytes.NewReader(payload[:20]), failingReader{}), versioner.QrEcLow)
+	assert.EqualError(err, "Error on reading the input: broken pipe", "error messages should match")
+	assert.Nil(symbol, "no symbol should be generated")
+	assert.Zero(n, "no bytes should be reported as encoded")
+}
+
+func BenchmarkGenerate(b *testing.B) {
+	g := New()
+
+	for n := 0; n < b.N; n++ {
+		g.Generate("https://www.qrcode.com/", versioner.QrEcMedium)
+	}
+}
+
+func BenchmarkGenerateWithStrings(b *testing.B) {
+	for n := 0; n < b.N; n++ {
+		generateWithStrings("https://www.qrcode.com/", versioner.QrEcMedium)
+	}
+}
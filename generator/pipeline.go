@@ -0,0 +1,180 @@
+package generator
+
+import (
+	"qr/qr-gen/bitbuf"
+	"qr/qr-gen/ec"
+	"qr/qr-gen/encoder"
+	"qr/qr-gen/interleaver"
+	"qr/qr-gen/matrix"
+	"qr/qr-gen/moduler"
+	"qr/qr-gen/util"
+	"qr/qr-gen/versioner"
+	"time"
+)
+
+// Stage is a step of the generation pipeline
+type Stage int
+
+const (
+	// Stage_VERSION selects the mode and the smallest version holding the input
+	Stage_VERSION Stage = iota
+	// Stage_ENCODE encodes the input and augments it up to the capacity of the symbol
+	Stage_ENCODE
+	// Stage_INTERLEAVE computes the error correction codewords and interleaves the blocks
+	Stage_INTERLEAVE
+	// Stage_MODULE places the final message in the symbol and masks it
+	Stage_MODULE
+)
+
+var stageNames = map[Stage]string{
+	Stage_VERSION:    "version",
+	Stage_ENCODE:     "encode",
+	Stage_INTERLEAVE: "interleave",
+	Stage_MODULE:     "module",
+}
+
+func (s Stage) String() string {
+	if name, ok := stageNames[s]; ok {
+		return name
+	}
+	return "unknown"
+}
+
+// StageEvent describes the generation to the hooks of a stage. The hooks run before a stage
+// see the results of the previous stages, the ones run after it its own results.
+type StageEvent struct {
+	Stage Stage
+	// Input is the text of the text entry points, Payload the bytes of the binary ones
+	Input   string
+	Payload []byte
+	Level   versioner.QrEcLevel
+	// Version is set from the version stage on
+	Version versioner.QrVersion
+	// Data is the encoded data, then the final message from the interleaving stage on. It
+	// is shared with the pipeline and must not be modified.
+	Data   *bitbuf.Buffer
+	Symbol *matrix.Matrix[util.Module]
+	// Duration and Err are set for the hooks run after the stage
+	Duration time.Duration
+	Err      error
+}
+
+// Hook is called before or after the stages, for logging, metrics or tracing
+type Hook func(event StageEvent)
+
+// ModulerFactory returns the moduler of the symbols of a version and level
+type ModulerFactory func(version versioner.QrVersion, lvl versioner.QrEcLevel) moduler.ModulerInterface
+
+// PipelineBuilder assembles a generator from the implementations of its stages. The stages
+// left unset use the default implementations.
+type PipelineBuilder struct {
+	versioner   versioner.Versioner
+	encoder     encoder.Encoder
+	corrector   ec.ErrorCorrector
+	interleaver interleaver.Interleaver
+	moduler     ModulerFactory
+	options     moduler.Options
+	before      []Hook
+	after       []Hook
+}
+
+func NewPipelineBuilder() *PipelineBuilder {
+	return &PipelineBuilder{options: moduler.DefaultOptions()}
+}
+
+// WithVersioner sets the versioner, which the default encoder uses too
+func (b *PipelineBuilder) WithVersioner(v versioner.Versioner) *PipelineBuilder {
+	b.versioner = v
+	return b
+}
+
+func (b *PipelineBuilder) WithEncoder(e encoder.Encoder) *PipelineBuilder {
+	b.encoder = e
+	return b
+}
+
+// WithErrorCorrector sets the error corrector used by the default interleaver
+func (b *PipelineBuilder) WithErrorCorrector(corrector ec.ErrorCorrector) *PipelineBuilder {
+	b.corrector = corrector
+	return b
+}
+
+func (b *PipelineBuilder) WithInterleaver(i interleaver.Interleaver) *PipelineBuilder {
+	b.interleaver = i
+	return b
+}
+
+// WithModuler sets the factory of the modulers, which replaces the moduler options
+func (b *PipelineBuilder) WithModuler(factory ModulerFactory) *PipelineBuilder {
+	b.moduler = factory
+	return b
+}
+
+// WithOptions sets the options of the default moduler
+func (b *PipelineBuilder) WithOptions(options moduler.Options) *PipelineBuilder {
+	b.options = options
+	return b
+}
+
+// BeforeStage adds a hook called before every stage, in the order of addition
+func (b *PipelineBuilder) BeforeStage(hook Hook) *PipelineBuilder {
+	b.before = append(b.before, hook)
+	return b
+}
+
+// AfterStage adds a hook called after every stage, failed ones included
+func (b *PipelineBuilder) AfterStage(hook Hook) *PipelineBuilder {
+	b.after = append(b.after, hook)
+	return b
+}
+
+func (b *PipelineBuilder) Build() Generator {
+	g := &QrGenerator{
+		versioner:   b.versioner,
+		encoder:     b.encoder,
+		interleaver: b.interleaver,
+		moduler:     b.moduler,
+		before:      append([]Hook(nil), b.before...),
+		after:       append([]Hook(nil), b.after...),
+	}
+
+	if g.versioner == nil {
+		g.versioner = versioner.New()
+	}
+	if g.encoder == nil {
+		g.encoder = encoder.NewWithVersioner(g.versioner)
+	}
+	if g.interleaver == nil {
+		corrector := b.corrector
+		if corrector == nil {
+			corrector = ec.New()
+		}
+		g.interleaver = interleaver.NewWithErrorCorrector(corrector)
+	}
+	if g.moduler == nil {
+		options := b.options
+		g.moduler = func(version versioner.QrVersion, lvl versioner.QrEcLevel) moduler.ModulerInterface {
+			return moduler.NewWithOptions(version, lvl, options)
+		}
+	}
+
+	return g
+}
+
+// Runs a stage between its hooks, the stage updating the event with its results
+func (g *QrGenerator) runStage(event *StageEvent, stage Stage, run func() error) error {
+	event.Stage, event.Duration, event.Err = stage, 0, nil
+	for _, hook := range g.before {
+		hook(*event)
+	}
+
+	start := time.Now()
+	err := run()
+	event.Duration, event.Err = time.Since(start), err
+
+	for _, hook := range g.after {
+		hook(*event)
+	}
+
+	return err
+}
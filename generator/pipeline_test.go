@@ -0,0 +1,156 @@
+package generator
+
+import (
+	"errors"
+	"qr/qr-gen/bitbuf"
+	"qr/qr-gen/decoder"
+	"qr/qr-gen/ec"
+	"qr/qr-gen/encoder"
+	"qr/qr-gen/matrix"
+	"qr/qr-gen/moduler"
+	"qr/qr-gen/qrerr"
+	"qr/qr-gen/util"
+	"qr/qr-gen/versioner"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestPipelineHooks(t *testing.T) {
+	assert := assert.New(t)
+
+	var before, after []StageEvent
+	g := NewPipelineBuilder().
+		BeforeStage(func(event StageEvent) { before = append(before, event) }).
+		AfterStage(func(event StageEvent) { after = append(after, event) }).
+		Build()
+
+	symbol, err := g.Generate("HELLO WORLD", versioner.QrEcQuartile)
+	assert.NoError(err)
+
+	stages := []Stage{Stage_VERSION, Stage_ENCODE, Stage_INTERLEAVE, Stage_MODULE}
+	if assert.Len(before, len(stages)) && assert.Len(after, len(stages)) {
+		for i, stage := range stages {
+			assert.Equal(stage, before[i].Stage, "hooks should run before every stage in order")
+			assert.Equal(stage, after[i].Stage, "hooks should run after every stage in order")
+			assert.Equal("HELLO WORLD", after[i].Input, "inputs should match")
+			assert.NoError(after[i].Err)
+		}
+	}
+
+	assert.Zero(before[0].Version, "version should not be known before its stage")
+	assert.Equal(versioner.QrVersion(1), after[0].Version, "versions should match")
+	assert.Nil(before[1].Data, "data should not be encoded before its stage")
+	assert.Equal(13*8, after[1].Data.Len(), "encoded data should fill the symbol")
+	assert.Equal(26*8, after[2].Data.Len(), "final message should hold the error correction codewords")
+	assert.Same(symbol, after[3].Symbol, "symbol should be reported")
+	assert.Equal("module", Stage_MODULE.String(), "stage names should match")
+}
+
+func TestPipelineHooksOnError(t *testing.T) {
+	assert := assert.New(t)
+
+	var after []StageEvent
+	g := NewPipelineBuilder().AfterStage(func(event StageEvent) { after = append(after, event) }).Build()
+
+	_, err := g.Generate("", versioner.QrEcLow)
+	assert.ErrorIs(err, qrerr.ErrEmptyInput)
+	if assert.Len(after, 1, "the pipeline should stop at the failed stage") {
+		assert.Equal(Stage_VERSION, after[0].Stage, "stages should match")
+		assert.ErrorIs(after[0].Err, qrerr.ErrEmptyInput, "error should be reported to the hooks")
+	}
+}
+
+// Selects a fixed version for every input
+type fixedVersioner struct {
+	versioner.Versioner
+	version versioner.QrVersion
+}
+
+func (v *fixedVersioner) GetVersion(s string, mode versioner.QrMode, lvl versioner.QrEcLevel) (versioner.QrVersion, error) {
+	return v.version, nil
+}
+
+// Counts the blocks whose error correction codewords are computed
+type countingCorrector struct {
+	ec.ErrorCorrector
+	blocks int
+}
+
+func (c *countingCorrector) GetErrorCorrectionBytes(data []byte, degree int) ([]byte, error) {
+	c.blocks += 1
+	return c.ErrorCorrector.GetErrorCorrectionBytes(data, degree)
+}
+
+func TestPipelineStages(t *testing.T) {
+	assert := assert.New(t)
+
+	corrector := &countingCorrector{ErrorCorrector: ec.New()}
+	g := NewPipelineBuilder().
+		WithVersioner(&fixedVersioner{Versioner: versioner.New(), version: 5}).
+		WithErrorCorrector(corrector).
+		Build()
+
+	symbol, err := g.Generate("HELLO WORLD", versioner.QrEcQuartile)
+	assert.NoError(err)
+	assert.Equal(versioner.QrVersion(5).Size()+2*moduler.DefaultQuietZone, symbol.Width(), "injected versioner should select the version")
+	assert.Equal(4, corrector.blocks, "injected error corrector should compute every block")
+
+	result, err := decoder.New().Decode(symbol)
+	assert.NoError(err)
+	assert.Equal("HELLO WORLD", string(result.Data), "symbol should still be readable")
+
+	var versions []versioner.QrVersion
+	g = NewPipelineBuilder().WithModuler(func(version versioner.QrVersion, lvl versioner.QrEcLevel) moduler.ModulerInterface {
+		versions = append(versions, version)
+		return moduler.NewWithOptions(version, lvl, moduler.Options{})
+	}).Build()
+
+	symbol, err = g.Generate("HELLO WORLD", versioner.QrEcQuartile)
+	assert.NoError(err)
+	assert.Equal([]versioner.QrVersion{1}, versions, "injected moduler should be used")
+	assert.Equal(21, symbol.Width(), "injected moduler should place the modules")
+}
+
+// The encoder has its own versioner, which would select version 1 and its shorter count
+// indicator: the data is still encoded for the version of the injected versioner
+func TestPipelineInjectedVersion(t *testing.T) {
+	assert := assert.New(t)
+
+	var version versioner.QrVersion
+	g := NewPipelineBuilder().
+		WithVersioner(&fixedVersioner{Versioner: versioner.New(), version: 10}).
+		WithEncoder(encoder.New()).
+		AfterStage(func(event StageEvent) { version = event.Version }).
+		Build()
+
+	symbol, err := g.Generate("HELLO WORLD", versioner.QrEcQuartile)
+	assert.NoError(err)
+	assert.Equal(versioner.QrVersion(10), version, "reported versions should match")
+
+	result, err := decoder.New().Decode(symbol)
+	if assert.NoError(err) {
+		assert.Equal(versioner.QrVersion(10), result.Version, "versions should match")
+		assert.Equal("HELLO WORLD", string(result.Data), "decoded data should match")
+	}
+}
+
+// Fails to place the modules of any symbol
+type failingModuler struct {
+	moduler.ModulerInterface
+}
+
+func (m *failingModuler) CreateModuleMatrixFromBits(data *bitbuf.Buffer) (*matrix.Matrix[util.Module], moduler.Penalty, error) {
+	return nil, moduler.Penalty{}, errors.New("no room")
+}
+
+func TestPipelineStageErrors(t *testing.T) {
+	assert := assert.New(t)
+
+	g := NewPipelineBuilder().WithModuler(func(version versioner.QrVersion, lvl versioner.QrEcLevel) moduler.ModulerInterface {
+		return &failingModuler{}
+	}).Build()
+
+	_, err := g.Generate("HELLO WORLD", versioner.QrEcQuartile)
+	assert.EqualError(err, "Error on placing the modules: no room", "error messages should match")
+}
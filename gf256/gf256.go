@@ -0,0 +1,147 @@
+package gf256
+
+import (
+	"fmt"
+	"qr/qr-gen/qrerr"
+	"sync"
+)
+
+// Primitive polynomial of the field used by QR codes, x^8 + x^4 + x^3 + x^2 + 1
+const primitivePolynomial = 285
+
+// Order of the multiplicative group, the exponents of the generator element repeat after it
+const order = 255
+
+// The tables are computed once, when the package is initialized, and only read afterwards,
+// so they are safe for concurrent use. The exponent table is doubled to spare the modulo
+// when multiplying.
+var expTable [2 * order]byte
+var logTable [256]int
+
+var generatorCache = struct {
+	sync.Mutex
+	polynomials map[int][]byte
+}{polynomials: make(map[int][]byte)}
+
+func init() {
+	value := 1
+
+	for i := 0; i < order; i++ {
+		expTable[i] = byte(value)
+		expTable[i+order] = byte(value)
+		logTable[value] = i
+
+		value <<= 1
+		if value >= 256 {
+			value ^= primitivePolynomial
+		}
+	}
+}
+
+// Exp returns the generator element 2 raised to the power n
+func Exp(n int) byte {
+	n %= order
+	if n < 0 {
+		n += order
+	}
+	return expTable[n]
+}
+
+// Log returns the exponent of the generator element equal to v. Zero has no logarithm,
+// so -1 is returned for it.
+func Log(v byte) int {
+	if v == 0 {
+		return -1
+	}
+	return logTable[v]
+}
+
+// Mul multiplies two elements of the field
+func Mul(a, b byte) byte {
+	if a == 0 || b == 0 {
+		return 0
+	}
+	return expTable[logTable[a]+logTable[b]]
+}
+
+// Div divides a by the non zero element b
+func Div(a, b byte) byte {
+	if b == 0 {
+		panic("gf256: division by zero")
+	}
+	if a == 0 {
+		return 0
+	}
+	return expTable[logTable[a]+order-logTable[b]]
+}
+
+// GeneratorPolynomial returns the coefficients, from the highest degree term, of the
+// Reed-Solomon generator polynomial (x - 2^0)(x - 2^1)...(x - 2^(degree-1)).
+// The polynomials are cached per degree and shared, so they must not be modified.
+func GeneratorPolynomial(degree int) []byte {
+	generatorCache.Lock()
+	defer generatorCache.Unlock()
+
+	if polynomial, ok := generatorCache.polynomials[degree]; ok {
+		return polynomial
+	}
+
+	polynomial := []byte{1}
+	for i := 0; i < degree; i++ {
+		// Multiplies by (x + 2^i), subtraction and addition being the same operation
+		next := make([]byte, len(polynomial)+1)
+		for j, coefficient := range polynomial {
+			next[j] ^= coefficient
+			next[j+1] ^= Mul(coefficient, Exp(i))
+		}
+		polynomial = next
+	}
+
+	generatorCache.polynomials[degree] = polynomial
+	return polynomial
+}
+
+// Encoder computes Reed-Solomon error correction codewords of a fixed degree
+type Encoder struct {
+	generator []byte
+}
+
+// NewEncoder returns an encoder of the given degree, which must be at least one
+func NewEncoder(degree int) (*Encoder, error) {
+	if degree < 1 {
+		return nil, fmt.Errorf("%w: error correction degree %d", qrerr.ErrInvalidInput, degree)
+	}
+	return &Encoder{generator: GeneratorPolynomial(degree)}, nil
+}
+
+// Encode returns the error correction codewords of the data codewords, from the highest
+// degree term. The remainder of the polynomial division is computed with a linear feedback
+// shift register, each data codeword shifting it by one position.
+func (e *Encoder) Encode(data []byte) []byte {
+	degree := len(e.generator) - 1
+	remainder := make([]byte, degree)
+
+	for _, codeword := range data {
+		factor := codeword ^ remainder[0]
+		copy(remainder, remainder[1:])
+		remainder[degree-1] = 0
+
+		if factor == 0 {
+			continue
+		}
+		for j := 0; j < degree; j++ {
+			remainder[j] ^= Mul(e.generator[j+1], factor)
+		}
+	}
+
+	return remainder
+}
+
+// Encode computes the given number of error correction codewords of the data codewords
+func Encode(data []byte, degree int) ([]byte, error) {
+	e, err := NewEncoder(degree)
+	if err != nil {
+		return nil, err
+	}
+	return e.Encode(data), nil
+}
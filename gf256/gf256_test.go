@@ -0,0 +1,150 @@
+package gf256
+
+import (
+	"errors"
+	"qr/qr-gen/qrerr"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestTables(t *testing.T) {
+	assert := assert.New(t)
+
+	assert.Equal(byte(1), Exp(0), "values should match")
+	assert.Equal(byte(29), Exp(8), "values should match")
+	assert.Equal(byte(142), Exp(254), "values should match")
+	assert.Equal(byte(1), Exp(255), "exponents should wrap around")
+
+	for v := 1; v < 256; v++ {
+		assert.Equal(byte(v), Exp(Log(byte(v))), "tables should be inverse of each other")
+	}
+	assert.Equal(-1, Log(0), "zero should have no logarithm")
+}
+
+func TestMulAndDiv(t *testing.T) {
+	assert := assert.New(t)
+
+	assert.Equal(byte(0), Mul(0, 17), "values should match")
+	assert.Equal(byte(Exp(9)), Mul(Exp(4), Exp(5)), "values should match")
+	assert.Equal(byte(Exp(3)), Mul(Exp(200), Exp(58)), "values should match")
+
+	for a := 0; a < 256; a++ {
+		for b := 1; b < 256; b++ {
+			if Div(Mul(byte(a), byte(b)), byte(b)) != byte(a) {
+				t.Fatalf("division should invert the multiplication of %d by %d", a, b)
+			}
+		}
+	}
+
+	assert.Panics(func() { Div(1, 0) }, "division by zero should panic")
+}
+
+func TestGeneratorPolynomial(t *testing.T) {
+	assert := assert.New(t)
+
+	assert.Equal([]byte{1, 3, 2}, GeneratorPolynomial(2), "coefficients should match")
+
+	expected := []byte{1, 216, 194, 159, 111, 199, 94, 95, 113, 157, 193}
+	assert.Equal(expected, GeneratorPolynomial(10), "coefficients should match")
+	assert.Equal(expected, GeneratorPolynomial(10), "cached coefficients should match")
+}
+
+func TestEncode(t *testing.T) {
+	assert := assert.New(t)
+
+	// Data codewords of "HELLO WORLD" at version 1-M
+	data := []byte{32, 91, 11, 120, 209, 114, 220, 77, 67, 64, 236, 17, 236, 17, 236, 17}
+	codewords, err := Encode(data, 10)
+	assert.Nil(err, "encoding should not fail")
+	assert.Equal([]byte{196, 35, 39, 119, 235, 215, 231, 226, 93, 23}, codewords, "codewords should match")
+
+	// Data codewords of "01234567" at version M2-L
+	data = []byte{0x40, 0x18, 0xac, 0xc3, 0x00}
+	codewords, err = Encode(data, 5)
+	assert.Nil(err, "encoding should not fail")
+	assert.Equal([]byte{0x86, 0x0d, 0x22, 0xae, 0x30}, codewords, "codewords should match")
+}
+
+func TestEncodeInvalidDegree(t *testing.T) {
+	assert := assert.New(t)
+
+	for _, degree := range []int{0, -1} {
+		_, err := NewEncoder(degree)
+		assert.ErrorIs(err, qrerr.ErrInvalidInput, "degree %d should be rejected", degree)
+
+		_, err = Encode([]byte{32, 91}, degree)
+		assert.ErrorIs(err, qrerr.ErrInvalidInput, "degree %d should be rejected", degree)
+	}
+}
+
+func TestConcurrentEncoders(t *testing.T) {
+	assert := assert.New(t)
+	data := []byte{32, 91, 11, 120, 209, 114, 220, 77, 67, 64, 236, 17, 236, 17, 236, 17}
+	results := make([][]byte, 64)
+
+	var wg sync.WaitGroup
+	for i := range results {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			results[i], _ = Encode(data, 1+i%30)
+		}(i)
+	}
+	wg.Wait()
+
+	for i, result := range results {
+		expected, _ := Encode(data, 1+i%30)
+		assert.Equal(expected, result, "concurrent results should match")
+	}
+}
+
+func BenchmarkEncode(b *testing.B) {
+	data := make([]byte, 118)
+	for i := range data {
+		data[i] = byte(i)
+	}
+	encoder, _ := NewEncoder(30)
+
+	for n := 0; n < b.N; n++ {
+		encoder.Encode(data)
+	}
+}
+
+// The codeword made of the data followed by the error correction codewords is a multiple
+// of the generator polynomial, so it vanishes at every root of the generator. Degrees
+// lower than one are rejected.
+func FuzzEncode(f *testing.F) {
+	f.Add([]byte{32, 91, 11, 120, 209, 114, 220, 77, 67, 64, 236, 17, 236, 17, 236, 17}, 10)
+	f.Add([]byte{}, 0)
+
+	f.Fuzz(func(t *testing.T, data []byte, degree int) {
+		degree %= 70
+
+		ec, err := Encode(data, degree)
+		if degree < 1 {
+			if !errors.Is(err, qrerr.ErrInvalidInput) {
+				t.Fatalf("degree %d should be rejected, got %v", degree, err)
+			}
+			return
+		}
+		if err != nil {
+			t.Fatalf("Error on encoding: %v", err)
+		}
+		if len(ec) != degree {
+			t.Fatalf("%d codewords computed instead of %d", len(ec), degree)
+		}
+
+		codeword := append(append([]byte(nil), data...), ec...)
+		for i := 0; i < degree; i++ {
+			value := byte(0)
+			for _, coefficient := range codeword {
+				value = Mul(value, Exp(i)) ^ coefficient
+			}
+			if value != 0 {
+				t.Fatalf("codeword does not vanish at the root 2^%d", i)
+			}
+		}
+	})
+}
@@ -0,0 +1,134 @@
+package img
+
+import (
+	"fmt"
+	"image"
+	"image/color"
+	"image/draw"
+	"qr/qr-gen/moduler"
+	"qr/qr-gen/qrerr"
+	"qr/qr-gen/util"
+)
+
+// Glyphs of the labels, 3x5 dots each, a # being a dark dot
+var glyphs = map[rune][5]string{
+	'0': {"###", "#.#", "#.#", "#.#", "###"},
+	'1': {".#.", "##.", ".#.", ".#.", "###"},
+	'2': {"###", "..#", "###", "#..", "###"},
+	'3': {"###", "..#", "###", "..#", "###"},
+	'4': {"#.#", "#.#", "###", "..#", "..#"},
+	'5': {"###", "#..", "###", "..#", "###"},
+	'6': {"###", "#..", "###", "#.#", "###"},
+	'7': {"###", "..#", "..#", "..#", "..#"},
+	'8': {"###", "#.#", "###", "#.#", "###"},
+	'9': {"###", "#.#", "###", "..#", "###"},
+	'A': {".#.", "#.#", "###", "#.#", "#.#"},
+	'K': {"#.#", "#.#", "##.", "#.#", "#.#"},
+	'L': {"#..", "#..", "#..", "#..", "###"},
+	'M': {"#.#", "###", "###", "#.#", "#.#"},
+	'N': {"##.", "#.#", "#.#", "#.#", "#.#"},
+	'O': {"###", "#.#", "#.#", "#.#", "###"},
+	'S': {"###", "#..", "###", "..#", "###"},
+	'T': {"###", ".#.", ".#.", ".#.", ".#."},
+	' ': {"...", "...", "...", "...", "..."},
+}
+
+const glyphWidth = 3
+const glyphHeight = 5
+
+// Candidates per row of the diagnostics image
+const diagnosticsColumns = 4
+
+var selectedColor = color.NRGBA{R: 0, G: 160, B: 0, A: 255}
+
+// CreateMaskDiagnosticsImage draws the symbols of every mask candidate side by side, scale
+// pixels per module, each one above the scores of the four penalty rules and their total.
+// The selected candidate is framed.
+func (qi *QrImage) CreateMaskDiagnosticsImage(filename string, diagnostics *moduler.MaskDiagnostics, scale int) (image.Image, error) {
+	if diagnostics == nil || len(diagnostics.Candidates) == 0 {
+		return nil, qrerr.ErrEmptyInput
+	}
+	if scale < 1 {
+		return nil, fmt.Errorf("%w: invalid scale %d", qrerr.ErrInvalidInput, scale)
+	}
+
+	labels := make([][]string, len(diagnostics.Candidates))
+	labelWidth := 0
+	for i, candidate := range diagnostics.Candidates {
+		p := candidate.Penalty
+		labels[i] = []string{
+			fmt.Sprintf("MASK %d", candidate.Mask),
+			fmt.Sprintf("N1 %d", p.AdjacentModules),
+			fmt.Sprintf("N2 %d", p.Blocks),
+			fmt.Sprintf("N3 %d", p.FinderLike),
+			fmt.Sprintf("N4 %d", p.Balance),
+			fmt.Sprintf("TOTAL %d", p.Total),
+		}
+		for _, label := range labels[i] {
+			labelWidth = util.Max(labelWidth, len(label))
+		}
+	}
+
+	// The dots of the labels are half a module wide, the frame and margins one dot
+	dot := util.Max(1, scale/2)
+	symbol := diagnostics.Candidates[0].Symbol
+	margin := 2 * dot
+	panelWidth := util.Max(symbol.Width()*scale, labelWidth*(glyphWidth+1)*dot) + 2*margin
+	panelHeight := symbol.Height()*scale + len(labels[0])*(glyphHeight+1)*dot + 3*margin
+
+	columns := util.Min(diagnosticsColumns, len(diagnostics.Candidates))
+	rows := (len(diagnostics.Candidates) + columns - 1) / columns
+	img := image.NewNRGBA(image.Rect(0, 0, columns*panelWidth, rows*panelHeight))
+	draw.Draw(img, img.Bounds(), image.White, image.Point{}, draw.Src)
+
+	for i, candidate := range diagnostics.Candidates {
+		panel := image.Rect(0, 0, panelWidth, panelHeight).Add(image.Pt(i%columns*panelWidth, i/columns*panelHeight))
+		if candidate.Mask == diagnostics.Selected {
+			drawFrame(img, panel, dot, selectedColor)
+		}
+
+		origin := panel.Min.Add(image.Pt(margin, margin))
+		drawModules(img, candidate.Symbol, origin, scale)
+
+		origin.Y += candidate.Symbol.Height()*scale + margin
+		for _, label := range labels[i] {
+			drawLabel(img, label, origin, dot)
+			origin.Y += (glyphHeight + 1) * dot
+		}
+	}
+
+	if err := writeImage(filename, img, 0); err != nil {
+		return nil, err
+	}
+
+	return img, nil
+}
+
+// drawFrame draws the border of the rectangle, width pixels thick
+func drawFrame(img draw.Image, r image.Rectangle, width int, c color.Color) {
+	fill := image.NewUniform(c)
+	draw.Draw(img, image.Rect(r.Min.X, r.Min.Y, r.Max.X, r.Min.Y+width), fill, image.Point{}, draw.Src)
+	draw.Draw(img, image.Rect(r.Min.X, r.Max.Y-width, r.Max.X, r.Max.Y), fill, image.Point{}, draw.Src)
+	draw.Draw(img, image.Rect(r.Min.X, r.Min.Y, r.Min.X+width, r.Max.Y), fill, image.Point{}, draw.Src)
+	draw.Draw(img, image.Rect(r.Max.X-width, r.Min.Y, r.Max.X, r.Max.Y), fill, image.Point{}, draw.Src)
+}
+
+// drawLabel draws the text dot pixels per glyph dot, its top left corner at the origin.
+// Characters without glyph are left blank.
+func drawLabel(img draw.Image, text string, origin image.Point, dot int) {
+	for i, r := range text {
+		glyph := glyphs[r]
+		left := origin.X + i*(glyphWidth+1)*dot
+
+		for row, line := range glyph {
+			for col, value := range line {
+				if value != '#' {
+					continue
+				}
+
+				x, y := left+col*dot, origin.Y+row*dot
+				draw.Draw(img, image.Rect(x, y, x+dot, y+dot), image.Black, image.Point{}, draw.Src)
+			}
+		}
+	}
+}
@@ -0,0 +1,59 @@
+package img
+
+import (
+	"image/color"
+	"path/filepath"
+	"qr/qr-gen/generator"
+	"qr/qr-gen/moduler"
+	"qr/qr-gen/qrerr"
+	"qr/qr-gen/util"
+	"qr/qr-gen/versioner"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCreateMaskDiagnosticsImage(t *testing.T) {
+	assert := assert.New(t)
+	path := filepath.Join(t.TempDir(), "masks.png")
+
+	diagnostics, _ := generator.New().DiagnoseMasks("HELLO WORLD", versioner.QrEcQuartile)
+	img, err := New().CreateMaskDiagnosticsImage(path, diagnostics, 4)
+	assert.NoError(err)
+
+	// 29 modules of 4 pixels and margins of 2 dots of 2 pixels, with 6 lines of 6 dots
+	panelWidth, panelHeight := 29*4+2*4, 29*4+6*6*2+3*4
+	assert.Equal(4*panelWidth, img.Bounds().Dx(), "widths should match")
+	assert.Equal(2*panelHeight, img.Bounds().Dy(), "heights should match")
+
+	selected := diagnostics.Selected
+	left, top := selected%4*panelWidth, selected/4*panelHeight
+	assert.Equal(color.NRGBA{R: 0, G: 160, B: 0, A: 255}, img.At(left, top), "selected candidate should be framed")
+	assert.Equal(color.NRGBA{R: 255, G: 255, B: 255, A: 255}, img.At((selected+1)%8%4*panelWidth, (selected+1)%8/4*panelHeight), "other candidates should not be framed")
+
+	// The top left module of the finder pattern, past the margin and the quiet zone
+	for i, candidate := range diagnostics.Candidates {
+		x, y := i%4*panelWidth+4+moduler.DefaultQuietZone*4, i/4*panelHeight+4+moduler.DefaultQuietZone*4
+		module, _ := candidate.Symbol.At(moduler.DefaultQuietZone, moduler.DefaultQuietZone)
+		assert.False(util.IsModuleLighten(module))
+		assert.Equal(uint32(0), gray(img.At(x, y)), "finder pattern of candidate %d should be drawn", i)
+	}
+
+	_, err = New().CreateMaskDiagnosticsImage(path, nil, 4)
+	assert.ErrorIs(err, qrerr.ErrEmptyInput)
+	_, err = New().CreateMaskDiagnosticsImage(path, diagnostics, 0)
+	assert.ErrorIs(err, qrerr.ErrInvalidInput)
+}
+
+func TestDrawLabel(t *testing.T) {
+	assert := assert.New(t)
+	path := filepath.Join(t.TempDir(), "masks.png")
+
+	diagnostics, _ := generator.New().DiagnoseMasks("1", versioner.QrEcLow)
+	img, _ := New().CreateMaskDiagnosticsImage(path, diagnostics, 1)
+
+	// With a single pixel per dot, the M of the first label starts under the symbol
+	top := 21 + 2*moduler.DefaultQuietZone + 2 + 2
+	assert.Equal(uint32(0), gray(img.At(2, top)), "first dot of the label should be dark")
+	assert.Equal(uint32(0xffff), gray(img.At(3, top)), "second dot of the label should be light")
+}
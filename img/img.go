@@ -1,17 +1,28 @@
 package img
 
 import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"hash/crc32"
 	"image"
 	"image/color"
+	"image/draw"
 	"image/png"
+	"math"
 	"os"
+	"qr/qr-gen/matrix"
+	"qr/qr-gen/moduler"
+	"qr/qr-gen/qrerr"
 	"qr/qr-gen/util"
 
 	"golang.org/x/exp/constraints"
 )
 
 type Image[T constraints.Integer] interface {
-	CreateImage(filename string, encoded [][]T) image.Image
+	CreateImage(filename string, modules *matrix.Matrix[T]) (image.Image, error)
+	CreatePrintImage(filename string, modules *matrix.Matrix[T], sizing *Sizing) (image.Image, error)
+	CreateMaskDiagnosticsImage(filename string, diagnostics *moduler.MaskDiagnostics, scale int) (image.Image, error)
 }
 
 type QrImage struct{}
@@ -20,24 +31,91 @@ func New() Image[util.Module] {
 	return &QrImage{}
 }
 
-func (qi *QrImage) CreateImage(filename string, encoded [][]util.Module) image.Image {
-	topLeftPoint := image.Point{0, 0}
-	bottomRightPoint := image.Point{len(encoded), len(encoded[0])}
+// CreateImage renders the modules one pixel each and writes the image as a PNG file
+func (qi *QrImage) CreateImage(filename string, modules *matrix.Matrix[util.Module]) (image.Image, error) {
+	return qi.createImage(filename, modules, 1, 0)
+}
+
+// CreatePrintImage renders the modules at the scale of the sizing and writes the image as a
+// PNG file holding the resolution of the printer, so that it is printed at the expected size
+func (qi *QrImage) CreatePrintImage(filename string, modules *matrix.Matrix[util.Module], sizing *Sizing) (image.Image, error) {
+	if sizing == nil || sizing.Scale < 1 || sizing.DPI < 1 {
+		return nil, fmt.Errorf("%w: invalid sizing", qrerr.ErrInvalidInput)
+	}
+
+	return qi.createImage(filename, modules, sizing.Scale, sizing.DPI)
+}
+
+func (qi *QrImage) createImage(filename string, modules *matrix.Matrix[util.Module], scale, dpi int) (image.Image, error) {
+	if modules == nil || modules.Width() == 0 || modules.Height() == 0 {
+		return nil, qrerr.ErrEmptyInput
+	}
+
+	img := image.NewNRGBA(image.Rect(0, 0, modules.Width()*scale, modules.Height()*scale))
+	drawModules(img, modules, image.Point{}, scale)
+
+	if err := writeImage(filename, img, dpi); err != nil {
+		return nil, err
+	}
 
-	img := image.NewNRGBA(image.Rectangle{topLeftPoint, bottomRightPoint})
+	return img, nil
+}
 
-	for i := 0; i < len(encoded); i++ {
-		for j := 0; j < len(encoded[i]); j++ {
-			if util.IsModuleLighten(encoded[i][j]) {
-				img.Set(j, i, color.White)
-			} else {
-				img.Set(j, i, color.Black)
+// drawModules draws the modules scale pixels each, the top left one at the origin
+func drawModules(img draw.Image, modules *matrix.Matrix[util.Module], origin image.Point, scale int) {
+	modules.ForEach(func(row, col int, module util.Module) {
+		c := color.Black
+		if util.IsModuleLighten(module) {
+			c = color.White
+		}
+
+		for y := origin.Y + row*scale; y < origin.Y+(row+1)*scale; y++ {
+			for x := origin.X + col*scale; x < origin.X+(col+1)*scale; x++ {
+				img.Set(x, y, c)
 			}
 		}
+	})
+}
+
+// writeImage encodes the image as a PNG file, holding the resolution of the printer when dpi
+// is set
+func writeImage(filename string, img image.Image, dpi int) error {
+	var b bytes.Buffer
+	if err := png.Encode(&b, img); err != nil {
+		return fmt.Errorf("Error on encoding the image: %w", err)
 	}
 
-	f, _ := os.Create(filename)
-	png.Encode(f, img)
+	data := b.Bytes()
+	if dpi > 0 {
+		data = withPhysicalDimensions(data, dpi)
+	}
+
+	if err := os.WriteFile(filename, data, 0644); err != nil {
+		return fmt.Errorf("Error on writing the image file: %w", err)
+	}
+
+	return nil
+}
+
+// pngHeaderSize is the size of the PNG signature followed by the IHDR chunk, which must be
+// the first chunk of the file
+const pngHeaderSize = 8 + 4 + 4 + 13 + 4
+
+// withPhysicalDimensions inserts a pHYs chunk after the IHDR chunk of the encoded PNG
+// image. The chunk holds the resolution in pixels per metre, as the PNG format requires.
+func withPhysicalDimensions(data []byte, dpi int) []byte {
+	ppm := uint32(math.Round(float64(dpi) / mmPerInch * 1000))
+
+	chunk := make([]byte, 0, 4+4+9+4)
+	chunk = binary.BigEndian.AppendUint32(chunk, 9)
+	chunk = append(chunk, "pHYs"...)
+	chunk = binary.BigEndian.AppendUint32(chunk, ppm)
+	chunk = binary.BigEndian.AppendUint32(chunk, ppm)
+	chunk = append(chunk, 1) // the unit is the metre
+	chunk = binary.BigEndian.AppendUint32(chunk, crc32.ChecksumIEEE(chunk[4:]))
 
-	return img
+	withChunk := make([]byte, 0, len(data)+len(chunk))
+	withChunk = append(withChunk, data[:pngHeaderSize]...)
+	withChunk = append(withChunk, chunk...)
+	return append(withChunk, data[pngHeaderSize:]...)
 }
@@ -0,0 +1,56 @@
+package img
+
+import (
+	"bytes"
+	"encoding/binary"
+	"image/png"
+	"os"
+	"path/filepath"
+	"qr/qr-gen/matrix"
+	"qr/qr-gen/qrerr"
+	"qr/qr-gen/util"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCreatePrintImage(t *testing.T) {
+	assert := assert.New(t)
+	path := filepath.Join(t.TempDir(), "qr.png")
+
+	encoded, _ := matrix.FromRows([][]util.Module{
+		{util.Module_DARKEN, util.Module_LIGHTEN, util.Module_DARKEN},
+		{util.Module_LIGHTEN, util.Module_DARKEN, util.Module_LIGHTEN},
+	})
+
+	_, err := New().CreatePrintImage(path, encoded, &Sizing{Scale: 3, DPI: 300})
+	assert.NoError(err)
+
+	data, err := os.ReadFile(path)
+	assert.NoError(err)
+
+	img, err := png.Decode(bytes.NewReader(data))
+	assert.NoError(err, "image should still be a valid PNG")
+	assert.Equal(9, img.Bounds().Dx(), "widths should match")
+	assert.Equal(6, img.Bounds().Dy(), "heights should match")
+	assert.Equal(uint32(0), gray(img.At(8, 0)), "module should be dark")
+	assert.Equal(uint32(0xffff), gray(img.At(3, 2)), "module should be light")
+
+	// 300 DPI is 11811 pixels per metre
+	chunk := data[pngHeaderSize:]
+	assert.Equal(uint32(9), binary.BigEndian.Uint32(chunk), "chunk lengths should match")
+	assert.Equal("pHYs", string(chunk[4:8]), "chunk types should match")
+	assert.Equal(uint32(11811), binary.BigEndian.Uint32(chunk[8:]), "horizontal resolutions should match")
+	assert.Equal(uint32(11811), binary.BigEndian.Uint32(chunk[12:]), "vertical resolutions should match")
+	assert.Equal(byte(1), chunk[16], "units should match")
+
+	_, err = New().CreatePrintImage(path, encoded, &Sizing{})
+	assert.ErrorIs(err, qrerr.ErrInvalidInput)
+	_, err = New().CreateImage(path, nil)
+	assert.ErrorIs(err, qrerr.ErrEmptyInput)
+}
+
+func gray(c interface{ RGBA() (r, g, b, a uint32) }) uint32 {
+	r, _, _, _ := c.RGBA()
+	return r
+}
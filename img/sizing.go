@@ -0,0 +1,108 @@
+package img
+
+import (
+	"fmt"
+	"math"
+	"qr/qr-gen/qrerr"
+)
+
+// MinQuietZone is the width in modules of the quiet zone required around QR symbols
+const MinQuietZone = 4
+
+const mmPerInch = 25.4
+
+// PrintSpec describes the physical constraints of a printed symbol
+type PrintSpec struct {
+	// WidthMM is the width available for the image, quiet zone included
+	WidthMM float64
+	// DPI is the resolution of the printer
+	DPI int
+	// MinModuleMM is the smallest module size (X-dimension) readable by the scanners, 0 when
+	// the scanners do not impose any
+	MinModuleMM float64
+}
+
+// SizingWarning flags a printed symbol which may not scan reliably
+type SizingWarning int
+
+const (
+	// SizingWarning_BELOW_PRINTER_RESOLUTION is raised when a module would be smaller than a dot
+	SizingWarning_BELOW_PRINTER_RESOLUTION SizingWarning = iota
+	// SizingWarning_BELOW_MIN_MODULE_SIZE is raised when the modules are smaller than the X-dimension
+	SizingWarning_BELOW_MIN_MODULE_SIZE
+	// SizingWarning_NARROW_QUIET_ZONE is raised when the quiet zone is narrower than MinQuietZone
+	SizingWarning_NARROW_QUIET_ZONE
+	// SizingWarning_EXCEEDS_WIDTH is raised when the image is wider than the available width
+	SizingWarning_EXCEEDS_WIDTH
+)
+
+var sizingWarningNames = map[SizingWarning]string{
+	SizingWarning_BELOW_PRINTER_RESOLUTION: "modules are smaller than the printer resolution",
+	SizingWarning_BELOW_MIN_MODULE_SIZE:    "modules are smaller than the minimum module size",
+	SizingWarning_NARROW_QUIET_ZONE:        "quiet zone is narrower than 4 modules",
+	SizingWarning_EXCEEDS_WIDTH:            "image is wider than the available width",
+}
+
+func (w SizingWarning) String() string {
+	if name, ok := sizingWarningNames[w]; ok {
+		return name
+	}
+	return "unknown"
+}
+
+// Sizing is the rendering of a symbol chosen for a print specification
+type Sizing struct {
+	// Scale is the number of pixels, or printer dots, per module
+	Scale int
+	// DPI is the resolution embedded in the image
+	DPI int
+	// ModuleMM is the printed size of a module
+	ModuleMM float64
+	// WidthPx and WidthMM are the width of the image, quiet zone included
+	WidthPx int
+	WidthMM float64
+	// Fits reports whether the symbol, surrounded by a quiet zone of MinQuietZone modules,
+	// holds in the available width with modules of at least the minimum size
+	Fits     bool
+	Warnings []SizingWarning
+}
+
+// ComputeSizing chooses the largest number of pixels per module which keeps the image in the
+// available width. The width of the image is given in modules, quiet zone included.
+func ComputeSizing(modules, quietZone int, spec PrintSpec) (*Sizing, error) {
+	if spec.WidthMM <= 0 || spec.DPI <= 0 || spec.MinModuleMM < 0 {
+		return nil, fmt.Errorf("%w: invalid print specification %+v", qrerr.ErrInvalidInput, spec)
+	}
+
+	if quietZone < 0 || modules <= 2*quietZone {
+		return nil, fmt.Errorf("%w: invalid symbol of %d modules with a quiet zone of %d modules", qrerr.ErrInvalidInput, modules, quietZone)
+	}
+
+	dots := int(math.Floor(spec.WidthMM * float64(spec.DPI) / mmPerInch))
+	sizing := &Sizing{Scale: dots / modules, DPI: spec.DPI}
+
+	if sizing.Scale == 0 {
+		// Rendered at one dot per module, the image can still be printed larger than wanted
+		sizing.Scale = 1
+		sizing.Warnings = append(sizing.Warnings, SizingWarning_BELOW_PRINTER_RESOLUTION)
+	}
+
+	sizing.ModuleMM = float64(sizing.Scale) * mmPerInch / float64(spec.DPI)
+	sizing.WidthPx = modules * sizing.Scale
+	sizing.WidthMM = float64(sizing.WidthPx) * mmPerInch / float64(spec.DPI)
+
+	if sizing.ModuleMM < spec.MinModuleMM {
+		sizing.Warnings = append(sizing.Warnings, SizingWarning_BELOW_MIN_MODULE_SIZE)
+	}
+	if quietZone < MinQuietZone {
+		sizing.Warnings = append(sizing.Warnings, SizingWarning_NARROW_QUIET_ZONE)
+	}
+	if sizing.WidthPx > dots {
+		sizing.Warnings = append(sizing.Warnings, SizingWarning_EXCEEDS_WIDTH)
+	}
+
+	compliantWidthPx := (modules - 2*quietZone + 2*MinQuietZone) * sizing.Scale
+	sizing.Fits = compliantWidthPx <= dots && sizing.ModuleMM >= spec.MinModuleMM
+
+	return sizing, nil
+}
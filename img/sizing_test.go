@@ -0,0 +1,50 @@
+package img
+
+import (
+	"qr/qr-gen/qrerr"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestComputeSizing(t *testing.T) {
+	assert := assert.New(t)
+
+	// A version 1 symbol with its quiet zone is 29 modules wide, 354 dots fit in 30mm at 300 DPI
+	sizing, err := ComputeSizing(29, 4, PrintSpec{WidthMM: 30, DPI: 300, MinModuleMM: 0.5})
+	assert.NoError(err)
+	assert.Equal(12, sizing.Scale, "scales should match")
+	assert.Equal(348, sizing.WidthPx, "widths should match")
+	assert.InDelta(1.016, sizing.ModuleMM, 1e-9, "module sizes should match")
+	assert.InDelta(29.464, sizing.WidthMM, 1e-9, "widths should match")
+	assert.True(sizing.Fits, "symbol should fit")
+	assert.Empty(sizing.Warnings, "sizing should not raise warnings")
+
+	sizing, err = ComputeSizing(29, 4, PrintSpec{WidthMM: 5, DPI: 300, MinModuleMM: 0.25})
+	assert.NoError(err)
+	assert.Equal(2, sizing.Scale, "scales should match")
+	assert.False(sizing.Fits, "modules should be too small")
+	assert.Equal([]SizingWarning{SizingWarning_BELOW_MIN_MODULE_SIZE}, sizing.Warnings, "warnings should match")
+
+	sizing, err = ComputeSizing(29, 4, PrintSpec{WidthMM: 2, DPI: 300})
+	assert.NoError(err)
+	assert.Equal(1, sizing.Scale, "scales should match")
+	assert.False(sizing.Fits, "symbol should not fit")
+	assert.Equal([]SizingWarning{SizingWarning_BELOW_PRINTER_RESOLUTION, SizingWarning_EXCEEDS_WIDTH}, sizing.Warnings, "warnings should match")
+
+	// With a 2 modules quiet zone, the scale is too large for a compliant quiet zone
+	sizing, err = ComputeSizing(25, 2, PrintSpec{WidthMM: 30, DPI: 300})
+	assert.NoError(err)
+	assert.Equal(14, sizing.Scale, "scales should match")
+	assert.False(sizing.Fits, "symbol should not fit with a compliant quiet zone")
+	assert.Equal([]SizingWarning{SizingWarning_NARROW_QUIET_ZONE}, sizing.Warnings, "warnings should match")
+	assert.Equal("quiet zone is narrower than 4 modules", sizing.Warnings[0].String(), "warning messages should match")
+
+	for _, spec := range []PrintSpec{{WidthMM: 0, DPI: 300}, {WidthMM: 30, DPI: 0}, {WidthMM: 30, DPI: 300, MinModuleMM: -1}} {
+		_, err = ComputeSizing(29, 4, spec)
+		assert.ErrorIs(err, qrerr.ErrInvalidInput, "spec %+v should be invalid", spec)
+	}
+
+	_, err = ComputeSizing(8, 4, PrintSpec{WidthMM: 30, DPI: 300})
+	assert.ErrorIs(err, qrerr.ErrInvalidInput, "symbol should be invalid")
+}
@@ -1,105 +1,99 @@
 package interleaver
 
 import (
+	"fmt"
+	"qr/qr-gen/bitbuf"
 	"qr/qr-gen/ec"
+	"qr/qr-gen/qrerr"
 	"qr/qr-gen/util"
 	"qr/qr-gen/versioner"
-	"strconv"
-	"strings"
 )
 
 type Interleaver interface {
-	GetFinalMessage(encoded string, version versioner.QrVersion, lvl versioner.QrEcLevel) string
+	GetFinalMessage(encoded string, version versioner.QrVersion, lvl versioner.QrEcLevel) (string, error)
+	GetFinalMessageBits(encoded *bitbuf.Buffer, version versioner.QrVersion, lvl versioner.QrEcLevel) (*bitbuf.Buffer, error)
 }
 
-type QrInterleaver struct{}
-
-func New() Interleaver {
-	// TODO: Extract this in another layer, to avoid redundant computation
-	util.ComputeLogAntilogTables()
-	return &QrInterleaver{}
+type QrInterleaver struct {
+	corrector ec.ErrorCorrector
 }
 
-func (i *QrInterleaver) GetFinalMessage(inputCodewords string, version versioner.QrVersion, lvl versioner.QrEcLevel) string {
-	var codewords string
-	ec := ec.New()
-
-	if i.isInterleavingNecessary(version, lvl) {
-		codewords = i.handleInterleaveProcess(version, lvl, inputCodewords)
-	} else {
-		errCorrCodewords := ec.GetErrorCorrectionCodewords(inputCodewords, version, lvl)
-		codewords = inputCodewords + util.ConvertIntListToCodewords(errCorrCodewords)
-	}
-
-	return util.PadRight(codewords, "0", len(codewords)+QR_REMAINDER_BITS[version])
+func New() Interleaver {
+	return NewWithErrorCorrector(ec.New())
 }
 
-func (i *QrInterleaver) isInterleavingNecessary(version versioner.QrVersion, lvl versioner.QrEcLevel) bool {
-	return util.QrEcInfo[util.GetECMappingKey(int(version), string(lvl))].NumBlocksGroup2 != 0
+// NewWithErrorCorrector returns an interleaver computing the error correction codewords of
+// the blocks with the given corrector
+func NewWithErrorCorrector(corrector ec.ErrorCorrector) Interleaver {
+	return &QrInterleaver{corrector: corrector}
 }
 
-func (i *QrInterleaver) handleInterleaveProcess(version versioner.QrVersion, lvl versioner.QrEcLevel, codewords string) string {
-	interleavedDataCodewords, dataBlocks := i.interleaveDataCodewords(version, lvl, codewords)
-	interleavedECCodewords := i.interleaveErrCorrCodewords(version, lvl, dataBlocks)
-
-	interleavedDataBinary := util.ConvertIntListToBin(interleavedDataCodewords)
-	interleavedECBinary := util.ConvertIntListToBin(interleavedECCodewords)
+func (i *QrInterleaver) GetFinalMessage(inputCodewords string, version versioner.QrVersion, lvl versioner.QrEcLevel) (string, error) {
+	encoded, err := bitbuf.FromString(inputCodewords)
+	if err != nil {
+		return "", err
+	}
 
-	return strings.Join(interleavedDataBinary, "") + strings.Join(interleavedECBinary, "")
+	result, err := i.GetFinalMessageBits(encoded, version, lvl)
+	if err != nil {
+		return "", err
+	}
+	return result.String(), nil
 }
 
-func (i *QrInterleaver) interleaveDataCodewords(version versioner.QrVersion, lvl versioner.QrEcLevel, encoded string) ([]int, [][]int) {
+// GetFinalMessageBits splits the data codewords in blocks, computes the error correction
+// codewords of every block and interleaves both, followed by the remainder bits.
+// Symbols made of a single block are left in order by the interleaving. The encoded data
+// must already be augmented up to the capacity of the symbol.
+func (i *QrInterleaver) GetFinalMessageBits(encoded *bitbuf.Buffer, version versioner.QrVersion, lvl versioner.QrEcLevel) (*bitbuf.Buffer, error) {
 	key := util.GetECMappingKey(int(version), string(lvl))
+	info, ok := util.QrEcInfo[key]
+	if !ok {
+		return nil, fmt.Errorf("%w %s", qrerr.ErrInvalidVersion, key)
+	}
 
-	group1Size := util.QrEcInfo[key].NumBlocksGroup1
-	group1BlockSize := util.QrEcInfo[key].DataCodeworkdsInGroup1Block
-	group1Codewords := encoded[:group1Size*group1BlockSize*util.QrCodewordSize]
-	group1Blocks := i.getBlocksOfCodewords(group1Codewords, group1Size, group1BlockSize)
-
-	group2Size := util.QrEcInfo[key].NumBlocksGroup2
-	group2BlockSize := util.QrEcInfo[key].DataCodewordsInGroup2Block
-	group2Codewords := encoded[group1Size*group1BlockSize*util.QrCodewordSize:]
-	group2Blocks := i.getBlocksOfCodewords(group2Codewords, group2Size, group2BlockSize)
-
-	dataBlocks := append(group1Blocks, group2Blocks...)
-	return i.interleaveCodewords(dataBlocks, util.Max(group1BlockSize, group2BlockSize)), dataBlocks
-}
+	if required := util.QrCodewordSize * info.TotalDataCodewords; encoded.Len() != required {
+		return nil, fmt.Errorf("%w: %d encoded bits instead of %d", qrerr.ErrInvalidLength, encoded.Len(), required)
+	}
 
-func (i *QrInterleaver) interleaveErrCorrCodewords(version versioner.QrVersion, lvl versioner.QrEcLevel, dataBlocks [][]int) []int {
-	blocks := make([][]int, len(dataBlocks))
-	ec := ec.New()
+	dataBlocks := i.getDataBlocks(encoded.Bytes(), info)
 
-	for i, block := range dataBlocks {
-		encoded := strings.Join(util.ConvertIntListToBin(block), "")
-		blocks[i] = ec.GetErrorCorrectionCodewords(encoded, version, lvl)
+	errCorrBlocks := make([][]byte, len(dataBlocks))
+	for j, block := range dataBlocks {
+		codewords, err := i.corrector.GetErrorCorrectionBytes(block, info.ECCodewordsPerBlock)
+		if err != nil {
+			return nil, err
+		}
+		errCorrBlocks[j] = codewords
 	}
 
-	return i.interleaveCodewords(blocks, util.QrEcInfo[util.GetECMappingKey(int(version), string(lvl))].ECCodewordsPerBlock)
-}
+	result := bitbuf.New()
+	result.AppendBytes(i.interleaveCodewords(dataBlocks, util.Max(info.DataCodeworkdsInGroup1Block, info.DataCodewordsInGroup2Block)))
+	result.AppendBytes(i.interleaveCodewords(errCorrBlocks, info.ECCodewordsPerBlock))
+	result.AppendBits(0, QR_REMAINDER_BITS[version])
 
-func (i *QrInterleaver) getBlocksOfCodewords(input string, blocksCount int, blockSize int) [][]int {
-	blocks := make([][]int, blocksCount)
+	return result, nil
+}
 
-	for i := 0; i < blocksCount; i++ {
-		currBlock := input[:blockSize*util.QrCodewordSize]
-		currBlockSlice := make([]int, blockSize)
+// Splits the data codewords in the blocks of both groups
+func (i *QrInterleaver) getDataBlocks(codewords []byte, info util.QrErrorCorrectionInfo) [][]byte {
+	blocks := make([][]byte, 0, info.NumBlocksGroup1+info.NumBlocksGroup2)
 
-		for j := 0; j < blockSize; j++ {
-			bin := currBlock[:util.QrCodewordSize]
-			value, _ := strconv.ParseInt(bin, 2, 64)
-			currBlockSlice[j] = int(value)
-			currBlock = currBlock[util.QrCodewordSize:]
-		}
+	for j := 0; j < info.NumBlocksGroup1; j++ {
+		blocks = append(blocks, codewords[:info.DataCodeworkdsInGroup1Block])
+		codewords = codewords[info.DataCodeworkdsInGroup1Block:]
+	}
 
-		blocks[i] = currBlockSlice
-		input = input[blockSize*util.QrCodewordSize:]
+	for j := 0; j < info.NumBlocksGroup2; j++ {
+		blocks = append(blocks, codewords[:info.DataCodewordsInGroup2Block])
+		codewords = codewords[info.DataCodewordsInGroup2Block:]
 	}
 
 	return blocks
 }
 
-func (i *QrInterleaver) interleaveCodewords(blocks [][]int, length int) []int {
-	var result []int
+func (i *QrInterleaver) interleaveCodewords(blocks [][]byte, length int) []byte {
+	var result []byte
 
 	for i := 0; i < length; i++ {
 		for _, block := range blocks {
@@ -0,0 +1,94 @@
+package interleaver
+
+import (
+	"qr/qr-gen/bitbuf"
+	"qr/qr-gen/gf256"
+	"qr/qr-gen/qrerr"
+	"qr/qr-gen/util"
+	"qr/qr-gen/versioner"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestGetFinalMessageErrors(t *testing.T) {
+	assert := assert.New(t)
+	i := New()
+
+	_, err := i.GetFinalMessage("0101", 41, versioner.QrEcLow)
+	assert.ErrorIs(err, qrerr.ErrInvalidVersion)
+
+	_, err = i.GetFinalMessage("0101", 1, versioner.QrEcLow)
+	assert.ErrorIs(err, qrerr.ErrInvalidLength)
+
+	_, err = i.GetFinalMessage("0102", 1, versioner.QrEcLow)
+	assert.Error(err, "invalid bits should be rejected")
+}
+
+// Every codeword of the data lands in a single block of the interleaved message, followed
+// by the error correction codewords of the block
+func FuzzGetFinalMessageBits(f *testing.F) {
+	f.Add([]byte("01234567"), byte(1), byte(1))
+	f.Add([]byte("HELLO WORLD"), byte(5), byte(2))
+	f.Add([]byte{}, byte(40), byte(3))
+
+	f.Fuzz(func(t *testing.T, seed []byte, version byte, lvl byte) {
+		qrVersion := versioner.QrVersion(int(version)%int(versioner.MaxQrVersion) + 1)
+		level := versioner.QrEcLevels[int(lvl)%len(versioner.QrEcLevels)]
+		info := util.QrEcInfo[util.GetECMappingKey(int(qrVersion), string(level))]
+
+		data := make([]byte, info.TotalDataCodewords)
+		for j := range data {
+			if len(seed) > 0 {
+				data[j] = seed[j%len(seed)] + byte(j/len(seed))
+			}
+		}
+
+		encoded := bitbuf.New()
+		encoded.AppendBytes(data)
+		message, err := New().GetFinalMessageBits(encoded, qrVersion, level)
+		if err != nil {
+			t.Fatalf("data of version %d should be interleaved: %v", qrVersion, err)
+		}
+
+		blocks := info.NumBlocksGroup1 + info.NumBlocksGroup2
+		totalCodewords := info.TotalDataCodewords + blocks*info.ECCodewordsPerBlock
+		if message.Len() != totalCodewords*util.QrCodewordSize+QR_REMAINDER_BITS[qrVersion] {
+			t.Fatalf("message of version %d should hold %d codewords", qrVersion, totalCodewords)
+		}
+
+		codewords := message.Bytes()
+		offset := 0
+		for block := 0; block < blocks; block++ {
+			length := info.DataCodeworkdsInGroup1Block
+			if block >= info.NumBlocksGroup1 {
+				length = info.DataCodewordsInGroup2Block
+			}
+
+			// Codewords are taken in turn from every block, the shorter blocks of the
+			// first group being skipped once exhausted
+			blockData := make([]byte, length)
+			for j := range blockData {
+				index := j*blocks + block
+				if j == info.DataCodewordsInGroup2Block-1 && info.NumBlocksGroup2 > 0 {
+					index = j*blocks + block - info.NumBlocksGroup1
+				}
+				blockData[j] = codewords[index]
+			}
+			if string(blockData) != string(data[offset:offset+length]) {
+				t.Fatalf("block %d of version %d should be interleaved in order", block, qrVersion)
+			}
+			offset += length
+
+			ec, err := gf256.Encode(blockData, info.ECCodewordsPerBlock)
+			if err != nil {
+				t.Fatalf("error correction codewords of block %d should be computed: %v", block, err)
+			}
+			for j := range ec {
+				if codewords[info.TotalDataCodewords+j*blocks+block] != ec[j] {
+					t.Fatalf("error correction codewords of block %d should be interleaved", block)
+				}
+			}
+		}
+	})
+}
@@ -0,0 +1,110 @@
+package matrix
+
+import (
+	"math/bits"
+	"qr/qr-gen/qrerr"
+)
+
+// WordSize is the number of modules packed in each word of the rows and columns
+const WordSize = 64
+
+// BitGrid is a grid of dark or light modules packed one bit per module, a set bit being a
+// dark module. The bits are stored twice, row wise and column wise, so that both rows and
+// columns are read as words. The first module of a line is the most significant bit of its
+// first word and the bits past the end of a line are always zeros.
+type BitGrid struct {
+	width    int
+	height   int
+	rowWords int
+	colWords int
+	rows     []uint64
+	cols     []uint64
+}
+
+func NewBitGrid(width, height int) *BitGrid {
+	rowWords := (width + WordSize - 1) / WordSize
+	colWords := (height + WordSize - 1) / WordSize
+
+	return &BitGrid{
+		width:    width,
+		height:   height,
+		rowWords: rowWords,
+		colWords: colWords,
+		rows:     make([]uint64, height*rowWords),
+		cols:     make([]uint64, width*colWords),
+	}
+}
+
+func (g *BitGrid) Width() int {
+	return g.width
+}
+
+func (g *BitGrid) Height() int {
+	return g.height
+}
+
+// Get returns whether the module is dark. Unlike Matrix, the coordinates are not checked
+// beyond the bounds of the storage, as the grid is read in the hot paths of the masking.
+func (g *BitGrid) Get(row, col int) bool {
+	return g.rows[row*g.rowWords+col/WordSize]&bitOf(col) != 0
+}
+
+// Set sets the module dark or light
+func (g *BitGrid) Set(row, col int, dark bool) {
+	rowWord := &g.rows[row*g.rowWords+col/WordSize]
+	colWord := &g.cols[col*g.colWords+row/WordSize]
+
+	if dark {
+		*rowWord |= bitOf(col)
+		*colWord |= bitOf(row)
+	} else {
+		*rowWord &^= bitOf(col)
+		*colWord &^= bitOf(row)
+	}
+}
+
+// Row returns the words of a row. The slice shares the storage of the grid.
+func (g *BitGrid) Row(i int) []uint64 {
+	return g.rows[i*g.rowWords : (i+1)*g.rowWords]
+}
+
+// Column returns the words of a column. The slice shares the storage of the grid.
+func (g *BitGrid) Column(j int) []uint64 {
+	return g.cols[j*g.colWords : (j+1)*g.colWords]
+}
+
+func (g *BitGrid) Clone() *BitGrid {
+	clone := *g
+	clone.rows = append([]uint64(nil), g.rows...)
+	clone.cols = append([]uint64(nil), g.cols...)
+	return &clone
+}
+
+// Xor toggles the modules of the grid set in the other one, as masking does
+func (g *BitGrid) Xor(other *BitGrid) error {
+	if g.width != other.width || g.height != other.height {
+		return &matrixError{"grids dimensions do not match", qrerr.ErrDimensionMismatch}
+	}
+
+	for i := range g.rows {
+		g.rows[i] ^= other.rows[i]
+	}
+	for i := range g.cols {
+		g.cols[i] ^= other.cols[i]
+	}
+
+	return nil
+}
+
+// Count returns the number of dark modules
+func (g *BitGrid) Count() int {
+	count := 0
+	for _, word := range g.rows {
+		count += bits.OnesCount64(word)
+	}
+	return count
+}
+
+func bitOf(i int) uint64 {
+	return 1 << (WordSize - 1 - i%WordSize)
+}
@@ -0,0 +1,74 @@
+package matrix
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestBitGridSet(t *testing.T) {
+	assert := assert.New(t)
+	g := NewBitGrid(70, 3)
+
+	g.Set(0, 0, true)
+	g.Set(1, 64, true)
+	g.Set(2, 69, true)
+	g.Set(2, 69, false)
+	g.Set(2, 68, true)
+
+	assert.True(g.Get(0, 0), "module should be dark")
+	assert.True(g.Get(1, 64), "module should be dark")
+	assert.False(g.Get(2, 69), "module should be light")
+	assert.Equal(3, g.Count(), "dark modules count should match")
+
+	assert.Equal([]uint64{1 << 63, 0}, g.Row(0), "row words should match")
+	assert.Equal([]uint64{0, 1 << 63}, g.Row(1), "row words should match")
+	assert.Equal([]uint64{0, 1 << 59}, g.Row(2), "row words should match")
+	assert.Equal([]uint64{1 << 62}, g.Column(64), "column words should match")
+	assert.Equal([]uint64{1 << 61}, g.Column(68), "column words should match")
+	assert.Equal([]uint64{0}, g.Column(69), "column words should match")
+}
+
+func TestBitGridXor(t *testing.T) {
+	assert := assert.New(t)
+	g := NewBitGrid(5, 5)
+	mask := NewBitGrid(5, 5)
+
+	for i := 0; i < 5; i++ {
+		g.Set(i, i, true)
+		mask.Set(i, 4-i, true)
+	}
+
+	clone := g.Clone()
+	assert.NoError(clone.Xor(mask))
+
+	assert.True(g.Get(2, 2), "original grid should be left as is")
+	assert.False(clone.Get(2, 2), "module on both grids should be toggled back")
+	assert.True(clone.Get(0, 4), "mask module should be toggled")
+	assert.True(clone.Get(4, 0), "mask module should be toggled")
+	assert.Equal(8, clone.Count(), "dark modules count should match")
+
+	for j := 0; j < 5; j++ {
+		for i := 0; i < 5; i++ {
+			assert.Equal(clone.Get(i, j), clone.Column(j)[0]&bitOf(i) != 0, "rows and columns should be consistent")
+		}
+	}
+
+	assert.EqualError(g.Xor(NewBitGrid(5, 6)), "grids dimensions do not match", "error messages should match")
+}
+
+func BenchmarkBitGridClone(b *testing.B) {
+	g := NewBitGrid(177, 177)
+
+	for n := 0; n < b.N; n++ {
+		g.Clone()
+	}
+}
+
+func BenchmarkMatrixClone(b *testing.B) {
+	m := NewMatrix[int](177, 177)
+
+	for n := 0; n < b.N; n++ {
+		NewMatrix[int](177, 177).SetMatrix(m.GetMatrix())
+	}
+}
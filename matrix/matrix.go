@@ -1,7 +1,13 @@
 package matrix
 
-import "fmt"
+import (
+	"fmt"
+	"qr/qr-gen/qrerr"
+	"strings"
+)
 
+// Matrix is a grid of values stored row by row: it has height rows of width values, and
+// every method takes the row before the column
 type Matrix[T any] struct {
 	mat    [][]T
 	width  int
@@ -9,10 +15,10 @@ type Matrix[T any] struct {
 }
 
 func NewMatrix[T any](width, height int) *Matrix[T] {
-	mat := make([][]T, width)
+	mat := make([][]T, height)
 
-	for i := 0; i < width; i++ {
-		mat[i] = make([]T, height)
+	for i := 0; i < height; i++ {
+		mat[i] = make([]T, width)
 	}
 
 	return &Matrix[T]{
@@ -22,13 +28,47 @@ func NewMatrix[T any](width, height int) *Matrix[T] {
 	}
 }
 
+// FromRows returns a matrix holding a copy of the rows, which must all have the same length
+func FromRows[T any](rows [][]T) (*Matrix[T], error) {
+	width := 0
+	if len(rows) > 0 {
+		width = len(rows[0])
+	}
+
+	m := NewMatrix[T](width, len(rows))
+	if err := m.SetMatrix(rows); err != nil {
+		return nil, err
+	}
+
+	return m, nil
+}
+
+// matrixError keeps the short messages of the matrix errors, while matching the sentinel
+// errors of the pipeline with errors.Is
+type matrixError struct {
+	msg      string
+	sentinel error
+}
+
+func (e *matrixError) Error() string {
+	return e.msg
+}
+
+func (e *matrixError) Unwrap() error {
+	return e.sentinel
+}
+
+func outOfRange(msg string) error {
+	return &matrixError{msg, qrerr.ErrOutOfRange}
+}
+
 func zero[T any]() T {
 	return *new(T)
 }
 
 func (m *Matrix[T]) Init(val T) {
-	for i := 0; i < m.width; i++ {
-		for j := 0; j < m.height; j++ {
+	for i := 0; i < m.height; i++ {
+		for j := 0; j < m.width; j++ {
 			m.mat[i][j] = val
 		}
 	}
@@ -42,101 +82,246 @@ func (m *Matrix[T]) Height() int {
 	return m.height
 }
 
-func (m *Matrix[T]) At(w, h int) (T, error) {
-	if w < 0 || w > m.width-1 {
-		return zero[T](), fmt.Errorf("width out of range")
+func (m *Matrix[T]) checkBounds(row, col int) error {
+	if row < 0 || row >= m.height {
+		return outOfRange("row index out of range")
 	}
 
-	if h < 0 || h >= m.height {
-		return zero[T](), fmt.Errorf("height out of range")
+	if col < 0 || col >= m.width {
+		return outOfRange("column index out of range")
 	}
 
-	return m.mat[w][h], nil
+	return nil
 }
 
-func (m *Matrix[T]) Set(w, h int, val T) (T, error) {
-	if w < 0 || w > m.width-1 {
-		return zero[T](), fmt.Errorf("width out of range")
+func (m *Matrix[T]) At(row, col int) (T, error) {
+	if err := m.checkBounds(row, col); err != nil {
+		return zero[T](), err
 	}
 
-	if h < 0 || h > m.height-1 {
-		return zero[T](), fmt.Errorf("height out of range")
+	return m.mat[row][col], nil
+}
+
+// Set sets the value and returns the previous one
+func (m *Matrix[T]) Set(row, col int, val T) (T, error) {
+	if err := m.checkBounds(row, col); err != nil {
+		return zero[T](), err
 	}
 
-	prevVal := m.mat[w][h]
-	m.mat[w][h] = val
+	prevVal := m.mat[row][col]
+	m.mat[row][col] = val
 
 	return prevVal, nil
 }
 
+// RowAt returns the values of a row. The slice shares the storage of the matrix.
 func (m *Matrix[T]) RowAt(rowIdx int) ([]T, error) {
-	if rowIdx < 0 || rowIdx > m.height-1 {
-		return nil, fmt.Errorf("row index out of range")
+	if rowIdx < 0 || rowIdx >= m.height {
+		return nil, outOfRange("row index out of range")
 	}
 
 	return m.mat[rowIdx], nil
 }
 
+// ColumnAt returns a copy of the values of a column
 func (m *Matrix[T]) ColumnAt(colIdx int) ([]T, error) {
-	if colIdx < 0 || colIdx > m.width-1 {
-		return nil, fmt.Errorf("column index out of range")
+	if colIdx < 0 || colIdx >= m.width {
+		return nil, outOfRange("column index out of range")
 	}
 
-	row := make([]T, m.height)
+	col := make([]T, m.height)
 	for i := 0; i < m.height; i++ {
-		row[i] = m.mat[i][colIdx]
+		col[i] = m.mat[i][colIdx]
 	}
 
-	return row, nil
+	return col, nil
 }
 
+// GetMatrix returns the rows of the matrix, sharing its storage
 func (m *Matrix[T]) GetMatrix() [][]T {
 	return m.mat
 }
 
+// SetMatrix copies the rows in the matrix, which must have the same dimensions
 func (m *Matrix[T]) SetMatrix(mat [][]T) error {
-	if m.width != len(mat) {
-		return fmt.Errorf("matrices witdth does not match")
+	if m.height != len(mat) {
+		return &matrixError{"matrices height does not match", qrerr.ErrDimensionMismatch}
 	}
 
-	if m.height != len(mat[0]) {
-		return fmt.Errorf("matrices height does not match")
+	for _, row := range mat {
+		if m.width != len(row) {
+			return &matrixError{"matrices width does not match", qrerr.ErrDimensionMismatch}
+		}
 	}
 
-	for i := 0; i < m.width; i++ {
-		for j := 0; j < m.height; j++ {
-			m.mat[i][j] = mat[i][j]
-		}
+	for i := range mat {
+		copy(m.mat[i], mat[i])
 	}
 
 	return nil
 }
 
-func (m *Matrix[T]) Expand(n int) error {
+// Expand surrounds the matrix with n lines and columns of the fill value on every side,
+// such as the quiet zone of a symbol
+func (m *Matrix[T]) Expand(n int, fill T) error {
 	if n < 0 {
-		return fmt.Errorf("invalid expansion unit")
+		return &matrixError{"invalid expansion unit", qrerr.ErrInvalidInput}
 	}
 
-	expandedMat := make([][]T, m.width+2*n)
-	for i := range expandedMat {
-		expandedMat[i] = make([]T, m.height+2*n)
+	expanded := NewMatrix[T](m.width+2*n, m.height+2*n)
+	expanded.Init(fill)
+
+	for i := 0; i < m.height; i++ {
+		copy(expanded.mat[i+n][n:], m.mat[i])
 	}
 
-	for i := 0; i < m.width; i++ {
-		for j := 0; j < m.height; j++ {
-			expandedMat[i+n][j+n] = m.mat[i][j]
-		}
+	*m = *expanded
+	return nil
+}
+
+// Crop removes n lines and columns on every side of the matrix, undoing Expand
+func (m *Matrix[T]) Crop(n int) error {
+	if n < 0 || 2*n > m.width || 2*n > m.height {
+		return &matrixError{"invalid crop unit", qrerr.ErrInvalidInput}
+	}
+
+	cropped, err := m.SubMatrix(n, n, m.height-2*n, m.width-2*n)
+	if err != nil {
+		return err
 	}
 
-	m.mat = expandedMat
+	*m = *cropped
 	return nil
 }
 
-func (m *Matrix[T]) PrintMatrix() {
+// SubMatrix returns a copy of the region of the given height and width, whose top left value is
+// at the given row and column
+func (m *Matrix[T]) SubMatrix(row, col, height, width int) (*Matrix[T], error) {
+	if width < 0 || height < 0 || row < 0 || col < 0 || row+height > m.height || col+width > m.width {
+		return nil, outOfRange("sub-matrix out of range")
+	}
+
+	sub := NewMatrix[T](width, height)
+	for i := 0; i < height; i++ {
+		copy(sub.mat[i], m.mat[row+i][col:col+width])
+	}
+
+	return sub, nil
+}
+
+func (m *Matrix[T]) Clone() *Matrix[T] {
+	clone, _ := m.SubMatrix(0, 0, m.height, m.width)
+	return clone
+}
+
+// Transpose returns a new matrix whose rows are the columns of the matrix
+func (m *Matrix[T]) Transpose() *Matrix[T] {
+	return m.transform(m.height, m.width, func(row, col int) T {
+		return m.mat[col][row]
+	})
+}
+
+// Rotate90 returns a new matrix rotated a quarter turn clockwise
+func (m *Matrix[T]) Rotate90() *Matrix[T] {
+	return m.transform(m.height, m.width, func(row, col int) T {
+		return m.mat[m.height-1-col][row]
+	})
+}
+
+// FlipH returns a new matrix mirrored left to right
+func (m *Matrix[T]) FlipH() *Matrix[T] {
+	return m.transform(m.width, m.height, func(row, col int) T {
+		return m.mat[row][m.width-1-col]
+	})
+}
+
+// FlipV returns a new matrix mirrored top to bottom
+func (m *Matrix[T]) FlipV() *Matrix[T] {
+	return m.transform(m.width, m.height, func(row, col int) T {
+		return m.mat[m.height-1-row][col]
+	})
+}
+
+// transform builds a new matrix of the given dimensions, every value read from the
+// matrix at the position given by the source function
+func (m *Matrix[T]) transform(width, height int, source func(row, col int) T) *Matrix[T] {
+	transformed := NewMatrix[T](width, height)
+	for i := 0; i < height; i++ {
+		for j := 0; j < width; j++ {
+			transformed.mat[i][j] = source(i, j)
+		}
+	}
+	return transformed
+}
+
+// ForEach calls fn with every value, row by row
+func (m *Matrix[T]) ForEach(fn func(row, col int, val T)) {
+	for i, row := range m.mat {
+		for j, val := range row {
+			fn(i, j, val)
+		}
+	}
+}
+
+// ForEachRow calls fn with every row, sharing the storage of the matrix
+func (m *Matrix[T]) ForEachRow(fn func(row int, vals []T)) {
+	for i, row := range m.mat {
+		fn(i, row)
+	}
+}
+
+// Count returns the number of values matching the predicate
+func (m *Matrix[T]) Count(pred func(val T) bool) int {
+	count := 0
+	m.ForEach(func(row, col int, val T) {
+		if pred(val) {
+			count += 1
+		}
+	})
+	return count
+}
+
+// Map returns a new matrix of the values computed by fn from every value of the matrix
+func Map[T, U any](m *Matrix[T], fn func(row, col int, val T) U) *Matrix[U] {
+	mapped := NewMatrix[U](m.width, m.height)
+	m.ForEach(func(row, col int, val T) {
+		mapped.mat[row][col] = fn(row, col, val)
+	})
+	return mapped
+}
+
+// Equal returns whether both matrices have the same dimensions and values
+func Equal[T comparable](a, b *Matrix[T]) bool {
+	if a.width != b.width || a.height != b.height {
+		return false
+	}
+
+	for i := range a.mat {
+		for j := range a.mat[i] {
+			if a.mat[i][j] != b.mat[i][j] {
+				return false
+			}
+		}
+	}
+
+	return true
+}
+
+// String formats the matrix one row per line, the values separated by spaces
+func (m *Matrix[T]) String() string {
+	var b strings.Builder
 	for _, row := range m.mat {
-		for _, val := range row {
-			fmt.Printf("%v ", val)
+		for j, val := range row {
+			if j > 0 {
+				b.WriteByte(' ')
+			}
+			fmt.Fprint(&b, val)
 		}
-		fmt.Println()
+		b.WriteByte('\n')
 	}
+	return b.String()
+}
+
+func (m *Matrix[T]) PrintMatrix() {
+	fmt.Print(m.String())
 }
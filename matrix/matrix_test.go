@@ -1,6 +1,8 @@
 package matrix
 
 import (
+	"qr/qr-gen/qrerr"
+	"strings"
 	"testing"
 
 	"github.com/stretchr/testify/assert"
@@ -35,36 +37,36 @@ func TestMatrixAt(t *testing.T) {
 
 	tests := []struct {
 		name     string
-		w, h     int
+		row, col int
 		expected int
 		err      string
 	}{
 		{
 			name:     "WithinRange",
-			w:        1,
-			h:        2,
+			row:      1,
+			col:      2,
 			expected: 6,
 			err:      "",
 		},
 		{
-			name:     "WidthOutOfRange",
-			w:        3,
-			h:        1,
+			name:     "RowOutOfRange",
+			row:      3,
+			col:      1,
 			expected: 0,
-			err:      "width out of range",
+			err:      "row index out of range",
 		},
 		{
-			name:     "HeightOutOfRange",
-			w:        1,
-			h:        3,
+			name:     "ColumnOutOfRange",
+			row:      1,
+			col:      3,
 			expected: 0,
-			err:      "height out of range",
+			err:      "column index out of range",
 		},
 	}
 
 	for _, test := range tests {
 		t.Run(test.name, func(t *testing.T) {
-			actual, err := mat.At(test.w, test.h)
+			actual, err := mat.At(test.row, test.col)
 
 			if err != nil {
 				assert.Equal(test.err, err.Error(), "Error messages should match")
@@ -84,45 +86,45 @@ func TestMatrixSet(t *testing.T) {
 
 	tests := []struct {
 		name            string
-		w, h, val       int
+		row, col, val   int
 		expectedPrevVal int
 		err             string
 	}{
 		{
 			name:            "WithinRange",
-			w:               1,
-			h:               2,
+			row:             1,
+			col:             2,
 			val:             10,
 			expectedPrevVal: 6,
 			err:             "",
 		},
 		{
-			name:            "WidthOutOfRange",
-			w:               3,
-			h:               1,
+			name:            "RowOutOfRange",
+			row:             3,
+			col:             1,
 			val:             10,
 			expectedPrevVal: 0,
-			err:             "width out of range",
+			err:             "row index out of range",
 		},
 		{
-			name:            "HeightOutOfRange",
-			w:               1,
-			h:               3,
+			name:            "ColumnOutOfRange",
+			row:             1,
+			col:             3,
 			val:             10,
 			expectedPrevVal: 0,
-			err:             "height out of range",
+			err:             "column index out of range",
 		},
 	}
 
 	for _, test := range tests {
 		t.Run(test.name, func(t *testing.T) {
-			prevVal, err := mat.Set(test.w, test.h, test.val)
+			prevVal, err := mat.Set(test.row, test.col, test.val)
 
 			if err != nil {
 				assert.Equal(test.err, err.Error(), "Error messages should match")
 			} else {
 				assert.Equal(test.expectedPrevVal, prevVal, "Previous value returned should match")
-				newVal, _ := mat.At(test.w, test.h)
+				newVal, _ := mat.At(test.row, test.col)
 				assert.Equal(test.val, newVal, "New value set should match")
 			}
 		})
@@ -220,3 +222,190 @@ func TestMatrixColAt(t *testing.T) {
 		})
 	}
 }
+
+func TestMatrixExpand(t *testing.T) {
+	assert := assert.New(t)
+
+	mat := NewMatrix[int](3, 2)
+	mat.SetMatrix([][]int{{1, 2, 3}, {4, 5, 6}})
+	assert.NoError(mat.Expand(1, 9))
+
+	assert.Equal(5, mat.Width(), "width should match")
+	assert.Equal(4, mat.Height(), "height should match")
+	assert.Equal([][]int{
+		{9, 9, 9, 9, 9},
+		{9, 1, 2, 3, 9},
+		{9, 4, 5, 6, 9},
+		{9, 9, 9, 9, 9},
+	}, mat.GetMatrix(), "matrices should match")
+
+	val, err := mat.At(3, 4)
+	assert.NoError(err, "expanded modules should be in range")
+	assert.Equal(9, val, "values should match")
+
+	assert.NoError(mat.Expand(0, 7))
+	assert.Equal(5, mat.Width(), "width should not change")
+}
+
+func TestMatrixErrors(t *testing.T) {
+	assert := assert.New(t)
+	mat := NewMatrix[int](3, 3)
+
+	_, err := mat.At(3, 0)
+	assert.ErrorIs(err, qrerr.ErrOutOfRange)
+
+	_, err = mat.ColumnAt(-1)
+	assert.ErrorIs(err, qrerr.ErrOutOfRange)
+
+	err = mat.SetMatrix([][]int{})
+	assert.EqualError(err, "matrices height does not match", "error messages should match")
+	assert.ErrorIs(err, qrerr.ErrDimensionMismatch)
+
+	err = mat.SetMatrix([][]int{{1, 2, 3}, {4, 5}, {7, 8, 9}})
+	assert.EqualError(err, "matrices width does not match", "error messages should match")
+
+	assert.ErrorIs(mat.Expand(-1, 0), qrerr.ErrInvalidInput)
+}
+
+func TestMatrixRectangular(t *testing.T) {
+	assert := assert.New(t)
+
+	mat, err := FromRows([][]int{{1, 2, 3}, {4, 5, 6}})
+	assert.NoError(err)
+	assert.Equal(3, mat.Width(), "width should be the length of the rows")
+	assert.Equal(2, mat.Height(), "height should be the number of rows")
+
+	row, err := mat.RowAt(1)
+	assert.NoError(err)
+	assert.Equal([]int{4, 5, 6}, row, "rows should match")
+
+	col, err := mat.ColumnAt(2)
+	assert.NoError(err)
+	assert.Equal([]int{3, 6}, col, "columns should match")
+
+	_, err = mat.RowAt(2)
+	assert.ErrorIs(err, qrerr.ErrOutOfRange)
+	_, err = mat.At(1, 2)
+	assert.NoError(err, "last value should be in range")
+	_, err = mat.Set(2, 1, 0)
+	assert.ErrorIs(err, qrerr.ErrOutOfRange)
+
+	_, err = FromRows([][]int{{1, 2}, {3}})
+	assert.ErrorIs(err, qrerr.ErrDimensionMismatch)
+}
+
+func TestMatrixTransformations(t *testing.T) {
+	assert := assert.New(t)
+	mat, _ := FromRows([][]int{{1, 2, 3}, {4, 5, 6}})
+
+	tests := []struct {
+		name     string
+		actual   *Matrix[int]
+		expected [][]int
+	}{
+		{"Transpose", mat.Transpose(), [][]int{{1, 4}, {2, 5}, {3, 6}}},
+		{"Rotate90", mat.Rotate90(), [][]int{{4, 1}, {5, 2}, {6, 3}}},
+		{"Rotate180", mat.Rotate90().Rotate90(), [][]int{{6, 5, 4}, {3, 2, 1}}},
+		{"FlipH", mat.FlipH(), [][]int{{3, 2, 1}, {6, 5, 4}}},
+		{"FlipV", mat.FlipV(), [][]int{{4, 5, 6}, {1, 2, 3}}},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			expected, _ := FromRows(test.expected)
+			assert.True(Equal(expected, test.actual), "matrices should match:\n%v", test.actual)
+			assert.Equal(len(test.expected[0]), test.actual.Width(), "widths should match")
+		})
+	}
+
+	rotated := mat.Rotate90().Rotate90().Rotate90().Rotate90()
+	assert.True(Equal(mat, rotated), "four rotations should restore the matrix")
+	assert.Equal([][]int{{1, 2, 3}, {4, 5, 6}}, mat.GetMatrix(), "transformations should leave the matrix as is")
+}
+
+func TestMatrixRegions(t *testing.T) {
+	assert := assert.New(t)
+	mat, _ := FromRows([][]int{{1, 2, 3, 4}, {5, 6, 7, 8}, {9, 10, 11, 12}})
+
+	sub, err := mat.SubMatrix(1, 2, 2, 2)
+	assert.NoError(err)
+	assert.Equal([][]int{{7, 8}, {11, 12}}, sub.GetMatrix(), "sub-matrices should match")
+
+	sub, err = mat.SubMatrix(0, 1, 2, 3)
+	assert.NoError(err)
+	assert.Equal([][]int{{2, 3, 4}, {6, 7, 8}}, sub.GetMatrix(), "sub-matrices should be sized by height then width")
+
+	_, err = mat.SubMatrix(2, 0, 2, 1)
+	assert.ErrorIs(err, qrerr.ErrOutOfRange)
+
+	clone := mat.Clone()
+	clone.Set(0, 0, 42)
+	assert.False(Equal(mat, clone), "clones should not share their storage")
+
+	assert.NoError(clone.Expand(2, 0))
+	assert.NoError(clone.Crop(2))
+	assert.Equal(4, clone.Width(), "width should be restored")
+	assert.Equal(3, clone.Height(), "height should be restored")
+	assert.Equal([]int{42, 2, 3, 4}, clone.GetMatrix()[0], "values should be restored")
+
+	assert.ErrorIs(clone.Crop(2), qrerr.ErrInvalidInput)
+}
+
+func TestMatrixIteration(t *testing.T) {
+	assert := assert.New(t)
+	mat, _ := FromRows([][]int{{1, 2, 3}, {4, 5, 6}})
+
+	visited := []int{}
+	mat.ForEach(func(row, col int, val int) {
+		assert.Equal(row*3+col+1, val, "positions should match")
+		visited = append(visited, val)
+	})
+	assert.Equal([]int{1, 2, 3, 4, 5, 6}, visited, "values should be visited row by row")
+
+	sums := []int{}
+	mat.ForEachRow(func(row int, vals []int) {
+		sums = append(sums, vals[0]+vals[1]+vals[2])
+	})
+	assert.Equal([]int{6, 15}, sums, "rows should match")
+
+	assert.Equal(3, mat.Count(func(val int) bool { return val%2 == 0 }), "counts should match")
+
+	labels := Map(mat, func(row, col int, val int) string {
+		return strings.Repeat("#", val%3)
+	})
+	assert.Equal([][]string{{"#", "##", ""}, {"#", "##", ""}}, labels.GetMatrix(), "mapped values should match")
+	assert.Equal("1 2 3\n4 5 6\n", mat.String(), "formats should match")
+}
+
+// The transformations compose back to the identity, and cropping undoes the expansion
+func FuzzMatrixTransformations(f *testing.F) {
+	f.Add(byte(3), byte(2), []byte{1, 2, 3, 4, 5, 6}, byte(1))
+	f.Add(byte(0), byte(0), []byte{}, byte(0))
+
+	f.Fuzz(func(t *testing.T, width, height byte, values []byte, n byte) {
+		m := NewMatrix[byte](int(width%32), int(height%32))
+		m.ForEach(func(row, col int, val byte) {
+			if len(values) > 0 {
+				m.Set(row, col, values[(row*m.Width()+col)%len(values)])
+			}
+		})
+
+		if !Equal(m, m.Rotate90().Rotate90().Rotate90().Rotate90()) {
+			t.Fatal("four quarter turns should be the identity")
+		}
+		if !Equal(m, m.Transpose().Transpose()) || !Equal(m, m.FlipH().FlipH()) || !Equal(m, m.FlipV().FlipV()) {
+			t.Fatal("transposition and flips should be involutions")
+		}
+		if !Equal(m.Rotate90(), m.Transpose().FlipH()) {
+			t.Fatal("a quarter turn should be the flipped transposition")
+		}
+
+		expanded := m.Clone()
+		if err := expanded.Expand(int(n%8), 0xff); err != nil {
+			t.Fatal(err)
+		}
+		if err := expanded.Crop(int(n % 8)); err != nil || !Equal(m, expanded) {
+			t.Fatalf("crop should undo the expansion: %v", err)
+		}
+	})
+}
@@ -0,0 +1,325 @@
+package micro
+
+import (
+	"fmt"
+	"qr/qr-gen/bitbuf"
+	"qr/qr-gen/ec"
+	"qr/qr-gen/encoder"
+	"qr/qr-gen/matrix"
+	"qr/qr-gen/qrerr"
+	"qr/qr-gen/util"
+	"qr/qr-gen/versioner"
+	"strconv"
+)
+
+type MicroVersion int
+
+type MicroEncoder interface {
+	GetVersion(s string, mode versioner.QrMode, lvl versioner.QrEcLevel) (MicroVersion, error)
+	GetModeIndicator(mode versioner.QrMode, version MicroVersion) string
+	GetCountIndicator(s string, version MicroVersion, mode versioner.QrMode) (string, error)
+	Encode(s string, lvl versioner.QrEcLevel) (string, MicroVersion, error)
+	AugmentEncodedInput(s string, version MicroVersion, lvl versioner.QrEcLevel) (string, error)
+	GetFinalMessage(encoded string, version MicroVersion, lvl versioner.QrEcLevel) (string, error)
+	EncodeBits(s string, lvl versioner.QrEcLevel) (*bitbuf.Buffer, MicroVersion, error)
+	AugmentEncodedBits(b *bitbuf.Buffer, version MicroVersion, lvl versioner.QrEcLevel) error
+	GetFinalMessageBits(encoded *bitbuf.Buffer, version MicroVersion, lvl versioner.QrEcLevel) (*bitbuf.Buffer, error)
+}
+
+type QrMicroEncoder struct{}
+
+type microSymbolInfo struct {
+	DataBits      int
+	ECCodewords   int
+	CharCapacity  []int
+	FormatStrings []string
+}
+
+const (
+	MicroM1 MicroVersion = 1
+	MicroM2 MicroVersion = 2
+	MicroM3 MicroVersion = 3
+	MicroM4 MicroVersion = 4
+)
+
+func NewEncoder() MicroEncoder {
+	return &QrMicroEncoder{}
+}
+
+// Generate encodes the input into the smallest Micro QR symbol supporting
+// the given error correction level and returns its module matrix.
+// M1 only provides error detection and is therefore only considered for
+// the low error correction level.
+func Generate(s string, lvl versioner.QrEcLevel) (*matrix.Matrix[util.Module], error) {
+	return GenerateWithOptions(s, lvl, DefaultOptions())
+}
+
+// GenerateWithOptions encodes the input like Generate, building the symbol with the given
+// options.
+func GenerateWithOptions(s string, lvl versioner.QrEcLevel, options Options) (*matrix.Matrix[util.Module], error) {
+	e := NewEncoder()
+
+	encoded, version, err := e.EncodeBits(s, lvl)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := e.AugmentEncodedBits(encoded, version, lvl); err != nil {
+		return nil, fmt.Errorf("Error on augmenting the encoded data: %w", err)
+	}
+
+	data, err := e.GetFinalMessageBits(encoded, version, lvl)
+	if err != nil {
+		return nil, fmt.Errorf("Error on computing the final message: %w", err)
+	}
+
+	m := NewModulerWithOptions(version, lvl, options)
+	matrix, _, err := m.CreateModuleMatrixFromBits(data)
+	if err != nil {
+		return nil, fmt.Errorf("Error on placing the modules: %w", err)
+	}
+
+	return matrix, nil
+}
+
+func (e *QrMicroEncoder) Encode(s string, lvl versioner.QrEcLevel) (string, MicroVersion, error) {
+	b, version, err := e.EncodeBits(s, lvl)
+	if err != nil {
+		return "", 0, err
+	}
+	return b.String(), version, nil
+}
+
+// EncodeBits encodes the input, preceded by its mode and count indicators, in the most
+// compact mode and returns the smallest version holding it
+func (e *QrMicroEncoder) EncodeBits(s string, lvl versioner.QrEcLevel) (*bitbuf.Buffer, MicroVersion, error) {
+	mode, err := versioner.New().GetMode(s)
+	if err != nil {
+		return nil, 0, fmt.Errorf("Error on computing the encoding mode: %w", err)
+	}
+
+	version, err := e.GetVersion(s, mode, lvl)
+	if err != nil {
+		return nil, 0, fmt.Errorf("Error on computing the encoding version: %w", err)
+	}
+
+	countIndLength, err := e.getCountIndicatorLength(version, mode)
+	if err != nil {
+		return nil, 0, fmt.Errorf("Error on computing the encoding count indicator: %w", err)
+	}
+
+	encodedInput, err := encoder.New().EncodeInputBits(s, mode)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	b := bitbuf.New()
+	b.AppendString(e.GetModeIndicator(mode, version))
+	b.AppendBits(len(s), countIndLength)
+	b.AppendBuffer(encodedInput)
+
+	return b, version, nil
+}
+
+func (e *QrMicroEncoder) GetVersion(s string, mode versioner.QrMode, lvl versioner.QrEcLevel) (MicroVersion, error) {
+	if _, ok := microModeIndices[mode]; !ok {
+		return MicroVersion(-1), fmt.Errorf("%w %q", qrerr.ErrInvalidMode, mode)
+	}
+
+	largest := MicroVersion(-1)
+	for version := MicroM1; version <= MicroM4; version++ {
+		info, ok := microSymbolInfos[version][lvl]
+		if !ok {
+			continue
+		}
+		largest = version
+
+		if len(s) <= info.CharCapacity[microModeIndices[mode]] {
+			return version, nil
+		}
+	}
+
+	if largest == -1 {
+		return MicroVersion(-1), fmt.Errorf("%w %q for Micro QR", qrerr.ErrInvalidLevel, lvl)
+	}
+
+	// The largest symbol of every level supports all the modes
+	return MicroVersion(-1), &qrerr.ErrDataTooLong{
+		Needed: len(microModeIndicators[largest][mode]) + microCountIndLengths[largest][mode] + versioner.New().GetDataLength(s, mode),
+		Max:    microSymbolInfos[largest][lvl].DataBits,
+		Level:  rune(lvl),
+	}
+}
+
+func (e *QrMicroEncoder) GetModeIndicator(mode versioner.QrMode, version MicroVersion) string {
+	return microModeIndicators[version][mode]
+}
+
+func (e *QrMicroEncoder) GetCountIndicator(s string, version MicroVersion, mode versioner.QrMode) (string, error) {
+	length, err := e.getCountIndicatorLength(version, mode)
+	if err != nil {
+		return "", err
+	}
+
+	sLenBin := strconv.FormatInt(int64(len(s)), 2)
+	return util.PadLeft(sLenBin, "0", length), nil
+}
+
+func (e *QrMicroEncoder) getCountIndicatorLength(version MicroVersion, mode versioner.QrMode) (int, error) {
+	length, ok := microCountIndLengths[version][mode]
+	if !ok {
+		return 0, fmt.Errorf("%w %s for M%d", qrerr.ErrInvalidMode, mode, version)
+	}
+	return length, nil
+}
+
+func (e *QrMicroEncoder) AugmentEncodedInput(s string, version MicroVersion, lvl versioner.QrEcLevel) (string, error) {
+	b, err := bitbuf.FromString(s)
+	if err != nil {
+		return "", err
+	}
+
+	if err := e.AugmentEncodedBits(b, version, lvl); err != nil {
+		return "", err
+	}
+	return b.String(), nil
+}
+
+// AugmentEncodedBits appends the terminator and the padding to the encoded data. The last
+// data codeword of M1 and M3 symbols is only 4 bits long and is padded with zeros.
+// Encoded data exceeding the capacity is rejected.
+func (e *QrMicroEncoder) AugmentEncodedBits(b *bitbuf.Buffer, version MicroVersion, lvl versioner.QrEcLevel) error {
+	info, ok := microSymbolInfos[version][lvl]
+	if !ok {
+		return fmt.Errorf("%w %s-%c", qrerr.ErrInvalidVersion, version, lvl)
+	}
+
+	requiredBitsCount := info.DataBits
+	if b.Len() > requiredBitsCount {
+		return &qrerr.ErrDataTooLong{Needed: b.Len(), Max: requiredBitsCount, Level: rune(lvl)}
+	}
+
+	b.AppendBits(0, util.Min(microTerminatorLengths[version], requiredBitsCount-b.Len()))
+
+	multiple := (b.Len() + util.QrCodewordSize - 1) / util.QrCodewordSize * util.QrCodewordSize
+	b.AppendBits(0, util.Min(multiple, requiredBitsCount)-b.Len())
+
+	for paddingByteIndex := 0; requiredBitsCount-b.Len() >= util.QrCodewordSize; paddingByteIndex++ {
+		b.AppendString(encoder.QR_PADDING_BYTES[encoder.QrPaddingByte(paddingByteIndex%2)])
+	}
+
+	b.AppendBits(0, requiredBitsCount-b.Len())
+	return nil
+}
+
+func (e *QrMicroEncoder) GetFinalMessage(encoded string, version MicroVersion, lvl versioner.QrEcLevel) (string, error) {
+	b, err := bitbuf.FromString(encoded)
+	if err != nil {
+		return "", err
+	}
+
+	result, err := e.GetFinalMessageBits(b, version, lvl)
+	if err != nil {
+		return "", err
+	}
+	return result.String(), nil
+}
+
+// GetFinalMessageBits appends the error correction codewords to the data codewords.
+// Micro QR symbols consist of a single block, so no interleaving is necessary.
+func (e *QrMicroEncoder) GetFinalMessageBits(encoded *bitbuf.Buffer, version MicroVersion, lvl versioner.QrEcLevel) (*bitbuf.Buffer, error) {
+	info, ok := microSymbolInfos[version][lvl]
+	if !ok {
+		return nil, fmt.Errorf("%w %s-%c", qrerr.ErrInvalidVersion, version, lvl)
+	}
+
+	// The 4 bit last codeword of M1 and M3 symbols enters the division padded with zeros
+	errCorrCodewords, err := ec.New().GetErrorCorrectionBytes(encoded.Bytes(), info.ECCodewords)
+	if err != nil {
+		return nil, err
+	}
+
+	result := bitbuf.New()
+	result.AppendBuffer(encoded)
+	result.AppendBytes(errCorrCodewords)
+
+	return result, nil
+}
+
+func (v MicroVersion) String() string {
+	return "M" + strconv.Itoa(int(v))
+}
+
+var microModeIndices = map[versioner.QrMode]int{
+	versioner.QrNumericMode:      0,
+	versioner.QrAlphanumericMode: 1,
+	versioner.QrByteMode:         2,
+}
+
+var microModeIndicators = map[MicroVersion]map[versioner.QrMode]string{
+	MicroM1: {
+		versioner.QrNumericMode: "",
+	},
+	MicroM2: {
+		versioner.QrNumericMode:      "0",
+		versioner.QrAlphanumericMode: "1",
+	},
+	MicroM3: {
+		versioner.QrNumericMode:      "00",
+		versioner.QrAlphanumericMode: "01",
+		versioner.QrByteMode:         "10",
+	},
+	MicroM4: {
+		versioner.QrNumericMode:      "000",
+		versioner.QrAlphanumericMode: "001",
+		versioner.QrByteMode:         "010",
+	},
+}
+
+var microCountIndLengths = map[MicroVersion]map[versioner.QrMode]int{
+	MicroM1: {
+		versioner.QrNumericMode: 3,
+	},
+	MicroM2: {
+		versioner.QrNumericMode:      4,
+		versioner.QrAlphanumericMode: 3,
+	},
+	MicroM3: {
+		versioner.QrNumericMode:      5,
+		versioner.QrAlphanumericMode: 4,
+		versioner.QrByteMode:         4,
+	},
+	MicroM4: {
+		versioner.QrNumericMode:      6,
+		versioner.QrAlphanumericMode: 5,
+		versioner.QrByteMode:         5,
+	},
+}
+
+var microTerminatorLengths = map[MicroVersion]int{
+	MicroM1: 3,
+	MicroM2: 5,
+	MicroM3: 7,
+	MicroM4: 9,
+}
+
+// Character capacities are listed in numeric, alphanumeric, byte order.
+// Format strings are indexed by the Micro QR mask pattern reference.
+var microSymbolInfos = map[MicroVersion]map[versioner.QrEcLevel]microSymbolInfo{
+	MicroM1: {
+		versioner.QrEcLow: {20, 2, []int{5, 0, 0}, []string{"100010001000101", "100000101110010", "100111000101011", "100101100011100"}},
+	},
+	MicroM2: {
+		versioner.QrEcLow:    {40, 5, []int{10, 6, 0}, []string{"101010110101110", "101000010011001", "101111111000000", "101101011110111"}},
+		versioner.QrEcMedium: {32, 6, []int{8, 5, 0}, []string{"110011110010011", "110001010100100", "110110111111101", "110100011001010"}},
+	},
+	MicroM3: {
+		versioner.QrEcLow:    {84, 6, []int{23, 14, 9}, []string{"111011001111000", "111001101001111", "111110000010110", "111100100100001"}},
+		versioner.QrEcMedium: {68, 8, []int{18, 11, 7}, []string{"000011011011110", "000001111101001", "000110010110000", "000100110000111"}},
+	},
+	MicroM4: {
+		versioner.QrEcLow:      {128, 8, []int{35, 21, 15}, []string{"001011100110101", "001001000000010", "001110101011011", "001100001101100"}},
+		versioner.QrEcMedium:   {112, 10, []int{30, 18, 13}, []string{"010010100001000", "010000000111111", "010111101100110", "010101001010001"}},
+		versioner.QrEcQuartile: {80, 14, []int{21, 13, 9}, []string{"011010011100011", "011000111010100", "011111010001101", "011101110111010"}},
+	},
+}
@@ -0,0 +1,229 @@
+package micro
+
+import (
+	"qr/qr-gen/qrerr"
+	"qr/qr-gen/util"
+	"qr/qr-gen/versioner"
+	"strconv"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMicroVersion(t *testing.T) {
+	assert := assert.New(t)
+	e := NewEncoder()
+
+	tests := []struct {
+		input    string
+		mode     versioner.QrMode
+		lvl      versioner.QrEcLevel
+		expected MicroVersion
+	}{
+		{"12345", versioner.QrNumericMode, versioner.QrEcLow, MicroM1},
+		{"123456", versioner.QrNumericMode, versioner.QrEcLow, MicroM2},
+		{"12345", versioner.QrNumericMode, versioner.QrEcMedium, MicroM2},
+		{"HELLO", versioner.QrAlphanumericMode, versioner.QrEcLow, MicroM2},
+		{"hello", versioner.QrByteMode, versioner.QrEcLow, MicroM3},
+		{"hello", versioner.QrByteMode, versioner.QrEcQuartile, MicroM4},
+	}
+
+	for _, test := range tests {
+		actual, err := e.GetVersion(test.input, test.mode, test.lvl)
+		assert.NoError(err)
+		assert.Equal(test.expected, actual, "Micro QR versions should match")
+	}
+
+	_, err := e.GetVersion("12345", versioner.QrNumericMode, versioner.QrECHigh)
+	assert.ErrorIs(err, qrerr.ErrInvalidLevel, "High error correction is not available for Micro QR")
+
+	_, err = e.GetVersion("hello world, hello", versioner.QrByteMode, versioner.QrEcQuartile)
+	var tooLong *qrerr.ErrDataTooLong
+	if assert.ErrorAs(err, &tooLong, "Input should not fit in a Micro QR symbol") {
+		assert.Equal(qrerr.ErrDataTooLong{Needed: 3 + 5 + 18*8, Max: 80, Level: 'Q'}, *tooLong, "error details should match")
+	}
+}
+
+func TestMicroCapacities(t *testing.T) {
+	assert := assert.New(t)
+
+	bitsPerChars := []func(n int) int{
+		func(n int) int { return 10*(n/3) + []int{0, 4, 7}[n%3] },
+		func(n int) int { return 11*(n/2) + 6*(n%2) },
+		func(n int) int { return 8 * n },
+	}
+	modes := []versioner.QrMode{versioner.QrNumericMode, versioner.QrAlphanumericMode, versioner.QrByteMode}
+
+	for version, infos := range microSymbolInfos {
+		for lvl, info := range infos {
+			for i, mode := range modes {
+				capacity := info.CharCapacity[i]
+				if capacity == 0 {
+					continue
+				}
+
+				header := len(microModeIndicators[version][mode]) + microCountIndLengths[version][mode]
+				assert.LessOrEqual(header+bitsPerChars[i](capacity), info.DataBits, "capacity should fit for %s-%c", version, lvl)
+				assert.Greater(header+bitsPerChars[i](capacity+1), info.DataBits, "capacity should be maximal for %s-%c", version, lvl)
+			}
+		}
+	}
+}
+
+func TestMicroEncoding(t *testing.T) {
+	assert := assert.New(t)
+	e := NewEncoder()
+
+	encoded, version, err := e.Encode("01234567", versioner.QrEcLow)
+	assert.NoError(err)
+	assert.Equal(MicroM2, version, "Micro QR versions should match")
+	assert.Equal("01000000000110001010110011000011", encoded, "Encoded input should match")
+
+	augmented, err := e.AugmentEncodedInput(encoded, version, versioner.QrEcLow)
+	assert.NoError(err)
+	assert.Equal("0100000000011000101011001100001100000000", augmented, "Augmented input should match")
+
+	final, _ := e.GetFinalMessage(augmented, version, versioner.QrEcLow)
+	assert.Equal(augmented+"1000011000001101001000101010111000110000", final, "Error correction codewords should match")
+}
+
+func TestMicroHalfCodewordAugmentation(t *testing.T) {
+	assert := assert.New(t)
+	e := NewEncoder()
+
+	encoded, version, _ := e.Encode("123", versioner.QrEcLow)
+	assert.Equal(MicroM1, version, "Micro QR versions should match")
+
+	augmented, err := e.AugmentEncodedInput(encoded, version, versioner.QrEcLow)
+	assert.NoError(err)
+	assert.Equal("01100011110110000000", augmented, "Augmented input should end with a 4 bit codeword")
+
+	final, _ := e.GetFinalMessage(augmented, version, versioner.QrEcLow)
+	assert.Equal(20+2*util.QrCodewordSize, len(final), "Final message should fill the data modules")
+
+	_, err = e.AugmentEncodedInput(augmented+"0", version, versioner.QrEcLow)
+	assert.ErrorIs(err, qrerr.ErrCapacityExceeded, "Encoded data exceeding the symbol should be rejected")
+}
+
+func TestMicroModuleMatrix(t *testing.T) {
+	assert := assert.New(t)
+
+	for _, test := range []struct {
+		input string
+		lvl   versioner.QrEcLevel
+		size  int
+	}{
+		{"123", versioner.QrEcLow, 11},
+		{"01234567", versioner.QrEcLow, 13},
+		{"HELLO WORLD", versioner.QrEcMedium, 15},
+		{"hello, world", versioner.QrEcLow, 17},
+	} {
+		matrix, err := Generate(test.input, test.lvl)
+		assert.NoError(err)
+		assert.Equal(test.size+2*DefaultQuietZone, len(matrix.GetMatrix()), "Symbol sizes should match")
+
+		counts := map[util.ModuleRole]int{}
+		util.ForEachModule(matrix.GetMatrix(), func(row, col int, dark bool, role util.ModuleRole) {
+			counts[role] += 1
+		})
+
+		assert.Equal(49, counts[util.ModuleRole_FINDER], "Micro QR symbols have a single finder pattern")
+		assert.Equal(15, counts[util.ModuleRole_FORMAT], "Format modules should match")
+		assert.Equal(2*(test.size-8), counts[util.ModuleRole_TIMING], "Timing modules should match")
+		assert.Zero(counts[util.ModuleRole_EMPTY], "No module should stay empty")
+		assert.Zero(counts[util.ModuleRole_RESERVED], "No module should stay reserved")
+	}
+}
+
+func TestMicroMaskSelection(t *testing.T) {
+	assert := assert.New(t)
+	e := NewEncoder()
+
+	encoded, version, _ := e.Encode("01234567", versioner.QrEcLow)
+	encoded, _ = e.AugmentEncodedInput(encoded, version, versioner.QrEcLow)
+	data, _ := e.GetFinalMessage(encoded, version, versioner.QrEcLow)
+
+	matrix, evaluation, err := NewModuler(version, versioner.QrEcLow).CreateModuleMatrix(data)
+	assert.NoError(err)
+	assert.Equal(1, evaluation.Mask, "Selected mask should match the reference symbol")
+
+	format := ""
+	for col := 1; col <= 8; col++ {
+		format += strconv.Itoa(moduleBit(matrix.GetMatrix()[8+DefaultQuietZone][col+DefaultQuietZone]))
+	}
+	for row := 7; row >= 1; row-- {
+		format += strconv.Itoa(moduleBit(matrix.GetMatrix()[row+DefaultQuietZone][8+DefaultQuietZone]))
+	}
+	assert.Equal(microSymbolInfos[MicroM2][versioner.QrEcLow].FormatStrings[1], format, "Format information should match")
+}
+
+func TestMicroModulerErrors(t *testing.T) {
+	assert := assert.New(t)
+	e := NewEncoder()
+
+	encoded, version, _ := e.Encode("123", versioner.QrEcLow)
+	encoded, _ = e.AugmentEncodedInput(encoded, version, versioner.QrEcLow)
+	data, _ := e.GetFinalMessage(encoded, version, versioner.QrEcLow)
+
+	_, _, err := NewModuler(MicroM1, versioner.QrEcLow).CreateModuleMatrix(data)
+	assert.NoError(err)
+
+	_, _, err = NewModuler(9, versioner.QrEcLow).CreateModuleMatrix(data)
+	assert.ErrorIs(err, qrerr.ErrInvalidVersion, "unknown versions should be rejected")
+	_, _, err = NewModuler(MicroM1, versioner.QrEcMedium).CreateModuleMatrix(data)
+	assert.ErrorIs(err, qrerr.ErrInvalidLevel, "M1 symbols only provide error detection")
+
+	_, _, err = NewModuler(MicroM1, versioner.QrEcLow).CreateModuleMatrix(data[1:])
+	assert.ErrorIs(err, qrerr.ErrInvalidLength, "short data should be rejected")
+	_, _, err = NewModuler(MicroM1, versioner.QrEcLow).CreateModuleMatrix(data + "0")
+	assert.ErrorIs(err, qrerr.ErrInvalidLength, "long data should be rejected")
+}
+
+func moduleBit(module util.Module) int {
+	if util.IsModuleLighten(module) {
+		return 0
+	}
+	return 1
+}
+
+func TestMicroQuietZone(t *testing.T) {
+	assert := assert.New(t)
+
+	for _, quietZone := range []int{0, 4} {
+		matrix, err := GenerateWithOptions("01234567", versioner.QrEcLow, Options{QuietZone: quietZone})
+		assert.NoError(err)
+		assert.Equal(13+2*quietZone, matrix.Width(), "symbol sizes should match")
+	}
+
+	_, err := GenerateWithOptions("01234567", versioner.QrEcLow, Options{QuietZone: -1})
+	assert.ErrorIs(err, qrerr.ErrInvalidInput, "negative quiet zones should be rejected")
+}
+
+func TestMicroConcurrentGeneration(t *testing.T) {
+	assert := assert.New(t)
+	inputs := []string{"1", "01234567", "HELLO", "hello world", "12345678901234567890"}
+
+	expected := make([][][]util.Module, len(inputs))
+	for i, input := range inputs {
+		matrix, err := Generate(input, versioner.QrEcLow)
+		assert.NoError(err)
+		expected[i] = matrix.GetMatrix()
+	}
+
+	actual := make([][][]util.Module, 500)
+	var wg sync.WaitGroup
+	for n := range actual {
+		wg.Add(1)
+		go func(n int) {
+			defer wg.Done()
+			matrix, _ := Generate(inputs[n%len(inputs)], versioner.QrEcLow)
+			actual[n] = matrix.GetMatrix()
+		}(n)
+	}
+	wg.Wait()
+
+	for n := range actual {
+		assert.Equal(expected[n%len(inputs)], actual[n], "concurrent and serial symbols should match")
+	}
+}
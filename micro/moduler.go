@@ -0,0 +1,302 @@
+package micro
+
+import (
+	"fmt"
+	"qr/qr-gen/bitbuf"
+	"qr/qr-gen/matrix"
+	"qr/qr-gen/qrerr"
+	"qr/qr-gen/util"
+	"qr/qr-gen/versioner"
+)
+
+type MicroModulerInterface interface {
+	CreateModuleMatrix(data string) (*matrix.Matrix[util.Module], Evaluation, error)
+	CreateModuleMatrixFromBits(data *bitbuf.Buffer) (*matrix.Matrix[util.Module], Evaluation, error)
+}
+
+// MicroModuler builds Micro QR symbols of a single version and level. A moduler keeps no
+// state between symbols, so it may be shared by goroutines.
+type MicroModuler struct {
+	version      MicroVersion
+	ecLevel      versioner.QrEcLevel
+	options      Options
+	moduleMatrix *matrix.Matrix[util.Module]
+}
+
+// Options configures the symbols built by the moduler. Start from DefaultOptions, as the
+// zero value has no quiet zone.
+type Options struct {
+	// QuietZone is the width in modules of the light margin around the symbol
+	QuietZone int
+}
+
+type coordinates struct {
+	row int
+	col int
+}
+
+// Evaluation holds the score of the selected mask. Unlike regular QR codes,
+// Micro QR symbols are evaluated on their dark edge modules and the highest score wins.
+type Evaluation struct {
+	Mask  int
+	Score int
+}
+
+const finderPatternSize = 7
+
+// DefaultQuietZone is the quiet zone width required by the specification
+const DefaultQuietZone = 2
+
+var maskFormula = map[int]func(coordinates) bool{
+	0: func(c coordinates) bool {
+		return c.row%2 == 0
+	},
+	1: func(c coordinates) bool {
+		return (c.row/2+c.col/3)%2 == 0
+	},
+	2: func(c coordinates) bool {
+		return ((c.row*c.col)%2+(c.row*c.col)%3)%2 == 0
+	},
+	3: func(c coordinates) bool {
+		return ((c.row+c.col)%2+(c.row*c.col)%3)%2 == 0
+	},
+}
+
+// DefaultOptions surrounds the symbol with the quiet zone of the specification
+func DefaultOptions() Options {
+	return Options{QuietZone: DefaultQuietZone}
+}
+
+func NewModuler(version MicroVersion, ecLevel versioner.QrEcLevel) MicroModulerInterface {
+	return NewModulerWithOptions(version, ecLevel, DefaultOptions())
+}
+
+func NewModulerWithOptions(version MicroVersion, ecLevel versioner.QrEcLevel, options Options) MicroModulerInterface {
+	return &MicroModuler{
+		version: version,
+		ecLevel: ecLevel,
+		options: options,
+	}
+}
+
+func (m *MicroModuler) CreateModuleMatrix(data string) (*matrix.Matrix[util.Module], Evaluation, error) {
+	b, err := bitbuf.FromString(data)
+	if err != nil {
+		return nil, Evaluation{}, err
+	}
+
+	return m.CreateModuleMatrixFromBits(b)
+}
+
+// CreateModuleMatrixFromBits places the final message in the symbol and applies the mask
+// with the highest score. The data must fill every data module of the symbol.
+func (m *MicroModuler) CreateModuleMatrixFromBits(data *bitbuf.Buffer) (*matrix.Matrix[util.Module], Evaluation, error) {
+	if err := m.validate(); err != nil {
+		return nil, Evaluation{}, err
+	}
+
+	return m.copy().createModuleMatrix(data)
+}
+
+func (m *MicroModuler) validate() error {
+	infos, ok := microSymbolInfos[m.version]
+	if !ok {
+		return fmt.Errorf("%w %d for Micro QR", qrerr.ErrInvalidVersion, int(m.version))
+	}
+
+	if _, ok := infos[m.ecLevel]; !ok {
+		return fmt.Errorf("%w %q for %s", qrerr.ErrInvalidLevel, m.ecLevel, m.version)
+	}
+
+	return nil
+}
+
+func (m *MicroModuler) copy() *MicroModuler {
+	return &MicroModuler{version: m.version, ecLevel: m.ecLevel, options: m.options}
+}
+
+func (m *MicroModuler) createModuleMatrix(data *bitbuf.Buffer) (*matrix.Matrix[util.Module], Evaluation, error) {
+	m.prepareModuleMatrix()
+
+	if capacity := m.dataModulesCount(); data.Len() != capacity {
+		return nil, Evaluation{}, fmt.Errorf("%w: %d data bits for %d data modules", qrerr.ErrInvalidLength, data.Len(), capacity)
+	}
+
+	moduleCoords := m.placeDataBits(data)
+	matrix, evaluation := m.getBestMaskedMatrix(moduleCoords)
+	if err := matrix.Expand(m.options.QuietZone, util.Module_QUIET_ZONE); err != nil {
+		return nil, Evaluation{}, err
+	}
+
+	return matrix, evaluation, nil
+}
+
+func (m *MicroModuler) prepareModuleMatrix() {
+	size := m.microCodeSize()
+	m.moduleMatrix = matrix.NewMatrix[util.Module](size, size)
+	m.moduleMatrix.Init(util.Module_EMPTY)
+
+	m.setFinderPattern()
+	m.setTimingPatterns()
+	m.reserveFormatArea()
+}
+
+func (m *MicroModuler) dataModulesCount() int {
+	return m.moduleMatrix.Count(func(module util.Module) bool {
+		return module == util.Module_EMPTY
+	})
+}
+
+func (m *MicroModuler) microCodeSize() int {
+	return 2*int(m.version) + 9
+}
+
+// Sets the single finder pattern and its separator in the top left corner
+func (m *MicroModuler) setFinderPattern() {
+	for i := 0; i < finderPatternSize; i++ {
+		for j := 0; j < finderPatternSize; j++ {
+			isBorder := i == 0 || j == 0 || i == finderPatternSize-1 || j == finderPatternSize-1
+			isCenter := i >= 2 && i <= finderPatternSize-3 && j >= 2 && j <= finderPatternSize-3
+
+			if isBorder || isCenter {
+				m.moduleMatrix.Set(i, j, util.Module_FINDER_DARKEN)
+			} else {
+				m.moduleMatrix.Set(i, j, util.Module_FINDER_LIGHTEN)
+			}
+		}
+	}
+
+	for i := 0; i <= finderPatternSize; i++ {
+		m.moduleMatrix.Set(finderPatternSize, i, util.Module_SEPARATOR)
+		m.moduleMatrix.Set(i, finderPatternSize, util.Module_SEPARATOR)
+	}
+}
+
+// Sets the timing patterns along the top row and the left column
+func (m *MicroModuler) setTimingPatterns() {
+	for i := finderPatternSize + 1; i < m.microCodeSize(); i++ {
+		module := util.Module_TIMING_DARKEN
+		if i%2 == 1 {
+			module = util.Module_TIMING_LIGHTEN
+		}
+
+		m.moduleMatrix.Set(0, i, module)
+		m.moduleMatrix.Set(i, 0, module)
+	}
+}
+
+// Sets the reserved format information area next to the finder pattern
+func (m *MicroModuler) reserveFormatArea() {
+	for i := 1; i <= finderPatternSize+1; i++ {
+		m.moduleMatrix.Set(finderPatternSize+1, i, util.Module_RESERVED)
+		m.moduleMatrix.Set(i, finderPatternSize+1, util.Module_RESERVED)
+	}
+}
+
+// Places the encoded data bits in the module matrix, moving in two module wide
+// columns from the bottom right corner. The timing column is the leftmost one,
+// so no column has to be skipped.
+func (m *MicroModuler) placeDataBits(data *bitbuf.Buffer) []coordinates {
+	var moduleCoords []coordinates
+	size := m.microCodeSize()
+	indexInBits := 0
+	isUpwardMovement := true
+
+	for col := size - 1; col > 0; col -= 2 {
+		for k := 0; k < size; k++ {
+			row := k
+			if isUpwardMovement {
+				row = size - 1 - k
+			}
+
+			for _, c := range []int{col, col - 1} {
+				if val, _ := m.moduleMatrix.At(row, c); val != util.Module_EMPTY {
+					continue
+				}
+
+				m.moduleMatrix.Set(row, c, util.GetDataModule(data.Bit(indexInBits)))
+				moduleCoords = append(moduleCoords, coordinates{row: row, col: c})
+				indexInBits += 1
+			}
+		}
+
+		isUpwardMovement = !isUpwardMovement
+	}
+
+	return moduleCoords
+}
+
+// Masks the data modules with every mask pattern and keeps the highest scoring candidate
+func (m *MicroModuler) getBestMaskedMatrix(moduleCoords []coordinates) (*matrix.Matrix[util.Module], Evaluation) {
+	var best *matrix.Matrix[util.Module]
+	evaluation := Evaluation{Mask: -1, Score: -1}
+
+	for rule := 0; rule < len(maskFormula); rule++ {
+		candidate := m.maskModuleMatrix(moduleCoords, rule)
+
+		if score := m.evaluateMatrixCandidate(candidate); score > evaluation.Score {
+			best = candidate
+			evaluation = Evaluation{Mask: rule, Score: score}
+		}
+	}
+
+	return best, evaluation
+}
+
+// Masks a module matrix based on the given rule
+func (m *MicroModuler) maskModuleMatrix(moduleCoords []coordinates, rule int) *matrix.Matrix[util.Module] {
+	matrixCandidate := m.moduleMatrix.Clone()
+	m.setFormatInformationModules(matrixCandidate, rule)
+
+	for _, c := range moduleCoords {
+		if !maskFormula[rule](c) {
+			continue
+		}
+
+		if val, _ := m.moduleMatrix.At(c.row, c.col); val == util.Module_LIGHTEN {
+			matrixCandidate.Set(c.row, c.col, util.Module_DARKEN)
+		} else {
+			matrixCandidate.Set(c.row, c.col, util.Module_LIGHTEN)
+		}
+	}
+
+	return matrixCandidate
+}
+
+// Sets the 15 format information bits, most significant first, along the row
+// below the finder pattern and then upwards along the column right of it
+func (m *MicroModuler) setFormatInformationModules(matrix *matrix.Matrix[util.Module], rule int) {
+	format := microSymbolInfos[m.version][m.ecLevel].FormatStrings[rule]
+	index := 0
+
+	for i := 1; i <= finderPatternSize+1; i++ {
+		matrix.Set(finderPatternSize+1, i, util.GetFormatModule(int(format[index]-'0')))
+		index += 1
+	}
+
+	for i := finderPatternSize; i >= 1; i-- {
+		matrix.Set(i, finderPatternSize+1, util.GetFormatModule(int(format[index]-'0')))
+		index += 1
+	}
+}
+
+// Computes the score based on the dark modules of the right and bottom edges,
+// excluding the timing pattern modules
+func (m *MicroModuler) evaluateMatrixCandidate(matrix *matrix.Matrix[util.Module]) int {
+	size := m.microCodeSize()
+	sumRight, sumBottom := 0, 0
+
+	for i := 1; i < size; i++ {
+		if val, _ := matrix.At(i, size-1); !util.IsModuleLighten(val) {
+			sumRight += 1
+		}
+		if val, _ := matrix.At(size-1, i); !util.IsModuleLighten(val) {
+			sumBottom += 1
+		}
+	}
+
+	if sumRight <= sumBottom {
+		return sumRight*16 + sumBottom
+	}
+	return sumBottom*16 + sumRight
+}
@@ -0,0 +1,190 @@
+package moduler
+
+import (
+	"fmt"
+	"io"
+	"qr/qr-gen/bitbuf"
+	"qr/qr-gen/matrix"
+	"qr/qr-gen/qrerr"
+	"qr/qr-gen/util"
+	"text/tabwriter"
+)
+
+// MaskSelectionReason tells why a mask was selected among the candidates
+type MaskSelectionReason int
+
+const (
+	// MaskSelectionReason_LOWEST_PENALTY is given when no other mask scores as low
+	MaskSelectionReason_LOWEST_PENALTY MaskSelectionReason = iota
+	// MaskSelectionReason_FIRST_OF_TIED is given when masks share the lowest penalty, the
+	// first one in the order of the references being selected
+	MaskSelectionReason_FIRST_OF_TIED
+	// MaskSelectionReason_ACCEPTABLE_PENALTY is given when the mask is the first one whose
+	// penalty is within MaskOptions.AcceptablePenalty
+	MaskSelectionReason_ACCEPTABLE_PENALTY
+	// MaskSelectionReason_SELECTOR is given when the mask is chosen by MaskOptions.Selector
+	MaskSelectionReason_SELECTOR
+)
+
+var maskSelectionReasonNames = map[MaskSelectionReason]string{
+	MaskSelectionReason_LOWEST_PENALTY:     "lowest penalty",
+	MaskSelectionReason_FIRST_OF_TIED:      "first of the masks tied on the lowest penalty",
+	MaskSelectionReason_ACCEPTABLE_PENALTY: "first mask with an acceptable penalty",
+	MaskSelectionReason_SELECTOR:           "chosen by the mask selector",
+}
+
+func (r MaskSelectionReason) String() string {
+	if name, ok := maskSelectionReasonNames[r]; ok {
+		return name
+	}
+	return "unknown"
+}
+
+// MaskCandidate is the symbol masked with one of the mask references, and its penalty
+type MaskCandidate struct {
+	Mask    int
+	Penalty Penalty
+	// Symbol is the masked module matrix, quiet zone included
+	Symbol    *matrix.Matrix[util.Module]
+	QuietZone int
+}
+
+// Gets a module of the symbol, the coordinates excluding the quiet zone
+func (c MaskCandidate) module(row, col int) (util.Module, error) {
+	return c.Symbol.At(row+c.QuietZone, col+c.QuietZone)
+}
+
+// Calls fn with every module of the symbol, the coordinates excluding the quiet zone
+func (c MaskCandidate) forEachModule(fn func(row, col int, module util.Module)) {
+	c.Symbol.ForEach(func(row, col int, module util.Module) {
+		row, col = row-c.QuietZone, col-c.QuietZone
+		size := c.Symbol.Width() - 2*c.QuietZone
+		if row >= 0 && col >= 0 && row < size && col < size {
+			fn(row, col, module)
+		}
+	})
+}
+
+// MaskDiagnostics reports the evaluation of every mask of a symbol and the mask selected
+// by the moduler
+type MaskDiagnostics struct {
+	// Candidates holds the eight masks, in the order of their references
+	Candidates []MaskCandidate
+	Selected   int
+	Reason     MaskSelectionReason
+}
+
+// DiagnoseMasks builds and scores the symbol with each of the eight masks. Unlike
+// CreateModuleMatrixFromBits, no candidate evaluation is cut short, so that every score
+// is reported, but the selected mask is the same, the mask selector included.
+func (m *Moduler) DiagnoseMasks(data *bitbuf.Buffer) (*MaskDiagnostics, error) {
+	if err := m.validate(); err != nil {
+		return nil, err
+	}
+
+	return m.copy().diagnoseMasks(data)
+}
+
+func (m *Moduler) diagnoseMasks(data *bitbuf.Buffer) (*MaskDiagnostics, error) {
+	moduleCoords, err := m.placeData(data)
+	if err != nil {
+		return nil, err
+	}
+
+	base := m.darkModuleGrid()
+	maskPatterns := m.maskPatternGrids()
+	formatCoords := m.formatInformationCoordinates()
+
+	diagnostics := &MaskDiagnostics{Candidates: make([]MaskCandidate, len(maskFormula))}
+	for rule := range diagnostics.Candidates {
+		grid, err := m.maskDarkModuleGrid(base, maskPatterns[rule], formatCoords, rule)
+		if err != nil {
+			return nil, err
+		}
+		penalty, _ := m.evaluateDarkModuleGrid(grid, func(int) bool { return false })
+
+		symbol, err := m.maskedSymbol(moduleCoords, rule)
+		if err != nil {
+			return nil, err
+		}
+
+		diagnostics.Candidates[rule] = MaskCandidate{Mask: rule, Penalty: penalty, Symbol: symbol, QuietZone: m.options.QuietZone}
+	}
+
+	diagnostics.Selected, diagnostics.Reason, err = m.explainSelection(diagnostics.Candidates)
+	if err != nil {
+		return nil, err
+	}
+
+	return diagnostics, nil
+}
+
+// Selects the mask of the fully evaluated candidates with the mask selector, or as
+// selectMask does without one
+func (m *Moduler) explainSelection(candidates []MaskCandidate) (int, MaskSelectionReason, error) {
+	if m.hasCustomSelector() {
+		mask := m.options.Mask.Selector.SelectMask(candidates)
+		if mask < 0 || mask >= len(candidates) {
+			return 0, 0, fmt.Errorf("%w: mask %d selected", qrerr.ErrInvalidInput, mask)
+		}
+		return mask, MaskSelectionReason_SELECTOR, nil
+	}
+
+	for _, candidate := range candidates {
+		if m.isPenaltyAcceptable(candidate.Penalty) {
+			return candidate.Mask, MaskSelectionReason_ACCEPTABLE_PENALTY, nil
+		}
+	}
+
+	best, ties := 0, 0
+	for rule, candidate := range candidates {
+		if candidate.Penalty.Total < candidates[best].Penalty.Total {
+			best, ties = rule, 0
+		} else if candidate.Penalty.Total == candidates[best].Penalty.Total && rule != best {
+			ties += 1
+		}
+	}
+
+	if ties > 0 {
+		return best, MaskSelectionReason_FIRST_OF_TIED, nil
+	}
+	return best, MaskSelectionReason_LOWEST_PENALTY, nil
+}
+
+// The penalty selector is the default selection, evaluated without building every candidate
+func (m *Moduler) hasCustomSelector() bool {
+	if m.options.Mask.Selector == nil {
+		return false
+	}
+	_, isPenalty := m.options.Mask.Selector.(*PenaltySelector)
+	return !isPenalty
+}
+
+// SelectedCandidate returns the candidate of the selected mask
+func (d *MaskDiagnostics) SelectedCandidate() MaskCandidate {
+	return d.Candidates[d.Selected]
+}
+
+// WriteTable writes the score of every rule for each mask, the selected one being starred,
+// followed by the reason of the selection
+func (d *MaskDiagnostics) WriteTable(w io.Writer) error {
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', tabwriter.AlignRight)
+	fmt.Fprintln(tw, "mask\tadjacent\tblocks\tfinder-like\tbalance\ttotal\t\t")
+
+	for _, candidate := range d.Candidates {
+		selected := ""
+		if candidate.Mask == d.Selected {
+			selected = "*"
+		}
+
+		p := candidate.Penalty
+		fmt.Fprintf(tw, "%d\t%d\t%d\t%d\t%d\t%d\t%s\t\n", candidate.Mask, p.AdjacentModules, p.Blocks, p.FinderLike, p.Balance, p.Total, selected)
+	}
+
+	if err := tw.Flush(); err != nil {
+		return err
+	}
+
+	_, err := fmt.Fprintf(w, "Mask %d selected: %s\n", d.Selected, d.Reason)
+	return err
+}
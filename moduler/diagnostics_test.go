@@ -0,0 +1,83 @@
+package moduler
+
+import (
+	"qr/qr-gen/matrix"
+	"qr/qr-gen/qrerr"
+	"qr/qr-gen/versioner"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDiagnoseMasks(t *testing.T) {
+	assert := assert.New(t)
+
+	for _, version := range []versioner.QrVersion{1, 2, 7, 10, 25} {
+		for seed := 0; seed < 4; seed++ {
+			data := finalMessage(version, versioner.QrEcMedium, seed)
+			m := New(version, versioner.QrEcMedium)
+
+			symbol, penalty, _ := m.CreateModuleMatrixFromBits(data)
+			diagnostics, err := m.DiagnoseMasks(data)
+			assert.NoError(err)
+			assert.Len(diagnostics.Candidates, 8, "every mask should be evaluated")
+
+			selected := diagnostics.SelectedCandidate()
+			assert.Equal(penalty, selected.Penalty, "diagnostics should select the same mask")
+			assert.True(matrix.Equal(symbol, selected.Symbol), "selected candidate should be the generated symbol")
+
+			for rule, candidate := range diagnostics.Candidates {
+				p := candidate.Penalty
+				assert.Equal(rule, candidate.Mask, "candidates should be in the order of the references")
+				assert.Equal(p.AdjacentModules+p.Blocks+p.FinderLike+p.Balance, p.Total, "total should be the sum of the scores")
+				assert.LessOrEqual(selected.Penalty.Total, p.Total, "selected mask should have the lowest penalty")
+			}
+		}
+	}
+}
+
+func TestDiagnoseMasksReasons(t *testing.T) {
+	assert := assert.New(t)
+	data := finalMessage(2, versioner.QrEcMedium, 0)
+
+	diagnostics, _ := New(2, versioner.QrEcMedium).DiagnoseMasks(data)
+	assert.NotEqual(MaskSelectionReason_ACCEPTABLE_PENALTY, diagnostics.Reason, "no penalty should be acceptable by default")
+
+	acceptable := diagnostics.Candidates[0].Penalty.Total
+	_, early, _ := NewWithOptions(2, versioner.QrEcMedium, withMask(MaskOptions{AcceptablePenalty: acceptable})).CreateModuleMatrixFromBits(data)
+	diagnostics, _ = NewWithOptions(2, versioner.QrEcMedium, withMask(MaskOptions{AcceptablePenalty: acceptable})).DiagnoseMasks(data)
+	assert.Equal(0, diagnostics.Selected, "first acceptable mask should be selected")
+	assert.Equal(MaskSelectionReason_ACCEPTABLE_PENALTY, diagnostics.Reason, "reasons should match")
+	assert.Equal(early, diagnostics.SelectedCandidate().Penalty, "diagnostics should select the same mask")
+
+	tied := []MaskCandidate{{Mask: 0, Penalty: Penalty{Total: 9}}, {Mask: 1, Penalty: Penalty{Total: 5}}, {Mask: 2, Penalty: Penalty{Total: 5}}}
+	selected, reason, _ := (&Moduler{}).explainSelection(tied)
+	assert.Equal(1, selected, "first of the tied masks should be selected")
+	assert.Equal(MaskSelectionReason_FIRST_OF_TIED, reason, "reasons should match")
+	assert.Equal("first of the masks tied on the lowest penalty", reason.String(), "reason names should match")
+}
+
+func TestDiagnoseMasksTable(t *testing.T) {
+	assert := assert.New(t)
+
+	diagnostics, _ := New(1, versioner.QrEcLow).DiagnoseMasks(finalMessage(1, versioner.QrEcLow, 0))
+	var b strings.Builder
+	assert.NoError(diagnostics.WriteTable(&b))
+
+	lines := strings.Split(strings.TrimSuffix(b.String(), "\n"), "\n")
+	assert.Len(lines, 10, "table should have a header, a line per mask and the reason")
+	assert.Equal("mask  adjacent  blocks  finder-like  balance  total", strings.TrimSpace(lines[0]), "headers should match")
+	assert.True(strings.HasSuffix(lines[1+diagnostics.Selected], "*"), "selected mask should be starred")
+	assert.Contains(lines[9], "selected: ", "reason should be given")
+}
+
+func TestDiagnoseMasksErrors(t *testing.T) {
+	assert := assert.New(t)
+
+	_, err := New(41, versioner.QrEcLow).DiagnoseMasks(finalMessage(40, versioner.QrEcLow, 0))
+	assert.ErrorIs(err, qrerr.ErrInvalidVersion)
+
+	_, err = New(2, versioner.QrEcLow).DiagnoseMasks(finalMessage(1, versioner.QrEcLow, 0))
+	assert.ErrorIs(err, qrerr.ErrInvalidLength)
+}
@@ -3,22 +3,52 @@ package moduler
 import (
 	"fmt"
 	"math"
+	"math/bits"
+	"qr/qr-gen/bitbuf"
 	"qr/qr-gen/matrix"
+	"qr/qr-gen/qrerr"
 	"qr/qr-gen/util"
 	"qr/qr-gen/versioner"
-	"strconv"
+	"sync"
+	"sync/atomic"
 )
 
 type ModulerInterface interface {
-	CreateModuleMatrix(data string) (*matrix.Matrix[util.Module], Penalty)
+	CreateModuleMatrix(data string) (*matrix.Matrix[util.Module], Penalty, error)
+	CreateModuleMatrixFromBits(data *bitbuf.Buffer) (*matrix.Matrix[util.Module], Penalty, error)
+	DiagnoseMasks(data *bitbuf.Buffer) (*MaskDiagnostics, error)
 }
 
 type Moduler struct {
 	version      versioner.QrVersion
 	ecLevel      versioner.QrEcLevel
+	options      Options
 	moduleMatrix *matrix.Matrix[util.Module]
 }
 
+// Options configures the symbols built by the moduler. Start from DefaultOptions, as the
+// zero value has no quiet zone.
+type Options struct {
+	Mask MaskOptions
+	// QuietZone is the width in modules of the light margin around the symbol:
+	// DefaultQuietZone per specification, more for harsh print conditions or none to embed
+	// the symbol in a layout providing its own margin
+	QuietZone int
+}
+
+// MaskOptions configures the evaluation of the mask candidates
+type MaskOptions struct {
+	// Parallel evaluates the candidates in concurrent goroutines
+	Parallel bool
+	// AcceptablePenalty stops the evaluation at the first mask, in the order of the mask
+	// references, whose penalty is lower or equal. Zero evaluates all the masks.
+	AcceptablePenalty int
+	// Selector chooses the mask among the candidates, such as a fixed mask for styled
+	// symbols. Nil selects the lowest penalty, as the specification requires. The other
+	// options are ignored with a selector, every candidate being built and scored.
+	Selector MaskSelector
+}
+
 type Coordinates struct {
 	row int
 	col int
@@ -29,27 +59,81 @@ type Boundary struct {
 	upper Coordinates
 }
 
+// Penalty holds the scores of the four penalty rules of the specification for a masked
+// symbol, the mask with the lowest total being the most readable
 type Penalty struct {
-	score1 int
-	score2 int
-	score3 int
-	score4 int
-	total  int
+	// AdjacentModules penalizes the runs of five or more modules of the same color in a row
+	// or a column
+	AdjacentModules int
+	// Blocks penalizes the 2x2 blocks of modules of the same color
+	Blocks int
+	// FinderLike penalizes the 1:1:3:1:1 patterns next to four light modules, which readers
+	// could mistake for finder patterns
+	FinderLike int
+	// Balance penalizes the deviation of the proportion of dark modules from one half
+	Balance int
+	Total   int
 }
 
 const finderPatternSize = 7
 
-var rulePattern = []util.Module{util.Module_DARKEN, util.Module_LIGHTEN, util.Module_DARKEN, util.Module_DARKEN, util.Module_DARKEN, util.Module_LIGHTEN, util.Module_DARKEN, util.Module_LIGHTEN, util.Module_LIGHTEN, util.Module_LIGHTEN, util.Module_LIGHTEN}
-var reversedRulePattern = []util.Module{util.Module_LIGHTEN, util.Module_LIGHTEN, util.Module_LIGHTEN, util.Module_LIGHTEN, util.Module_DARKEN, util.Module_LIGHTEN, util.Module_DARKEN, util.Module_DARKEN, util.Module_DARKEN, util.Module_LIGHTEN, util.Module_DARKEN}
-
-// These locations stand only for alignment patterns that do not overlap with finder patterns
-// This can be improved by checking the overlap programatically
-var allignmentPatternLocation = map[versioner.QrVersion][]Coordinates{
-	1: {},
-	2: {Coordinates{18, 18}},
-	3: {Coordinates{22, 22}},
-	4: {Coordinates{26, 26}},
-	5: {Coordinates{30, 30}},
+// DefaultQuietZone is the quiet zone width required by the specification
+const DefaultQuietZone = 4
+
+const versionInformationLength = 18
+const versionInformationGenerator = 0x1f25
+
+// Finder-like patterns of the third penalty strategy, a set bit being a dark module
+const rulePattern = 0b10111010000
+const reversedRulePattern = 0b00001011101
+const rulePatternLength = 11
+
+// Words of the longest line, in the largest symbol
+const maxLineWords = (177 + matrix.WordSize - 1) / matrix.WordSize
+
+// Row and column coordinates of the alignment pattern centers. The patterns are placed at
+// every combination of them, except the three overlapping the finder patterns.
+var allignmentPatternPositions = map[versioner.QrVersion][]int{
+	1:  {},
+	2:  {6, 18},
+	3:  {6, 22},
+	4:  {6, 26},
+	5:  {6, 30},
+	6:  {6, 34},
+	7:  {6, 22, 38},
+	8:  {6, 24, 42},
+	9:  {6, 26, 46},
+	10: {6, 28, 50},
+	11: {6, 30, 54},
+	12: {6, 32, 58},
+	13: {6, 34, 62},
+	14: {6, 26, 46, 66},
+	15: {6, 26, 48, 70},
+	16: {6, 26, 50, 74},
+	17: {6, 30, 54, 78},
+	18: {6, 30, 56, 82},
+	19: {6, 30, 58, 86},
+	20: {6, 34, 62, 90},
+	21: {6, 28, 50, 72, 94},
+	22: {6, 26, 50, 74, 98},
+	23: {6, 30, 54, 78, 102},
+	24: {6, 28, 54, 80, 106},
+	25: {6, 32, 58, 84, 110},
+	26: {6, 30, 58, 86, 114},
+	27: {6, 34, 62, 90, 118},
+	28: {6, 26, 50, 74, 98, 122},
+	29: {6, 30, 54, 78, 102, 126},
+	30: {6, 26, 52, 78, 104, 130},
+	31: {6, 30, 56, 82, 108, 134},
+	32: {6, 34, 60, 86, 112, 138},
+	33: {6, 30, 58, 86, 114, 142},
+	34: {6, 34, 62, 90, 118, 146},
+	35: {6, 30, 54, 78, 102, 126, 150},
+	36: {6, 24, 50, 76, 102, 128, 154},
+	37: {6, 28, 54, 80, 106, 132, 158},
+	38: {6, 32, 58, 84, 110, 136, 162},
+	39: {6, 26, 54, 82, 110, 138, 166},
+	40: {6, 30, 58, 86, 114, 142, 170},
 }
 
 var maskFormula = map[int]func(Coordinates) bool{
@@ -79,25 +163,123 @@ var maskFormula = map[int]func(Coordinates) bool{
 	},
 }
 
+// The grids of the modules toggled by every mask only depend on the version, so they are
+// computed once per version and shared. They must not be modified.
+var maskPatternCache = struct {
+	sync.Mutex
+	grids map[versioner.QrVersion][]*matrix.BitGrid
+}{grids: make(map[versioner.QrVersion][]*matrix.BitGrid)}
+
+// DefaultOptions evaluates every mask sequentially and surrounds the symbol with the quiet
+// zone of the specification
+func DefaultOptions() Options {
+	return Options{QuietZone: DefaultQuietZone}
+}
+
 func New(version versioner.QrVersion, ecLevel versioner.QrEcLevel) ModulerInterface {
+	return NewWithOptions(version, ecLevel, DefaultOptions())
+}
+
+func NewWithOptions(version versioner.QrVersion, ecLevel versioner.QrEcLevel, options Options) ModulerInterface {
 	return &Moduler{
 		version: version,
 		ecLevel: ecLevel,
+		options: options,
+	}
+}
+
+func (m *Moduler) CreateModuleMatrix(data string) (*matrix.Matrix[util.Module], Penalty, error) {
+	b, err := bitbuf.FromString(data)
+	if err != nil {
+		return nil, Penalty{}, err
+	}
+	return m.CreateModuleMatrixFromBits(b)
+}
+
+// CreateModuleMatrixFromBits places the final message in the symbol and applies the mask
+// with the lowest penalty. The symbol is built on a copy of the moduler, so that the same
+// moduler can be used by concurrent goroutines. The data must fill every data module of
+// the symbol, remainder bits included.
+func (m *Moduler) CreateModuleMatrixFromBits(data *bitbuf.Buffer) (*matrix.Matrix[util.Module], Penalty, error) {
+	if err := m.validate(); err != nil {
+		return nil, Penalty{}, err
 	}
+
+	return m.copy().createModuleMatrix(data)
 }
 
-func (m *Moduler) CreateModuleMatrix(data string) (*matrix.Matrix[util.Module], Penalty) {
-	m.prepareModuleMatrix(data)
+func (m *Moduler) validate() error {
+	if m.version < 1 || int(m.version) > len(allignmentPatternPositions) {
+		return fmt.Errorf("%w %d", qrerr.ErrInvalidVersion, m.version)
+	}
+
+	if _, ok := util.FormatInformationStrings[rune(m.ecLevel)]; !ok {
+		return fmt.Errorf("%w %q", qrerr.ErrInvalidLevel, m.ecLevel)
+	}
 
-	moduleCoords := m.placeDataBits(data)
-	candidates := m.getModuleMatrixCandidates(moduleCoords)
-	matrix, penalty := m.getBestMaskedMatrix(candidates)
-	matrix.Expand(4)
+	return nil
+}
 
-	return matrix, penalty
+func (m *Moduler) copy() *Moduler {
+	return &Moduler{version: m.version, ecLevel: m.ecLevel, options: m.options}
 }
 
-func (m *Moduler) prepareModuleMatrix(data string) {
+func (m *Moduler) createModuleMatrix(data *bitbuf.Buffer) (*matrix.Matrix[util.Module], Penalty, error) {
+	if m.hasCustomSelector() {
+		return m.createModuleMatrixWithSelector(data)
+	}
+
+	moduleCoords, err := m.placeData(data)
+	if err != nil {
+		return nil, Penalty{}, err
+	}
+
+	mask, penalty, err := m.selectMask()
+	if err != nil {
+		return nil, Penalty{}, err
+	}
+
+	matrix, err := m.maskedSymbol(moduleCoords, mask)
+	if err != nil {
+		return nil, Penalty{}, err
+	}
+
+	return matrix, penalty, nil
+}
+
+// Builds every candidate for the mask selector, which may inspect the modules of the symbols
+func (m *Moduler) createModuleMatrixWithSelector(data *bitbuf.Buffer) (*matrix.Matrix[util.Module], Penalty, error) {
+	diagnostics, err := m.diagnoseMasks(data)
+	if err != nil {
+		return nil, Penalty{}, err
+	}
+
+	selected := diagnostics.SelectedCandidate()
+	return selected.Symbol, selected.Penalty, nil
+}
+
+// Builds the function patterns and places the data in the unmasked module matrix
+func (m *Moduler) placeData(data *bitbuf.Buffer) ([]Coordinates, error) {
+	m.prepareModuleMatrix()
+
+	if capacity := m.dataModulesCount(); data.Len() != capacity {
+		return nil, fmt.Errorf("%w: %d data bits for %d data modules", qrerr.ErrInvalidLength, data.Len(), capacity)
+	}
+
+	return m.placeDataBits(data), nil
+}
+
+// Masks the module matrix and surrounds it with the quiet zone
+func (m *Moduler) maskedSymbol(moduleCoords []Coordinates, rule int) (*matrix.Matrix[util.Module], error) {
+	matrix := m.maskModuleMatrix(moduleCoords, rule)
+	if err := matrix.Expand(m.options.QuietZone, util.Module_QUIET_ZONE); err != nil {
+		return nil, fmt.Errorf("Error on adding the quiet zone: %w", err)
+	}
+
+	return matrix, nil
+}
+
+func (m *Moduler) prepareModuleMatrix() {
 	qrCodeSize := m.qrCodeSize()
 	m.moduleMatrix = matrix.NewMatrix[util.Module](qrCodeSize, qrCodeSize)
 	m.moduleMatrix.Init(util.Module_EMPTY)
@@ -109,10 +291,18 @@ func (m *Moduler) prepareModuleMatrix(data string) {
 	m.setTimingPatterns()
 	m.setDarkModule()
 	m.reserveFormatArea()
+	m.setVersionInformation()
+}
+
+// Counts the modules left empty by the function patterns, which hold the data bits
+func (m *Moduler) dataModulesCount() int {
+	return m.moduleMatrix.Count(func(module util.Module) bool {
+		return util.GetModuleRole(module) == util.ModuleRole_EMPTY
+	})
 }
 
 func (m *Moduler) qrCodeSize() int {
-	return (int(m.version)-1)*4 + 21
+	return m.version.Size()
 }
 
 // Sets the top left finder pattern in the module matrix
@@ -159,36 +349,55 @@ func (m *Moduler) setBottomLeftFinderPattern() {
 
 // Sets the alignment patterns in the module matrix
 func (m *Moduler) setAlignmentPatterns() {
-	for _, c := range allignmentPatternLocation[m.version] {
+	for _, c := range m.alignmentPatternLocations() {
 		boundary := m.alignmentPatternBoundary(c)
 		m.patchPattern(boundary, util.Module_ALIGNMENT_LIGHTEN, util.Module_ALIGNMENT_DARKEN)
 	}
 }
 
-// Sets the timing patterns in the module matrix
+func (m *Moduler) alignmentPatternLocations() []Coordinates {
+	var locations []Coordinates
+	positions := allignmentPatternPositions[m.version]
+	last := len(positions) - 1
+
+	for i, row := range positions {
+		for j, col := range positions {
+			if (i == 0 && j == 0) || (i == 0 && j == last) || (i == last && j == 0) {
+				continue
+			}
+			locations = append(locations, Coordinates{row, col})
+		}
+	}
+
+	return locations
+}
+
+// Sets the timing patterns in the module matrix, between the separators of the finder patterns
 func (m *Moduler) setTimingPatterns() {
 	topLeftFinderBoundary, _ := m.finderPatternBoundary(true, true)
 	topRightFinderBoundary, _ := m.finderPatternBoundary(true, false)
 	bottomLeftFinderBoundary, _ := m.finderPatternBoundary(false, true)
 
-	val := 1
-	for i := topLeftFinderBoundary.upper.col - 1; i < topRightFinderBoundary.lower.col; i++ {
-		if val == 0 {
-			m.moduleMatrix.Set(6, i, util.Module_TIMING_LIGHTEN)
-		} else {
-			m.moduleMatrix.Set(6, i, util.Module_TIMING_DARKEN)
-		}
-		val = (val + 1) % 2
+	for i := topLeftFinderBoundary.upper.col + 1; i < topRightFinderBoundary.lower.col-1; i++ {
+		m.setTimingModule(6, i, i)
 	}
 
-	val = 1
-	for i := topLeftFinderBoundary.upper.row - 1; i < bottomLeftFinderBoundary.lower.row; i++ {
-		if val == 0 {
-			m.moduleMatrix.Set(i, 6, util.Module_TIMING_LIGHTEN)
-		} else {
-			m.moduleMatrix.Set(i, 6, util.Module_TIMING_DARKEN)
-		}
-		val = (val + 1) % 2
+	for i := topLeftFinderBoundary.upper.row + 1; i < bottomLeftFinderBoundary.lower.row-1; i++ {
+		m.setTimingModule(i, 6, i)
+	}
+}
+
+// Sets a timing module, dark on even indices. The alignment patterns crossed by the timing
+// patterns match their colors, so they are left in place.
+func (m *Moduler) setTimingModule(row, col, index int) {
+	if val, _ := m.moduleMatrix.At(row, col); val != util.Module_EMPTY {
+		return
+	}
+
+	if index%2 == 0 {
+		m.moduleMatrix.Set(row, col, util.Module_TIMING_DARKEN)
+	} else {
+		m.moduleMatrix.Set(row, col, util.Module_TIMING_LIGHTEN)
 	}
 }
 
@@ -197,6 +406,36 @@ func (m *Moduler) setDarkModule() {
 	m.moduleMatrix.Set(4*int(m.version)+9, 8, util.Module_DARK)
 }
 
+// Sets both copies of the version information, above the bottom left finder pattern and
+// left to the top right one. Only symbols from version 7 hold it.
+func (m *Moduler) setVersionInformation() {
+	if m.version < 7 {
+		return
+	}
+
+	info := m.computeVersionInformation()
+	size := m.qrCodeSize()
+
+	for i := 0; i < versionInformationLength; i++ {
+		module := util.GetVersionModule((info >> i) & 1)
+		m.moduleMatrix.Set(i/3, size-11+i%3, module)
+		m.moduleMatrix.Set(size-11+i%3, i/3, module)
+	}
+}
+
+// Computes the 18 bit version information, made of the 6 bit version number
+// and 12 BCH error correction bits
+func (m *Moduler) computeVersionInformation() int {
+	remainder := int(m.version) << 12
+	for i := versionInformationLength - 1; i >= 12; i-- {
+		if remainder&(1<<i) != 0 {
+			remainder ^= versionInformationGenerator << (i - 12)
+		}
+	}
+
+	return int(m.version)<<12 | remainder
+}
+
 // Sets the reserved format information area in the module matrix
 func (m *Moduler) reserveFormatArea() {
 	boundary, _ := m.finderPatternBoundary(true, true)
@@ -231,7 +470,7 @@ func (m *Moduler) reserveFormatArea() {
 }
 
 // Places the encoded data bits in the module matrix
-func (m *Moduler) placeDataBits(data string) []Coordinates {
+func (m *Moduler) placeDataBits(data *bitbuf.Buffer) []Coordinates {
 	var moduleCoords []Coordinates
 	currentCellCoord := Coordinates{row: m.qrCodeSize() - 1, col: m.qrCodeSize() - 1}
 
@@ -243,7 +482,7 @@ func (m *Moduler) placeDataBits(data string) []Coordinates {
 	for currentCellCoord.col >= 0 {
 
 		if val, _ := m.moduleMatrix.At(currentCellCoord.row, currentCellCoord.col); val == util.Module_EMPTY {
-			if bit, _ := strconv.ParseInt(string(data[indexInBits]), 2, 64); bit == 0 {
+			if data.Bit(indexInBits) == 0 {
 				module = util.Module_LIGHTEN
 			} else {
 				module = util.Module_DARKEN
@@ -332,21 +571,9 @@ func (m *Moduler) alignmentPatternBoundary(c Coordinates) Boundary {
 	}
 }
 
-// Gets the masked matrix candidates based on masking formulas
-func (m *Moduler) getModuleMatrixCandidates(moduleCoords []Coordinates) []*matrix.Matrix[util.Module] {
-	matrixCandidates := make([]*matrix.Matrix[util.Module], len(maskFormula))
-
-	for i := range maskFormula {
-		matrixCandidates[i] = m.maskModuleMatrix(moduleCoords, i)
-	}
-
-	return matrixCandidates
-}
-
 // Masks a module matrix based on the given rule
 func (m *Moduler) maskModuleMatrix(moduleCoords []Coordinates, rule int) *matrix.Matrix[util.Module] {
-	matrixCandidate := matrix.NewMatrix[util.Module](m.qrCodeSize(), m.qrCodeSize())
-	matrixCandidate.SetMatrix(m.moduleMatrix.GetMatrix())
+	matrixCandidate := m.moduleMatrix.Clone()
 	m.setFormatInformationModules(matrixCandidate, rule)
 
 	for _, c := range moduleCoords {
@@ -370,159 +597,321 @@ func (m *Moduler) toggleModule(c Coordinates) util.Module {
 	return util.Module_LIGHTEN
 }
 
+// Sets the format information of the given mask in both copies of the reserved area
 func (m *Moduler) setFormatInformationModules(matrix *matrix.Matrix[util.Module], rule int) {
-	boundary, _ := m.finderPatternBoundary(true, true)
+	format := m.formatInformation(rule)
+
+	for i, c := range m.formatInformationCoordinates() {
+		matrix.Set(c.row, c.col, util.GetFormatModule(int(format[i]-'0')))
+	}
+}
+
+// Gets the format information bits of the given mask, repeated for both copies
+func (m *Moduler) formatInformation(rule int) string {
 	format := util.FormatInformationStrings[rune(m.ecLevel)][rule]
-	format += format
-	index := 0
+	return format + format
+}
 
-	for i := boundary.lower.col; i < boundary.upper.col+1; i++ {
-		if val, _ := matrix.At(boundary.upper.row+1, i); !util.IsModuleSkippedForFormat(val) {
-			bit, _ := strconv.ParseInt(string(format[index]), 2, 64)
-			matrix.Set(boundary.upper.row+1, i, util.GetDataModule(int(bit)))
-			index += 1
+// Gets the coordinates of the format information modules, in the order of the bits
+// returned by formatInformation
+func (m *Moduler) formatInformationCoordinates() []Coordinates {
+	coords := make([]Coordinates, 0, 30)
+	add := func(row, col int) {
+		if val, _ := m.moduleMatrix.At(row, col); !util.IsModuleSkippedForFormat(val) {
+			coords = append(coords, Coordinates{row: row, col: col})
 		}
 	}
 
+	boundary, _ := m.finderPatternBoundary(true, true)
+
+	for i := boundary.lower.col; i < boundary.upper.col+1; i++ {
+		add(boundary.upper.row+1, i)
+	}
+
 	for i := boundary.upper.row + 1; i >= 0; i-- {
-		if val, _ := matrix.At(i, boundary.upper.col+1); !util.IsModuleSkippedForFormat(val) {
-			bit, _ := strconv.ParseInt(string(format[index]), 2, 64)
-			matrix.Set(i, boundary.upper.col+1, util.GetDataModule(int(bit)))
-			index += 1
-		}
+		add(i, boundary.upper.col+1)
 	}
 
 	boundary, _ = m.finderPatternBoundary(false, true)
 
 	for i := boundary.upper.row - 1; i >= boundary.lower.row-1; i-- {
-		if val, _ := matrix.At(i, boundary.upper.col+1); !util.IsModuleSkippedForFormat(val) {
-			bit, _ := strconv.ParseInt(string(format[index]), 2, 64)
-			matrix.Set(i, boundary.upper.col+1, util.GetDataModule(int(bit)))
-			index += 1
-		}
+		add(i, boundary.upper.col+1)
 	}
 
 	boundary, _ = m.finderPatternBoundary(true, false)
 
 	for i := boundary.lower.col - 1; i < boundary.upper.col; i++ {
-		if val, _ := matrix.At(boundary.upper.row+1, i); !util.IsModuleSkippedForFormat(val) {
-			bit, _ := strconv.ParseInt(string(format[index]), 2, 64)
-			matrix.Set(boundary.upper.row+1, i, util.GetDataModule(int(bit)))
-			index += 1
+		add(boundary.upper.row+1, i)
+	}
+
+	return coords
+}
+
+// Selects the mask with the lowest penalty, the first one on ties. The candidates are
+// evaluated on packed grids of dark modules, the module matrix being built for the
+// selected mask only.
+func (m *Moduler) selectMask() (int, Penalty, error) {
+	base := m.darkModuleGrid()
+	maskPatterns := m.maskPatternGrids()
+	formatCoords := m.formatInformationCoordinates()
+
+	if m.options.Mask.Parallel {
+		return m.selectMaskConcurrently(base, maskPatterns, formatCoords)
+	}
+
+	best, penalty := -1, Penalty{}
+
+	for rule := 0; rule < len(maskFormula); rule++ {
+		grid, err := m.maskDarkModuleGrid(base, maskPatterns[rule], formatCoords, rule)
+		if err != nil {
+			return 0, Penalty{}, err
+		}
+
+		// A candidate is abandoned as soon as it cannot beat the best one anymore
+		current, ok := m.evaluateDarkModuleGrid(grid, func(partial int) bool {
+			return best >= 0 && partial >= penalty.Total
+		})
+		if ok {
+			best, penalty = rule, current
+		}
+
+		if m.isPenaltyAcceptable(penalty) {
+			break
 		}
 	}
+
+	return best, penalty, nil
 }
 
-func (m *Moduler) getBestMaskedMatrix(candidates []*matrix.Matrix[util.Module]) (*matrix.Matrix[util.Module], Penalty) {
-	penalty := m.evaluateMatrixCandidate(candidates[0])
-	scores := make([]int, len(candidates))
-	scores[0] = penalty.total
-	matrix := candidates[0]
+// Evaluates the candidates in one goroutine per mask. The selected mask is the same as the
+// sequential evaluation: with an acceptable penalty, the goroutines of the masks following
+// the first acceptable one are stopped.
+func (m *Moduler) selectMaskConcurrently(base *matrix.BitGrid, maskPatterns []*matrix.BitGrid, formatCoords []Coordinates) (int, Penalty, error) {
+	penalties := make([]Penalty, len(maskFormula))
+	errs := make([]error, len(maskFormula))
+	var accepted atomic.Int32
+	accepted.Store(int32(len(maskFormula)))
+
+	var wg sync.WaitGroup
+	for rule := 0; rule < len(maskFormula); rule++ {
+		wg.Add(1)
+		go func(rule int) {
+			defer wg.Done()
+
+			grid, err := m.maskDarkModuleGrid(base, maskPatterns[rule], formatCoords, rule)
+			if err != nil {
+				errs[rule] = err
+				return
+			}
+
+			penalty, ok := m.evaluateDarkModuleGrid(grid, func(int) bool {
+				return int(accepted.Load()) < rule
+			})
+			if !ok {
+				return
+			}
+			penalties[rule] = penalty
+
+			if m.isPenaltyAcceptable(penalty) {
+				for current := accepted.Load(); int(current) > rule; current = accepted.Load() {
+					if accepted.CompareAndSwap(current, int32(rule)) {
+						break
+					}
+				}
+			}
+		}(rule)
+	}
+	wg.Wait()
+
+	for _, err := range errs {
+		if err != nil {
+			return 0, Penalty{}, err
+		}
+	}
+
+	if rule := int(accepted.Load()); rule < len(maskFormula) {
+		return rule, penalties[rule], nil
+	}
 
-	for i := 1; i < len(candidates); i++ {
-		currentPenalty := m.evaluateMatrixCandidate(candidates[i])
-		scores[i] = currentPenalty.total
-		if currentPenalty.total < penalty.total {
-			penalty = currentPenalty
-			matrix = candidates[i]
+	best := 0
+	for rule := 1; rule < len(penalties); rule++ {
+		if penalties[rule].Total < penalties[best].Total {
+			best = rule
 		}
 	}
 
-	return matrix, penalty
+	return best, penalties[best], nil
 }
 
-func (m *Moduler) evaluateMatrixCandidate(matrix *matrix.Matrix[util.Module]) Penalty {
-	penalty := Penalty{}
-	penalty.score1 = m.computeFirstPenalty(matrix)
-	penalty.score2 = m.computeSecondPenalty(matrix)
-	penalty.score3 = m.computeThirdPenalty(matrix)
-	penalty.score4 = m.computeFourthPenalty(matrix)
-	penalty.total = penalty.score1 + penalty.score2 + penalty.score3 + penalty.score4
-	return penalty
+func (m *Moduler) isPenaltyAcceptable(penalty Penalty) bool {
+	return m.options.Mask.AcceptablePenalty > 0 && penalty.Total <= m.options.Mask.AcceptablePenalty
 }
 
-// Implements the first penalty score strategy
-func (m *Moduler) computeFirstPenalty(matrix *matrix.Matrix[util.Module]) int {
-	rowPenalty := 0
-	colPenalty := 0
+// Gets the grid of the function patterns, the modules set being the ones left unmasked
+func (m *Moduler) functionPatternGrid() *matrix.BitGrid {
+	size := m.qrCodeSize()
+	grid := matrix.NewBitGrid(size, size)
 
-	for i := 0; i < m.qrCodeSize(); i++ {
-		row, _ := matrix.RowAt(i)
-		rowPenalty += m.computeModulesLinePenalty(row)
-		col, _ := matrix.ColumnAt(i)
-		colPenalty += m.computeModulesLinePenalty(col)
-	}
+	m.moduleMatrix.ForEach(func(row, col int, module util.Module) {
+		grid.Set(row, col, util.GetModuleRole(module) != util.ModuleRole_DATA)
+	})
 
-	return rowPenalty + colPenalty
+	return grid
 }
 
-// Implements the second penalty score strategy
-func (m *Moduler) computeSecondPenalty(matrix *matrix.Matrix[util.Module]) int {
-	count := 0
+// Gets the grids of the modules toggled by every mask, outside of the function patterns.
+// The function patterns must be set.
+func (m *Moduler) maskPatternGrids() []*matrix.BitGrid {
+	maskPatternCache.Lock()
+	defer maskPatternCache.Unlock()
+
+	if grids, ok := maskPatternCache.grids[m.version]; ok {
+		return grids
+	}
 
-	for i := 0; i < matrix.Width()-1; i++ {
-		for j := 0; j < matrix.Height()-1; j++ {
-			module, _ := matrix.At(i, j)
-			moduleRight, _ := matrix.At(i, j+1)
-			moduleBottom, _ := matrix.At(i+1, j)
-			moduleOpposite, _ := matrix.At(i+1, j+1)
-
-			if util.IsModuleLighten(module) == util.IsModuleLighten(moduleRight) &&
-				util.IsModuleLighten(module) == util.IsModuleLighten(moduleBottom) &&
-				util.IsModuleLighten(module) == util.IsModuleLighten(moduleOpposite) {
-				count += 1
+	size := m.qrCodeSize()
+	functionPatterns := m.functionPatternGrid()
+	grids := make([]*matrix.BitGrid, len(maskFormula))
+
+	for rule := range grids {
+		grids[rule] = matrix.NewBitGrid(size, size)
+
+		for row := 0; row < size; row++ {
+			for col := 0; col < size; col++ {
+				if !functionPatterns.Get(row, col) && maskFormula[rule](Coordinates{row: row, col: col}) {
+					grids[rule].Set(row, col, true)
+				}
 			}
 		}
 	}
 
-	return count * 3
+	maskPatternCache.grids[m.version] = grids
+	return grids
 }
 
-// Implements the third penalty score strategy
-func (m *Moduler) computeThirdPenalty(matrix *matrix.Matrix[util.Module]) int {
-	count := 0
+// Gets the dark modules of the unmasked module matrix
+func (m *Moduler) darkModuleGrid() *matrix.BitGrid {
+	size := m.qrCodeSize()
+	grid := matrix.NewBitGrid(size, size)
 
-	for i := 0; i < m.qrCodeSize(); i++ {
-		row, _ := matrix.RowAt(i)
-		for col := 0; col < m.qrCodeSize()-11+1; col++ {
-			if m.isRulePattern(rulePattern, row[col:col+11]) {
-				count += 1
-			}
-			if m.isRulePattern(reversedRulePattern, row[col:col+11]) {
-				count += 1
-			}
+	m.moduleMatrix.ForEach(func(row, col int, module util.Module) {
+		grid.Set(row, col, !util.IsModuleLighten(module))
+	})
+
+	return grid
+}
+
+// Masks a copy of the dark modules grid with the pattern of the given rule, and sets its
+// format information
+func (m *Moduler) maskDarkModuleGrid(base, maskPattern *matrix.BitGrid, formatCoords []Coordinates, rule int) (*matrix.BitGrid, error) {
+	grid := base.Clone()
+	if err := grid.Xor(maskPattern); err != nil {
+		return nil, err
+	}
+
+	format := m.formatInformation(rule)
+	for i, c := range formatCoords {
+		grid.Set(c.row, c.col, format[i] == '1')
+	}
+
+	return grid, nil
+}
+
+// Computes the penalty of a masked grid. The evaluation is abandoned, and false returned,
+// when the abandon function is true for the partial score after any of the strategies.
+func (m *Moduler) evaluateDarkModuleGrid(grid *matrix.BitGrid, abandon func(partial int) bool) (Penalty, bool) {
+	penalty := Penalty{}
+	strategies := []struct {
+		score   *int
+		compute func(*matrix.BitGrid) int
+	}{
+		{&penalty.Balance, m.computeFourthPenalty},
+		{&penalty.Blocks, m.computeSecondPenalty},
+		{&penalty.AdjacentModules, m.computeFirstPenalty},
+		{&penalty.FinderLike, m.computeThirdPenalty},
+	}
+
+	for _, strategy := range strategies {
+		*strategy.score = strategy.compute(grid)
+		penalty.Total += *strategy.score
+
+		if abandon(penalty.Total) {
+			return penalty, false
 		}
 	}
 
-	for i := 0; i < m.qrCodeSize(); i++ {
-		col, _ := matrix.ColumnAt(i)
-		for row := 0; row < m.qrCodeSize()-11+1; row++ {
-			if m.isRulePattern(rulePattern, col[row:row+11]) {
-				count += 1
-			}
-			if m.isRulePattern(reversedRulePattern, col[row:row+11]) {
-				count += 1
-			}
+	return penalty, true
+}
+
+// Implements the first penalty score strategy a word at a time. A run of n >= 5 modules of
+// the same color scores n-2: it holds n-1 modules equal to their next one, n-4 of them
+// starting four such modules in a row, hence the count of those plus two per run.
+func (m *Moduler) computeFirstPenalty(grid *matrix.BitGrid) int {
+	score := 0
+	var equalBuffer, runBuffer [maxLineWords]uint64
+
+	m.forEachLine(grid, func(line []uint64, length int) {
+		equal, runs := equalBuffer[:len(line)], runBuffer[:len(line)]
+
+		for w := range line {
+			equal[w] = ^(line[w] ^ shiftedWord(line, w, 1)) & startsMask(length-1, w)
+		}
+		for w := range equal {
+			runs[w] = equal[w] & shiftedWord(equal, w, 1) & shiftedWord(equal, w, 2) & shiftedWord(equal, w, 3)
+		}
+		for w := range runs {
+			ends := runs[w] &^ shiftedWord(runs, w, 1)
+			score += bits.OnesCount64(runs[w]) + 2*bits.OnesCount64(ends)
+		}
+	})
+
+	return score
+}
+
+// Implements the second penalty score strategy. The 2x2 blocks are found a word at a time,
+// comparing two consecutive rows and each of them with itself shifted by one module.
+func (m *Moduler) computeSecondPenalty(grid *matrix.BitGrid) int {
+	count := 0
+
+	for i := 0; i < grid.Height()-1; i++ {
+		top, bottom := grid.Row(i), grid.Row(i+1)
+
+		for w := range top {
+			block := ^(top[w] ^ bottom[w]) & ^(top[w] ^ shiftedWord(top, w, 1)) & ^(bottom[w] ^ shiftedWord(bottom, w, 1))
+			count += bits.OnesCount64(block & startsMask(grid.Width()-1, w))
 		}
 	}
 
-	return count * 40
+	return count * 3
 }
 
-// Implements the fourth penalty score strategy
-func (m *Moduler) computeFourthPenalty(matrix *matrix.Matrix[util.Module]) int {
-	total := m.qrCodeSize() * m.qrCodeSize()
-	darkModules := 0
-
-	for _, row := range matrix.GetMatrix() {
-		for _, module := range row {
-			if !util.IsModuleLighten(module) {
-				darkModules += 1
+// Implements the third penalty score strategy a word at a time, every bit of a word telling
+// whether the pattern starts at its module
+func (m *Moduler) computeThirdPenalty(grid *matrix.BitGrid) int {
+	count := 0
+
+	m.forEachLine(grid, func(line []uint64, length int) {
+		for w := range line {
+			pattern := startsMask(length-rulePatternLength+1, w)
+			reversed := pattern
+
+			for k := 0; k < rulePatternLength; k++ {
+				word := shiftedWord(line, w, k)
+				pattern &= patternWord(word, rulePattern, k)
+				reversed &= patternWord(word, reversedRulePattern, k)
 			}
+
+			count += bits.OnesCount64(pattern) + bits.OnesCount64(reversed)
 		}
-	}
+	})
+
+	return count * 40
+}
 
-	percentage := darkModules * 100 / total
+// Implements the fourth penalty score strategy
+func (m *Moduler) computeFourthPenalty(grid *matrix.BitGrid) int {
+	total := grid.Width() * grid.Height()
+	percentage := grid.Count() * 100 / total
 
 	if percentage > 50 {
 		percentage = int(math.Floor(float64(percentage)/5)) * 5
@@ -533,38 +922,41 @@ func (m *Moduler) computeFourthPenalty(matrix *matrix.Matrix[util.Module]) int {
 	return int(math.Abs(float64(percentage)-50)) * 2
 }
 
-func (m *Moduler) computeModulesLinePenalty(modules []util.Module) int {
-	count := 0
-	score := 0
-	isLighten := false
-
-	for _, module := range modules {
-		if x := util.IsModuleLighten(module); x != isLighten {
-			isLighten = x
-			count = 1
-		} else {
-			count++
-			if count == 5 {
-				score += 3
-			} else if count > 5 {
-				score += 1
-			}
-		}
+// Calls the function on every row, then every column of the grid
+func (m *Moduler) forEachLine(grid *matrix.BitGrid, fn func(line []uint64, length int)) {
+	for i := 0; i < grid.Height(); i++ {
+		fn(grid.Row(i), grid.Width())
 	}
+	for j := 0; j < grid.Width(); j++ {
+		fn(grid.Column(j), grid.Height())
+	}
+}
 
-	return score
+// Gets the w-th word of a line shifted by n modules, the n-th next module taking the place
+// of each. The modules past the end of the line are light.
+func shiftedWord(line []uint64, w, n int) uint64 {
+	word := line[w] << n
+	if n > 0 && w+1 < len(line) {
+		word |= line[w+1] >> (matrix.WordSize - n)
+	}
+	return word
 }
 
-func (m *Moduler) isRulePattern(pattern, seq []util.Module) bool {
-	for i := range seq {
-		val := util.Module_DARKEN
-		if util.IsModuleLighten(seq[i]) {
-			val = util.Module_LIGHTEN
-		}
+// Gets the bits of the w-th word among the first n modules of a line
+func startsMask(n, w int) uint64 {
+	n -= w * matrix.WordSize
+	if n <= 0 {
+		return 0
+	} else if n >= matrix.WordSize {
+		return ^uint64(0)
+	}
+	return ^uint64(0) << (matrix.WordSize - n)
+}
 
-		if pattern[i] != val {
-			return false
-		}
+// Gets the bits of the word matching the k-th module of the pattern
+func patternWord(word uint64, pattern, k int) uint64 {
+	if pattern>>(rulePatternLength-1-k)&1 == 1 {
+		return word
 	}
-	return true
+	return ^word
 }
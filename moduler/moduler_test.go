@@ -1,10 +1,18 @@
 package moduler
 
 import (
+	"bytes"
+	"fmt"
+	"math/rand"
+	"qr/qr-gen/bitbuf"
+	"qr/qr-gen/decoder"
 	"qr/qr-gen/encoder"
-	"qr/qr-gen/img"
 	"qr/qr-gen/interleaver"
+	"qr/qr-gen/matrix"
+	"qr/qr-gen/qrerr"
+	"qr/qr-gen/util"
 	"qr/qr-gen/versioner"
+	"sync"
 	"testing"
 
 	"github.com/stretchr/testify/assert"
@@ -20,15 +28,317 @@ func TestModuler(t *testing.T) {
 
 	e := encoder.New()
 	encoded, _ := e.Encode(input, versioner.QrEcMedium)
-	encoded = e.AugmentEncodedInput(encoded, version, versioner.QrEcMedium)
+	encoded, _ = e.AugmentEncodedInput(encoded, version, versioner.QrEcMedium)
 
 	i := interleaver.New()
-	data := i.GetFinalMessage(encoded, version, versioner.QrEcMedium)
+	data, _ := i.GetFinalMessage(encoded, version, versioner.QrEcMedium)
 
 	m := New(version, versioner.QrEcMedium)
-	matrix, penalty := m.CreateModuleMatrix(data)
-	assert.Equal(415, penalty.total, "penalty score should match")
+	matrix, penalty, err := m.CreateModuleMatrix(data)
+	assert.NoError(err)
+	assert.Equal(Penalty{AdjacentModules: 248, Blocks: 117, FinderLike: 40, Balance: 10, Total: 415}, penalty, "penalty scores should match")
+	assert.Equal(25+2*DefaultQuietZone, matrix.Width(), "symbol sizes should match")
+}
+
+func TestModulerErrors(t *testing.T) {
+	assert := assert.New(t)
+
+	_, _, err := New(1, versioner.QrEcLow).CreateModuleMatrix("0102")
+	assert.EqualError(err, "Invalid bit '2' at position 3", "error messages should match")
+
+	_, _, err = New(1, versioner.QrEcLow).CreateModuleMatrix("0101")
+	assert.ErrorIs(err, qrerr.ErrInvalidLength)
+
+	_, _, err = New(41, versioner.QrEcLow).CreateModuleMatrixFromBits(finalMessage(40, versioner.QrEcLow, 0))
+	assert.ErrorIs(err, qrerr.ErrInvalidVersion)
+
+	_, _, err = New(1, 'X').CreateModuleMatrixFromBits(finalMessage(1, versioner.QrEcLow, 0))
+	assert.ErrorIs(err, qrerr.ErrInvalidLevel)
+}
+
+func TestModuleRoles(t *testing.T) {
+	assert := assert.New(t)
+	input := "HELLO WORLD"
+
+	v := versioner.New()
+	mode, _ := v.GetMode(input)
+	version, _ := v.GetVersion(input, mode, versioner.QrEcQuartile)
+
+	e := encoder.New()
+	encoded, _ := e.Encode(input, versioner.QrEcQuartile)
+	encoded, _ = e.AugmentEncodedInput(encoded, version, versioner.QrEcQuartile)
+
+	i := interleaver.New()
+	data, _ := i.GetFinalMessage(encoded, version, versioner.QrEcQuartile)
+
+	m := New(version, versioner.QrEcQuartile)
+	matrix, _, _ := m.CreateModuleMatrix(data)
+
+	counts := map[util.ModuleRole]int{}
+	util.ForEachModule(matrix.GetMatrix(), func(row, col int, dark bool, role util.ModuleRole) {
+		counts[role] += 1
+	})
+
+	size := 21 + 2*DefaultQuietZone
+	assert.Equal(size*size-21*21, counts[util.ModuleRole_QUIET_ZONE], "quiet zone modules should match")
+	assert.Equal(30, counts[util.ModuleRole_FORMAT], "format modules should match")
+	assert.Equal(3*49, counts[util.ModuleRole_FINDER], "finder modules should match")
+	assert.Equal(1, counts[util.ModuleRole_DARK_MODULE], "dark module should be set")
+	assert.Equal(208, counts[util.ModuleRole_DATA], "data modules should match")
+	assert.Zero(counts[util.ModuleRole_RESERVED], "no module should stay reserved")
+	assert.Zero(counts[util.ModuleRole_EMPTY], "no module should stay empty")
+}
+
+func TestSharedModuler(t *testing.T) {
+	assert := assert.New(t)
+	e := encoder.New()
+	i := interleaver.New()
+	m := New(2, versioner.QrEcMedium)
+
+	inputs := []string{"HELLO WORLD AND MOON", "https://example.com/", "0123456789012345678901234567890123456789"}
+	expected := make([]Penalty, len(inputs))
+	data := make([]string, len(inputs))
+	for j, input := range inputs {
+		encoded, _ := e.Encode(input, versioner.QrEcMedium)
+		encoded, _ = e.AugmentEncodedInput(encoded, 2, versioner.QrEcMedium)
+		data[j], _ = i.GetFinalMessage(encoded, 2, versioner.QrEcMedium)
+		_, expected[j], _ = m.CreateModuleMatrix(data[j])
+	}
+
+	actual := make([]Penalty, 300)
+	var wg sync.WaitGroup
+	for n := range actual {
+		wg.Add(1)
+		go func(n int) {
+			defer wg.Done()
+			_, actual[n], _ = m.CreateModuleMatrix(data[n%len(data)])
+		}(n)
+	}
+	wg.Wait()
+
+	for n, penalty := range actual {
+		assert.Equal(expected[n%len(data)], penalty, "a moduler shared by goroutines should keep its results")
+	}
+}
+
+func TestVersionInformation(t *testing.T) {
+	assert := assert.New(t)
+
+	tests := []struct {
+		version  versioner.QrVersion
+		expected int
+	}{
+		{7, 0x07c94},
+		{21, 0x15683},
+		{40, 0x28c69},
+	}
+
+	for _, test := range tests {
+		m := &Moduler{version: test.version, ecLevel: versioner.QrEcLow}
+		assert.Equal(test.expected, m.computeVersionInformation(), "version information of %d should match", test.version)
+	}
+}
+
+// Gets the final message of pseudo random data codewords filling the given version
+func finalMessage(version versioner.QrVersion, lvl versioner.QrEcLevel, seed int) *bitbuf.Buffer {
+	info := util.QrEcInfo[util.GetECMappingKey(int(version), string(lvl))]
+	data := make([]byte, info.TotalDataCodewords)
+	for j := range data {
+		data[j] = byte((j*7 + seed*13 + j*j) % 256)
+	}
+
+	message, _ := interleaver.New().GetFinalMessageBits(bitbuf.FromBytes(data), version, lvl)
+	return message
+}
+
+func TestMaskOptions(t *testing.T) {
+	assert := assert.New(t)
+
+	for _, version := range []versioner.QrVersion{1, 2, 7, 10, 25} {
+		for seed := 0; seed < 4; seed++ {
+			data := finalMessage(version, versioner.QrEcMedium, seed)
+
+			expectedMatrix, expected, _ := New(version, versioner.QrEcMedium).CreateModuleMatrixFromBits(data)
+			matrix, penalty, _ := NewWithOptions(version, versioner.QrEcMedium, withMask(MaskOptions{Parallel: true})).CreateModuleMatrixFromBits(data)
+			assert.Equal(expected, penalty, "parallel evaluation should select the same mask")
+			assert.Equal(expectedMatrix.GetMatrix(), matrix.GetMatrix(), "parallel evaluation should build the same matrix")
+
+			options := MaskOptions{AcceptablePenalty: expected.Total + 200}
+			_, early, _ := NewWithOptions(version, versioner.QrEcMedium, withMask(options)).CreateModuleMatrixFromBits(data)
+			assert.LessOrEqual(early.Total, options.AcceptablePenalty, "early stop penalty should be acceptable")
+
+			options.Parallel = true
+			_, parallelEarly, _ := NewWithOptions(version, versioner.QrEcMedium, withMask(options)).CreateModuleMatrixFromBits(data)
+			assert.Equal(early, parallelEarly, "parallel early stop should select the same mask")
+
+			_, unreachable, _ := NewWithOptions(version, versioner.QrEcMedium, withMask(MaskOptions{AcceptablePenalty: 1})).CreateModuleMatrixFromBits(data)
+			assert.Equal(expected, unreachable, "all the masks should be evaluated when none is acceptable")
+		}
+	}
+}
+
+func TestQuietZone(t *testing.T) {
+	assert := assert.New(t)
+	data := finalMessage(1, versioner.QrEcLow, 0)
+
+	for _, quietZone := range []int{0, 4, 10} {
+		options := DefaultOptions()
+		options.QuietZone = quietZone
+		matrix, _, err := NewWithOptions(1, versioner.QrEcLow, options).CreateModuleMatrixFromBits(data)
+		assert.NoError(err)
+
+		size := 21 + 2*quietZone
+		assert.Equal(size, matrix.Width(), "widths should match")
+		assert.Equal(size, matrix.Height(), "heights should match")
+		assert.Len(matrix.GetMatrix(), size, "rows should match")
+
+		counts := map[util.ModuleRole]int{}
+		util.ForEachModule(matrix.GetMatrix(), func(row, col int, dark bool, role util.ModuleRole) {
+			counts[role] += 1
+		})
+		assert.Equal(size*size-21*21, counts[util.ModuleRole_QUIET_ZONE], "quiet zone modules should match")
+	}
+
+	options := DefaultOptions()
+	options.QuietZone = -1
+	_, _, err := NewWithOptions(1, versioner.QrEcLow, options).CreateModuleMatrixFromBits(data)
+	assert.ErrorIs(err, qrerr.ErrInvalidInput)
+}
+
+func withMask(mask MaskOptions) Options {
+	options := DefaultOptions()
+	options.Mask = mask
+	return options
+}
+
+func BenchmarkCreateModuleMatrix(b *testing.B) {
+	variants := []struct {
+		name    string
+		options MaskOptions
+	}{
+		{"sequential", MaskOptions{}},
+		{"parallel", MaskOptions{Parallel: true}},
+		{"early-stop", MaskOptions{AcceptablePenalty: 1000}},
+	}
+
+	for _, version := range []versioner.QrVersion{1, 10, 25, 40} {
+		data := finalMessage(version, versioner.QrEcMedium, 0)
+
+		for _, variant := range variants {
+			b.Run(fmt.Sprintf("v%d/%s", version, variant.name), func(b *testing.B) {
+				m := NewWithOptions(version, versioner.QrEcMedium, withMask(variant.options))
+				for n := 0; n < b.N; n++ {
+					m.CreateModuleMatrixFromBits(data)
+				}
+			})
+		}
+	}
+}
+
+func TestWordWisePenalties(t *testing.T) {
+	assert := assert.New(t)
+	m := &Moduler{}
+	r := rand.New(rand.NewSource(1))
+
+	for _, size := range []int{21, 63, 64, 65, 128, 177} {
+		grid := matrix.NewBitGrid(size, size)
+		for row := 0; row < size; row++ {
+			for col := 0; col < size; col++ {
+				// Biased modules make long runs and finder-like patterns likely
+				grid.Set(row, col, r.Intn(4) == 0 || (col/5+row/7)%3 == 0)
+			}
+		}
+
+		first, third := naivePenalties(grid)
+		assert.Equal(first, m.computeFirstPenalty(grid), "first penalties should match for size %d", size)
+		assert.Equal(third, m.computeThirdPenalty(grid), "third penalties should match for size %d", size)
+	}
+}
+
+// Computes the first and third penalties module by module
+func naivePenalties(grid *matrix.BitGrid) (int, int) {
+	first, third := 0, 0
+	pattern := []bool{true, false, true, true, true, false, true, false, false, false, false}
+
+	score := func(line []bool) {
+		for start := 0; start < len(line); {
+			end := start
+			for end < len(line) && line[end] == line[start] {
+				end++
+			}
+			if end-start >= 5 {
+				first += end - start - 2
+			}
+			start = end
+		}
+
+		for start := 0; start+len(pattern) <= len(line); start++ {
+			matches, reversed := true, true
+			for k := range pattern {
+				matches = matches && line[start+k] == pattern[k]
+				reversed = reversed && line[start+k] == pattern[len(pattern)-1-k]
+			}
+			if matches {
+				third += 40
+			}
+			if reversed {
+				third += 40
+			}
+		}
+	}
+
+	for i := 0; i < grid.Height(); i++ {
+		row, col := make([]bool, grid.Width()), make([]bool, grid.Height())
+		for j := range row {
+			row[j], col[j] = grid.Get(i, j), grid.Get(j, i)
+		}
+		score(row)
+		score(col)
+	}
+
+	return first, third
+}
+
+// A symbol built from a byte mode segment of any data, at any version and level, decodes
+// back to the data
+func FuzzCreateModuleMatrix(f *testing.F) {
+	f.Add([]byte("https://www.qrcode.com/"), byte(0), byte(1))
+	f.Add([]byte{0x00, 0xff, 0x80}, byte(6), byte(3))
+	f.Add([]byte{}, byte(39), byte(0))
+
+	empty, _ := versioner.New().GetSegmentsCapacity(nil)
+
+	f.Fuzz(func(t *testing.T, data []byte, version byte, lvl byte) {
+		qrVersion := versioner.QrVersion(int(version)%int(versioner.MaxQrVersion) + 1)
+		level := versioner.QrEcLevels[int(lvl)%len(versioner.QrEcLevels)]
+		data = data[:util.Min(len(data), empty.RemainingCharacters(qrVersion, level, versioner.QrByteMode))]
+
+		encoded := bitbuf.New()
+		encoded.AppendBits(0b0100, 4)
+		encoded.AppendBits(len(data), versioner.New().GetCountIndicatorLength(qrVersion, versioner.QrByteMode))
+		encoded.AppendBytes(data)
+		if err := encoder.New().AugmentEncodedBits(encoded, qrVersion, level); err != nil {
+			t.Fatalf("Error on augmenting the encoded data: %v", err)
+		}
+		message, err := interleaver.New().GetFinalMessageBits(encoded, qrVersion, level)
+		if err != nil {
+			t.Fatalf("Error on computing the final message: %v", err)
+		}
+
+		symbol, _, err := New(qrVersion, level).CreateModuleMatrixFromBits(message)
+		if err != nil {
+			t.Fatalf("Error on placing the modules: %v", err)
+		}
 
-	qi := img.New()
-	qi.CreateImage("best.png", matrix.GetMatrix())
+		result, err := decoder.New().Decode(symbol)
+		if err != nil {
+			t.Fatalf("Error on decoding the %d-%c symbol: %v", qrVersion, level, err)
+		}
+		if result.Version != qrVersion || result.Level != level {
+			t.Fatalf("%d-%c symbol decoded as %d-%c", qrVersion, level, result.Version, result.Level)
+		}
+		if !bytes.Equal(data, result.Data) {
+			t.Fatalf("decoded data %x should be %x", result.Data, data)
+		}
+	})
 }
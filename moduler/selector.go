@@ -0,0 +1,131 @@
+package moduler
+
+import (
+	"qr/qr-gen/util"
+)
+
+// MaskSelector chooses the mask of a symbol among the candidates built with each of the
+// eight mask references
+type MaskSelector interface {
+	// SelectMask returns the mask reference of the selected candidate
+	SelectMask(candidates []MaskCandidate) int
+}
+
+// Region is a rectangle of modules of the symbol, quiet zone excluded, such as the area
+// covered by a logo
+type Region struct {
+	Row    int
+	Col    int
+	Width  int
+	Height int
+}
+
+func (r Region) contains(row, col int) bool {
+	return row >= r.Row && row < r.Row+r.Height && col >= r.Col && col < r.Col+r.Width
+}
+
+// PenaltySelector selects the mask with the lowest penalty, the first one on ties, as the
+// specification requires
+type PenaltySelector struct{}
+
+func NewPenaltySelector() MaskSelector {
+	return &PenaltySelector{}
+}
+
+func (s *PenaltySelector) SelectMask(candidates []MaskCandidate) int {
+	return lowest(candidates, func(c MaskCandidate) int { return 0 })
+}
+
+// FixedMaskSelector always selects the same mask, whatever its penalty
+type FixedMaskSelector struct {
+	mask int
+}
+
+func NewFixedMaskSelector(mask int) MaskSelector {
+	return &FixedMaskSelector{mask: mask}
+}
+
+func (s *FixedMaskSelector) SelectMask(candidates []MaskCandidate) int {
+	return s.mask
+}
+
+// LogoAreaSelector selects the mask leaving the fewest dark modules in the region, so that
+// a logo drawn over it hides as few of them as possible. Ties are broken by the penalty.
+type LogoAreaSelector struct {
+	region Region
+}
+
+func NewLogoAreaSelector(region Region) MaskSelector {
+	return &LogoAreaSelector{region: region}
+}
+
+func (s *LogoAreaSelector) SelectMask(candidates []MaskCandidate) int {
+	return lowest(candidates, func(c MaskCandidate) int {
+		dark := 0
+		c.forEachModule(func(row, col int, module util.Module) {
+			if s.region.contains(row, col) && !util.IsModuleLighten(module) {
+				dark += 1
+			}
+		})
+		return dark
+	})
+}
+
+// ContrastSelector selects the mask with the most color changes between neighbouring
+// modules in the ring of the given width around the region, which sets the region apart
+// from the rest of the symbol. Ties are broken by the penalty.
+type ContrastSelector struct {
+	region Region
+	width  int
+}
+
+func NewContrastSelector(region Region, width int) MaskSelector {
+	return &ContrastSelector{region: region, width: width}
+}
+
+func (s *ContrastSelector) SelectMask(candidates []MaskCandidate) int {
+	outer := Region{
+		Row:    s.region.Row - s.width,
+		Col:    s.region.Col - s.width,
+		Width:  s.region.Width + 2*s.width,
+		Height: s.region.Height + 2*s.width,
+	}
+	inRing := func(row, col int) bool {
+		return outer.contains(row, col) && !s.region.contains(row, col)
+	}
+
+	return lowest(candidates, func(c MaskCandidate) int {
+		changes := 0
+		c.forEachModule(func(row, col int, module util.Module) {
+			if !inRing(row, col) {
+				return
+			}
+
+			for _, next := range []Coordinates{{row: row, col: col + 1}, {row: row + 1, col: col}} {
+				if !inRing(next.row, next.col) {
+					continue
+				}
+
+				neighbour, err := c.module(next.row, next.col)
+				if err == nil && util.IsModuleLighten(neighbour) != util.IsModuleLighten(module) {
+					changes += 1
+				}
+			}
+		})
+		// The most changes are the best
+		return -changes
+	})
+}
+
+// lowest returns the mask of the candidate with the lowest score, the lowest penalty then
+// the first one breaking the ties
+func lowest(candidates []MaskCandidate, score func(MaskCandidate) int) int {
+	best, bestScore := 0, 0
+	for i, candidate := range candidates {
+		current := score(candidate)
+		if i == 0 || current < bestScore || (current == bestScore && candidate.Penalty.Total < candidates[best].Penalty.Total) {
+			best, bestScore = i, current
+		}
+	}
+	return candidates[best].Mask
+}
@@ -0,0 +1,100 @@
+package moduler
+
+import (
+	"qr/qr-gen/matrix"
+	"qr/qr-gen/qrerr"
+	"qr/qr-gen/util"
+	"qr/qr-gen/versioner"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func withSelector(selector MaskSelector) Options {
+	return withMask(MaskOptions{Selector: selector})
+}
+
+func TestPenaltySelector(t *testing.T) {
+	assert := assert.New(t)
+
+	for seed := 0; seed < 4; seed++ {
+		data := finalMessage(3, versioner.QrEcQuartile, seed)
+
+		expectedMatrix, expected, _ := New(3, versioner.QrEcQuartile).CreateModuleMatrixFromBits(data)
+		matrix, penalty, err := NewWithOptions(3, versioner.QrEcQuartile, withSelector(NewPenaltySelector())).CreateModuleMatrixFromBits(data)
+		assert.NoError(err)
+		assert.Equal(expected, penalty, "penalty selector should select the default mask")
+		assert.Equal(expectedMatrix.GetMatrix(), matrix.GetMatrix(), "penalty selector should build the same matrix")
+
+		diagnostics, _ := New(3, versioner.QrEcQuartile).DiagnoseMasks(data)
+		assert.Equal(diagnostics.Selected, NewPenaltySelector().SelectMask(diagnostics.Candidates), "penalty selector should select the lowest penalty")
+	}
+}
+
+func TestFixedMaskSelector(t *testing.T) {
+	assert := assert.New(t)
+	data := finalMessage(2, versioner.QrEcLow, 0)
+
+	for mask := 0; mask < 8; mask++ {
+		m := NewWithOptions(2, versioner.QrEcLow, withSelector(NewFixedMaskSelector(mask)))
+		symbol, penalty, err := m.CreateModuleMatrixFromBits(data)
+		assert.NoError(err)
+
+		diagnostics, _ := m.DiagnoseMasks(data)
+		assert.Equal(mask, diagnostics.Selected, "fixed mask should be selected")
+		assert.Equal(MaskSelectionReason_SELECTOR, diagnostics.Reason, "reasons should match")
+		assert.Equal(diagnostics.Candidates[mask].Penalty, penalty, "penalty of the fixed mask should be returned")
+		assert.True(matrix.Equal(diagnostics.Candidates[mask].Symbol, symbol), "symbol of the fixed mask should be built")
+	}
+
+	_, _, err := NewWithOptions(2, versioner.QrEcLow, withSelector(NewFixedMaskSelector(8))).CreateModuleMatrixFromBits(data)
+	assert.ErrorIs(err, qrerr.ErrInvalidInput)
+}
+
+func TestLogoAreaSelector(t *testing.T) {
+	assert := assert.New(t)
+	region := Region{Row: 10, Col: 10, Width: 5, Height: 5}
+
+	for seed := 0; seed < 4; seed++ {
+		data := finalMessage(2, versioner.QrECHigh, seed)
+		diagnostics, _ := New(2, versioner.QrECHigh).DiagnoseMasks(data)
+
+		darkModules := make([]int, len(diagnostics.Candidates))
+		for i, candidate := range diagnostics.Candidates {
+			for row := region.Row; row < region.Row+region.Height; row++ {
+				for col := region.Col; col < region.Col+region.Width; col++ {
+					if module, _ := candidate.Symbol.At(row+DefaultQuietZone, col+DefaultQuietZone); !util.IsModuleLighten(module) {
+						darkModules[i] += 1
+					}
+				}
+			}
+		}
+
+		selected := NewLogoAreaSelector(region).SelectMask(diagnostics.Candidates)
+		for i := range darkModules {
+			assert.LessOrEqual(darkModules[selected], darkModules[i], "selected mask should leave the fewest dark modules under the logo")
+		}
+	}
+}
+
+func TestContrastSelector(t *testing.T) {
+	assert := assert.New(t)
+
+	// A ring of a single module around a single module region
+	candidate := func(mask int, rows [][]util.Module) MaskCandidate {
+		symbol, _ := matrix.FromRows(rows)
+		return MaskCandidate{Mask: mask, Symbol: symbol}
+	}
+	D, L := util.Module_DARKEN, util.Module_LIGHTEN
+	candidates := []MaskCandidate{
+		candidate(0, [][]util.Module{{D, D, D}, {D, L, D}, {D, D, D}}),
+		candidate(1, [][]util.Module{{D, L, D}, {L, D, L}, {D, L, D}}),
+		candidate(2, [][]util.Module{{D, L, D}, {D, L, D}, {D, L, D}}),
+	}
+
+	assert.Equal(1, NewContrastSelector(Region{Row: 1, Col: 1, Width: 1, Height: 1}, 1).SelectMask(candidates), "mask with the most color changes should be selected")
+
+	data := finalMessage(2, versioner.QrEcMedium, 0)
+	_, _, err := NewWithOptions(2, versioner.QrEcMedium, withSelector(NewContrastSelector(Region{Row: 10, Col: 10, Width: 5, Height: 5}, 2))).CreateModuleMatrixFromBits(data)
+	assert.NoError(err)
+}
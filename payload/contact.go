@@ -0,0 +1,178 @@
+package payload
+
+import (
+	"strings"
+)
+
+type VCardVersion string
+
+const (
+	VCard3 VCardVersion = "3.0"
+	VCard4 VCardVersion = "4.0"
+)
+
+type Address struct {
+	Street     string
+	City       string
+	Region     string
+	PostalCode string
+	Country    string
+}
+
+// VCard describes a contact, serialized as a vCard 3.0 (RFC 2426) or 4.0 (RFC 6350).
+type VCard struct {
+	Version      VCardVersion
+	FirstName    string
+	LastName     string
+	Organization string
+	Title        string
+	Phones       []string
+	Emails       []string
+	URL          string
+	Address      Address
+	Note         string
+}
+
+// MeCard describes a contact in the compact MECARD:N:Last,First;TEL:...;; format.
+type MeCard struct {
+	FirstName string
+	LastName  string
+	Phones    []string
+	Emails    []string
+	URL       string
+	Address   Address
+	Birthday  string
+	Note      string
+}
+
+const meCardSpecials = `\;,:`
+
+func (v VCard) Validate() error {
+	if v.Version != VCard3 && v.Version != VCard4 {
+		return &ValidationError{"vCard", "version", "must be 3.0 or 4.0"}
+	}
+
+	if v.FirstName == "" && v.LastName == "" {
+		return &ValidationError{"vCard", "name", "is required"}
+	}
+
+	return validateContact("vCard", v.Phones, v.Emails)
+}
+
+func (v VCard) String() string {
+	lines := []string{
+		"BEGIN:VCARD",
+		"VERSION:" + string(v.Version),
+		"N:" + escapeText(v.LastName) + ";" + escapeText(v.FirstName) + ";;;",
+		"FN:" + escapeText(strings.TrimSpace(v.FirstName+" "+v.LastName)),
+	}
+
+	if v.Organization != "" {
+		lines = append(lines, "ORG:"+escapeText(v.Organization))
+	}
+	if v.Title != "" {
+		lines = append(lines, "TITLE:"+escapeText(v.Title))
+	}
+	for _, phone := range v.Phones {
+		if v.Version == VCard4 {
+			lines = append(lines, "TEL;VALUE=uri:tel:"+strings.ReplaceAll(phone, " ", ""))
+		} else {
+			lines = append(lines, "TEL:"+phone)
+		}
+	}
+	for _, email := range v.Emails {
+		lines = append(lines, "EMAIL:"+email)
+	}
+	if v.URL != "" {
+		lines = append(lines, "URL:"+v.URL)
+	}
+	if v.Address != (Address{}) {
+		components := []string{"", "", v.Address.Street, v.Address.City, v.Address.Region, v.Address.PostalCode, v.Address.Country}
+		for i := range components {
+			components[i] = escapeText(components[i])
+		}
+		lines = append(lines, "ADR:"+strings.Join(components, ";"))
+	}
+	if v.Note != "" {
+		lines = append(lines, "NOTE:"+escapeText(v.Note))
+	}
+
+	return joinLines(append(lines, "END:VCARD"))
+}
+
+func (m MeCard) Validate() error {
+	if m.FirstName == "" && m.LastName == "" {
+		return &ValidationError{"MeCard", "name", "is required"}
+	}
+
+	if m.Birthday != "" && !isDigits(m.Birthday, 8) {
+		return &ValidationError{"MeCard", "birthday", "must be formatted as YYYYMMDD"}
+	}
+
+	return validateContact("MeCard", m.Phones, m.Emails)
+}
+
+func (m MeCard) String() string {
+	var builder strings.Builder
+
+	builder.WriteString("MECARD:N:" + escapeFields(m.LastName, meCardSpecials))
+	if m.FirstName != "" {
+		builder.WriteString("," + escapeFields(m.FirstName, meCardSpecials))
+	}
+	builder.WriteString(";")
+
+	for _, phone := range m.Phones {
+		builder.WriteString("TEL:" + escapeFields(phone, meCardSpecials) + ";")
+	}
+	for _, email := range m.Emails {
+		builder.WriteString("EMAIL:" + escapeFields(email, meCardSpecials) + ";")
+	}
+	if m.URL != "" {
+		builder.WriteString("URL:" + escapeFields(m.URL, meCardSpecials) + ";")
+	}
+	if m.Address != (Address{}) {
+		components := []string{"", "", m.Address.Street, m.Address.City, m.Address.Region, m.Address.PostalCode, m.Address.Country}
+		for i := range components {
+			components[i] = escapeFields(components[i], meCardSpecials)
+		}
+		builder.WriteString("ADR:" + strings.Join(components, ",") + ";")
+	}
+	if m.Birthday != "" {
+		builder.WriteString("BDAY:" + m.Birthday + ";")
+	}
+	if m.Note != "" {
+		builder.WriteString("NOTE:" + escapeFields(m.Note, meCardSpecials) + ";")
+	}
+	builder.WriteString(";")
+
+	return builder.String()
+}
+
+func validateContact(payload string, phones, emails []string) error {
+	for _, phone := range phones {
+		if !isPhoneNumber(phone) {
+			return &ValidationError{payload, "phone " + phone, "is not a valid phone number"}
+		}
+	}
+
+	for _, email := range emails {
+		if !isEmailAddress(email) {
+			return &ValidationError{payload, "email " + email, "is not a valid address"}
+		}
+	}
+
+	return nil
+}
+
+func isDigits(s string, n int) bool {
+	if len(s) != n {
+		return false
+	}
+
+	for _, r := range s {
+		if r < '0' || r > '9' {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,60 @@
+package payload
+
+import "time"
+
+// Event describes a calendar event, serialized as an iCalendar VEVENT (RFC 5545).
+// All day events only keep the date of their start and end.
+type Event struct {
+	Summary     string
+	Start       time.Time
+	End         time.Time
+	AllDay      bool
+	Location    string
+	Description string
+}
+
+const eventTimeLayout = "20060102T150405Z"
+const eventDateLayout = "20060102"
+
+func (e Event) Validate() error {
+	if e.Summary == "" {
+		return &ValidationError{"event", "summary", "is required"}
+	}
+
+	if e.Start.IsZero() {
+		return &ValidationError{"event", "start", "is required"}
+	}
+
+	if !e.End.IsZero() && e.End.Before(e.Start) {
+		return &ValidationError{"event", "end", "must not be before the start"}
+	}
+
+	return nil
+}
+
+func (e Event) String() string {
+	lines := []string{
+		"BEGIN:VEVENT",
+		"SUMMARY:" + escapeText(e.Summary),
+		"DTSTART" + e.formatTime(e.Start),
+	}
+
+	if !e.End.IsZero() {
+		lines = append(lines, "DTEND"+e.formatTime(e.End))
+	}
+	if e.Location != "" {
+		lines = append(lines, "LOCATION:"+escapeText(e.Location))
+	}
+	if e.Description != "" {
+		lines = append(lines, "DESCRIPTION:"+escapeText(e.Description))
+	}
+
+	return joinLines(append(lines, "END:VEVENT"))
+}
+
+func (e Event) formatTime(t time.Time) string {
+	if e.AllDay {
+		return ";VALUE=DATE:" + t.Format(eventDateLayout)
+	}
+	return ":" + t.UTC().Format(eventTimeLayout)
+}
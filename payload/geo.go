@@ -0,0 +1,41 @@
+package payload
+
+import (
+	"math"
+	"strconv"
+)
+
+// Geo describes a location, serialized as a geo URI (RFC 5870).
+type Geo struct {
+	Latitude  float64
+	Longitude float64
+	Altitude  *float64
+}
+
+func (g Geo) Validate() error {
+	if math.IsNaN(g.Latitude) || g.Latitude < -90 || g.Latitude > 90 {
+		return &ValidationError{"geo", "latitude", "must be between -90 and 90"}
+	}
+
+	if math.IsNaN(g.Longitude) || g.Longitude < -180 || g.Longitude > 180 {
+		return &ValidationError{"geo", "longitude", "must be between -180 and 180"}
+	}
+
+	if g.Altitude != nil && (math.IsNaN(*g.Altitude) || math.IsInf(*g.Altitude, 0)) {
+		return &ValidationError{"geo", "altitude", "must be a finite number"}
+	}
+
+	return nil
+}
+
+func (g Geo) String() string {
+	uri := "geo:" + formatCoordinate(g.Latitude) + "," + formatCoordinate(g.Longitude)
+	if g.Altitude != nil {
+		uri += "," + formatCoordinate(*g.Altitude)
+	}
+	return uri
+}
+
+func formatCoordinate(f float64) string {
+	return strconv.FormatFloat(f, 'f', -1, 64)
+}
@@ -0,0 +1,80 @@
+package payload
+
+import (
+	"net/url"
+	"strings"
+)
+
+// SMS describes a text message, serialized as SMSTO:number:message.
+// The message is written as it is: the format has no escaping, readers taking everything
+// after the number as the message.
+type SMS struct {
+	Number  string
+	Message string
+}
+
+// Email describes an email message, serialized as a mailto URI (RFC 6068).
+type Email struct {
+	To      []string
+	Cc      []string
+	Subject string
+	Body    string
+}
+
+func (s SMS) Validate() error {
+	if !isPhoneNumber(s.Number) {
+		return &ValidationError{"SMS", "number", "is not a valid phone number"}
+	}
+	return nil
+}
+
+func (s SMS) String() string {
+	return "SMSTO:" + strings.ReplaceAll(s.Number, " ", "") + ":" + s.Message
+}
+
+func (e Email) Validate() error {
+	if len(e.To) == 0 {
+		return &ValidationError{"email", "recipient", "is required"}
+	}
+
+	for _, address := range append(append([]string{}, e.To...), e.Cc...) {
+		if !isEmailAddress(address) {
+			return &ValidationError{"email", "address " + address, "is not a valid address"}
+		}
+	}
+
+	return nil
+}
+
+func (e Email) String() string {
+	to := make([]string, len(e.To))
+	for i, address := range e.To {
+		to[i] = url.PathEscape(address)
+	}
+
+	var query []string
+	if len(e.Cc) > 0 {
+		cc := make([]string, len(e.Cc))
+		for i, address := range e.Cc {
+			cc[i] = url.PathEscape(address)
+		}
+		query = append(query, "cc="+strings.Join(cc, ","))
+	}
+	if e.Subject != "" {
+		query = append(query, "subject="+escapeQuery(e.Subject))
+	}
+	if e.Body != "" {
+		query = append(query, "body="+escapeQuery(e.Body))
+	}
+
+	uri := "mailto:" + strings.Join(to, ",")
+	if len(query) > 0 {
+		uri += "?" + strings.Join(query, "&")
+	}
+	return uri
+}
+
+// Percent-encodes a mailto component, spaces included, as RFC 6068 does not allow '+'.
+func escapeQuery(s string) string {
+	return strings.ReplaceAll(url.QueryEscape(s), "+", "%20")
+}
@@ -0,0 +1,124 @@
+package payload
+
+import (
+	"fmt"
+	"net/mail"
+	"qr/qr-gen/generator"
+	"qr/qr-gen/matrix"
+	"qr/qr-gen/qrerr"
+	"qr/qr-gen/util"
+	"qr/qr-gen/versioner"
+	"strings"
+	"unicode/utf8"
+)
+
+// Payload is a structured content that is serialized into the text encoded by a QR code.
+type Payload interface {
+	Validate() error
+	String() string
+}
+
+// ValidationError reports an invalid or missing field of a payload.
+type ValidationError struct {
+	Payload string
+	Field   string
+	Reason  string
+}
+
+func (e *ValidationError) Error() string {
+	return fmt.Sprintf("invalid %s payload: %s %s", e.Payload, e.Field, e.Reason)
+}
+
+func (e *ValidationError) Is(target error) bool {
+	return target == qrerr.ErrInvalidInput
+}
+
+// Encode validates the payload and returns its serialized text.
+func Encode(p Payload) (string, error) {
+	if err := p.Validate(); err != nil {
+		return "", err
+	}
+	return p.String(), nil
+}
+
+// Generate validates and serializes the payload and generates its QR code.
+// The versioner selects the most compact mode able to hold the serialized text, except for
+// the payloads which must be encoded in byte mode. Texts beyond ASCII are always encoded in
+// byte mode, marked as UTF-8 by an ECI header.
+func Generate(p Payload, lvl versioner.QrEcLevel) (*matrix.Matrix[util.Module], error) {
+	s, err := Encode(p)
+	if err != nil {
+		return nil, err
+	}
+
+	_, isByteMode := p.(byteModePayload)
+	if isByteMode || !isASCII(s) {
+		return generator.New().GenerateByteMode(s, lvl, !isASCII(s))
+	}
+
+	return generator.New().Generate(s, lvl)
+}
+
+// Implemented by the payloads whose readers expect them in byte mode
+type byteModePayload interface {
+	byteMode()
+}
+
+// Escapes the special characters of the semicolon separated formats (Wi-Fi, MeCard)
+// with a backslash.
+func escapeFields(s string, specials string) string {
+	var builder strings.Builder
+
+	for _, r := range s {
+		if strings.ContainsRune(specials, r) {
+			builder.WriteRune('\\')
+		}
+		builder.WriteRune(r)
+	}
+
+	return builder.String()
+}
+
+// Escapes a text value of the vCard and iCalendar formats (RFC 6350, RFC 5545).
+func escapeText(s string) string {
+	replacer := strings.NewReplacer(`\`, `\\`, `;`, `\;`, `,`, `\,`, "\r\n", `\n`, "\n", `\n`)
+	return replacer.Replace(s)
+}
+
+// Joins content lines with the CRLF line break required by vCard and iCalendar.
+func joinLines(lines []string) string {
+	return strings.Join(lines, "\r\n")
+}
+
+func isASCII(s string) bool {
+	for i := 0; i < len(s); i++ {
+		if s[i] >= utf8.RuneSelf {
+			return false
+		}
+	}
+	return true
+}
+
+// Reports whether s is a bare address. Addresses with a display name, which net/mail
+// accepts, are rejected since the address is written in the payload as it is.
+func isEmailAddress(s string) bool {
+	address, err := mail.ParseAddress(s)
+	return err == nil && address.Address == s
+}
+
+func isPhoneNumber(s string) bool {
+	if strings.HasPrefix(s, "+") {
+		s = s[1:]
+	}
+
+	if s == "" {
+		return false
+	}
+
+	for _, r := range s {
+		if (r < '0' || r > '9') && r != ' ' && r != '-' {
+			return false
+		}
+	}
+	return true
+}
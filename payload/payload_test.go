@@ -0,0 +1,328 @@
+package payload
+
+import (
+	"errors"
+	"qr/qr-gen/qrerr"
+	"qr/qr-gen/versioner"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestWifi(t *testing.T) {
+	assert := assert.New(t)
+
+	tests := []struct {
+		name     string
+		input    Wifi
+		expected string
+		err      string
+	}{
+		{
+			name:     "WPA",
+			input:    Wifi{SSID: "home", Password: "pass;word", Security: WifiWPA},
+			expected: `WIFI:T:WPA;S:home;P:pass\;word;;`,
+		},
+		{
+			name:     "HiddenOpenNetwork",
+			input:    Wifi{SSID: `cafe "free"`, Security: WifiNoPass, Hidden: true},
+			expected: `WIFI:T:nopass;S:cafe \"free\";H:true;;`,
+		},
+		{
+			name:     "WEPHexKey",
+			input:    Wifi{SSID: "old", Password: "0123456789", Security: WifiWEP},
+			expected: `WIFI:T:WEP;S:old;P:0123456789;;`,
+		},
+		{
+			name:  "MissingSSID",
+			input: Wifi{Password: "password", Security: WifiWPA},
+			err:   "invalid Wi-Fi payload: SSID is required",
+		},
+		{
+			name:  "ShortWPAPassword",
+			input: Wifi{SSID: "home", Password: "short", Security: WifiWPA},
+			err:   "invalid Wi-Fi payload: password must be between 8 and 63 characters, or 64 hex digits for WPA",
+		},
+		{
+			name:     "WPAPreSharedKey",
+			input:    Wifi{SSID: "home", Password: strings.Repeat("0a", 32), Security: WifiWPA},
+			expected: `WIFI:T:WPA;S:home;P:` + strings.Repeat("0a", 32) + `;;`,
+		},
+		{
+			name:  "InvalidWPAPreSharedKey",
+			input: Wifi{SSID: "home", Password: strings.Repeat("0z", 32), Security: WifiWPA},
+			err:   "invalid Wi-Fi payload: password must be between 8 and 63 characters, or 64 hex digits for WPA",
+		},
+		{
+			name:  "InvalidWEPKey",
+			input: Wifi{SSID: "old", Password: "012345678z", Security: WifiWEP},
+			err:   "invalid Wi-Fi payload: password must be 5 or 13 characters, or 10 or 26 hex digits for WEP",
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			actual, err := Encode(test.input)
+
+			if test.err != "" {
+				assert.EqualError(err, test.err, "error messages should match")
+			} else {
+				assert.NoError(err)
+				assert.Equal(test.expected, actual, "payloads should match")
+			}
+		})
+	}
+}
+
+func TestVCard(t *testing.T) {
+	assert := assert.New(t)
+
+	card := VCard{
+		Version:      VCard3,
+		FirstName:    "Jane",
+		LastName:     "Doe",
+		Organization: "Acme, Inc.",
+		Phones:       []string{"+40 721 000 000"},
+		Emails:       []string{"jane@example.com"},
+		Address:      Address{Street: "1 Main St", City: "Cluj"},
+		Note:         "line1\nline2",
+	}
+
+	actual, err := Encode(card)
+	assert.NoError(err)
+	assert.Equal("BEGIN:VCARD\r\nVERSION:3.0\r\nN:Doe;Jane;;;\r\nFN:Jane Doe\r\nORG:Acme\\, Inc.\r\n"+
+		"TEL:+40 721 000 000\r\nEMAIL:jane@example.com\r\nADR:;;1 Main St;Cluj;;;\r\nNOTE:line1\\nline2\r\nEND:VCARD", actual, "payloads should match")
+
+	card.Version = VCard4
+	card.Organization, card.Address, card.Note = "", Address{}, ""
+	actual, err = Encode(card)
+	assert.NoError(err)
+	assert.Equal("BEGIN:VCARD\r\nVERSION:4.0\r\nN:Doe;Jane;;;\r\nFN:Jane Doe\r\n"+
+		"TEL;VALUE=uri:tel:+40721000000\r\nEMAIL:jane@example.com\r\nEND:VCARD", actual, "payloads should match")
+
+	_, err = Encode(VCard{Version: "2.1", FirstName: "Jane"})
+	assert.EqualError(err, "invalid vCard payload: version must be 3.0 or 4.0", "error messages should match")
+	assert.ErrorIs(err, qrerr.ErrInvalidInput, "validation errors should match the sentinel error")
+
+	_, err = Encode(VCard{Version: VCard4, FirstName: "Jane", Emails: []string{"jane"}})
+	assert.EqualError(err, "invalid vCard payload: email jane is not a valid address", "error messages should match")
+	_, err = Encode(VCard{Version: VCard4, FirstName: "Jane", Emails: []string{"Jane <jane@example.com>"}})
+	assert.EqualError(err, "invalid vCard payload: email Jane <jane@example.com> is not a valid address", "display names should be rejected")
+}
+
+func TestMeCard(t *testing.T) {
+	assert := assert.New(t)
+
+	actual, err := Encode(MeCard{FirstName: "Jane", LastName: "Doe", Phones: []string{"0721000000"}, Birthday: "19900131", Note: "a:b"})
+	assert.NoError(err)
+	assert.Equal(`MECARD:N:Doe,Jane;TEL:0721000000;BDAY:19900131;NOTE:a\:b;;`, actual, "payloads should match")
+
+	_, err = Encode(MeCard{FirstName: "Jane", Birthday: "1990-01-31"})
+	assert.EqualError(err, "invalid MeCard payload: birthday must be formatted as YYYYMMDD", "error messages should match")
+
+	_, err = Encode(MeCard{Phones: []string{"0721000000"}})
+	assert.EqualError(err, "invalid MeCard payload: name is required", "error messages should match")
+
+	_, err = Encode(MeCard{FirstName: "Jane", Emails: []string{"Jane <jane@example.com>"}})
+	assert.EqualError(err, "invalid MeCard payload: email Jane <jane@example.com> is not a valid address", "display names should be rejected")
+}
+
+func TestGeo(t *testing.T) {
+	assert := assert.New(t)
+	altitude := 340.5
+
+	actual, err := Encode(Geo{Latitude: 46.7712, Longitude: 23.6236})
+	assert.NoError(err)
+	assert.Equal("geo:46.7712,23.6236", actual, "payloads should match")
+
+	actual, err = Encode(Geo{Latitude: -33.8, Longitude: 151, Altitude: &altitude})
+	assert.NoError(err)
+	assert.Equal("geo:-33.8,151,340.5", actual, "payloads should match")
+
+	_, err = Encode(Geo{Latitude: 91, Longitude: 0})
+	assert.EqualError(err, "invalid geo payload: latitude must be between -90 and 90", "error messages should match")
+}
+
+func TestSMSAndEmail(t *testing.T) {
+	assert := assert.New(t)
+
+	actual, err := Encode(SMS{Number: "+40 721 000 000", Message: "Hi: see you; bring a\\b"})
+	assert.NoError(err)
+	assert.Equal(`SMSTO:+40721000000:Hi: see you; bring a\b`, actual, "messages should be written as they are")
+
+	_, err = Encode(SMS{Number: "call me"})
+	assert.EqualError(err, "invalid SMS payload: number is not a valid phone number", "error messages should match")
+
+	actual, err = Encode(Email{To: []string{"jane@example.com"}, Subject: "Hello there", Body: "a&b=c"})
+	assert.NoError(err)
+	assert.Equal("mailto:jane@example.com?subject=Hello%20there&body=a%26b%3Dc", actual, "payloads should match")
+
+	_, err = Encode(Email{Subject: "Hello"})
+	assert.EqualError(err, "invalid email payload: recipient is required", "error messages should match")
+
+	_, err = Encode(Email{To: []string{"jane@example.com"}, Cc: []string{"Bob <bob@example.com>"}})
+	assert.EqualError(err, "invalid email payload: address Bob <bob@example.com> is not a valid address", "display names should be rejected")
+}
+
+func TestEvent(t *testing.T) {
+	assert := assert.New(t)
+	start := time.Date(2026, 10, 19, 9, 0, 0, 0, time.FixedZone("EEST", 3*60*60))
+
+	actual, err := Encode(Event{Summary: "Review; QR", Start: start, End: start.Add(time.Hour), Location: "Room 1"})
+	assert.NoError(err)
+	assert.Equal("BEGIN:VEVENT\r\nSUMMARY:Review\\; QR\r\nDTSTART:20261019T060000Z\r\nDTEND:20261019T070000Z\r\n"+
+		"LOCATION:Room 1\r\nEND:VEVENT", actual, "payloads should match")
+
+	actual, err = Encode(Event{Summary: "Holiday", Start: start, AllDay: true})
+	assert.NoError(err)
+	assert.Equal("BEGIN:VEVENT\r\nSUMMARY:Holiday\r\nDTSTART;VALUE=DATE:20261019\r\nEND:VEVENT", actual, "payloads should match")
+
+	_, err = Encode(Event{Summary: "Review", Start: start, End: start.Add(-time.Hour)})
+	assert.EqualError(err, "invalid event payload: end must not be before the start", "error messages should match")
+}
+
+func TestGenerate(t *testing.T) {
+	assert := assert.New(t)
+
+	matrix, err := Generate(Geo{Latitude: 46.7712, Longitude: 23.6236}, versioner.QrEcMedium)
+	assert.NoError(err)
+	assert.NotNil(matrix)
+
+	_, err = Generate(Wifi{Security: WifiWPA}, versioner.QrEcMedium)
+	var validationErr *ValidationError
+	assert.True(errors.As(err, &validationErr), "validation errors should be returned before generation")
+	assert.Equal("SSID", validationErr.Field, "invalid fields should match")
+}
+
+func TestSepaCreditTransfer(t *testing.T) {
+	assert := assert.New(t)
+
+	transfer := SepaCreditTransfer{
+		Version: Epc002,
+		BIC:     "COBADEFFXXX",
+		Name:    "Jane Doe",
+		IBAN:    "de89 3704 0044 0532 0130 00",
+		Amount:  1230,
+		Text:    "Invoice 42",
+	}
+
+	actual, err := Encode(transfer)
+	assert.NoError(err)
+	assert.Equal("BCD\n002\n1\nSCT\nCOBADEFFXXX\nJane Doe\nDE89370400440532013000\nEUR12.30\n\n\nInvoice 42", actual, "payloads should match")
+
+	actual, err = Encode(SepaCreditTransfer{Version: Epc002, Name: "Jane Doe", IBAN: "DE89370400440532013000"})
+	assert.NoError(err)
+	assert.Equal("BCD\n002\n1\nSCT\n\nJane Doe\nDE89370400440532013000", actual, "trailing empty lines should be omitted")
+
+	tests := []struct {
+		name     string
+		transfer SepaCreditTransfer
+		err      string
+	}{
+		{"MissingBICInVersion001", SepaCreditTransfer{Version: Epc001, Name: "Jane", IBAN: "DE89370400440532013000"},
+			"invalid EPC payload: BIC is required by version 001"},
+		{"InvalidBIC", SepaCreditTransfer{Version: Epc002, BIC: "COBA1EFF", Name: "Jane", IBAN: "DE89370400440532013000"},
+			"invalid EPC payload: BIC is not a valid BIC"},
+		{"InvalidIBANCheckDigits", SepaCreditTransfer{Version: Epc002, Name: "Jane", IBAN: "DE88370400440532013000"},
+			"invalid EPC payload: IBAN is not a valid IBAN"},
+		{"AmountTooLarge", SepaCreditTransfer{Version: Epc002, Name: "Jane", IBAN: "DE89370400440532013000", Amount: 100000000000},
+			"invalid EPC payload: amount must be between 0.01 and 999999999.99 euro"},
+		{"ReferenceAndText", SepaCreditTransfer{Version: Epc002, Name: "Jane", IBAN: "DE89370400440532013000", Reference: "RF18", Text: "Invoice"},
+			"invalid EPC payload: remittance must be either a reference or a text"},
+		{"NameTooLong", SepaCreditTransfer{Version: Epc002, Name: strings.Repeat("a", 71), IBAN: "DE89370400440532013000"},
+			"invalid EPC payload: name must be at most 70 characters"},
+		{"LineBreak", SepaCreditTransfer{Version: Epc002, Name: "Jane", IBAN: "DE89370400440532013000", Information: "a\nb"},
+			"invalid EPC payload: information must not contain line breaks"},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			_, err := Encode(test.transfer)
+			assert.EqualError(err, test.err, "error messages should match")
+		})
+	}
+}
+
+func TestEmvMerchantPresented(t *testing.T) {
+	assert := assert.New(t)
+
+	merchant := EmvMerchantPresented{
+		Initiation: EmvDynamic,
+		Accounts: []MerchantAccount{
+			{ID: 26, Fields: []DataObject{{0, "COM.EXAMPLE"}, {1, "1234567890"}}},
+		},
+		CategoryCode:   "5411",
+		Currency:       "978",
+		Amount:         "12.30",
+		CountryCode:    "DE",
+		MerchantName:   "ACME Ltd",
+		MerchantCity:   "Berlin",
+		AdditionalData: []DataObject{{5, "INV-2026-01"}},
+	}
+
+	actual, err := Encode(merchant)
+	assert.NoError(err)
+	assert.Equal("000201010212"+"26290011COM.EXAMPLE01101234567890"+"52045411"+"5303978"+"540512.30"+"5802DE"+
+		"5908ACME Ltd"+"6006Berlin"+"62150511INV-2026-01"+"6304DD05", actual, "payloads should match")
+
+	tests := []struct {
+		name   string
+		modify func(m *EmvMerchantPresented)
+		err    string
+	}{
+		{"MissingAccount", func(m *EmvMerchantPresented) { m.Accounts = nil },
+			"invalid EMV payload: merchant account is required"},
+		{"MissingGloballyUniqueIdentifier", func(m *EmvMerchantPresented) {
+			m.Accounts = []MerchantAccount{{ID: 26, Fields: []DataObject{{1, "123"}}}}
+		},
+			"invalid EMV payload: merchant account 26 must start with a globally unique identifier field"},
+		{"AccountIDOutOfRange", func(m *EmvMerchantPresented) { m.Accounts = []MerchantAccount{{ID: 52, Value: "123"}} },
+			"invalid EMV payload: merchant account 52 must have an ID between 02 and 51"},
+		{"InvalidCurrency", func(m *EmvMerchantPresented) { m.Currency = "EUR" },
+			"invalid EMV payload: currency must be a 3 digit ISO 4217 code"},
+		{"InvalidAmount", func(m *EmvMerchantPresented) { m.Amount = "12,30" },
+			"invalid EMV payload: amount must be a decimal number of at most 13 characters"},
+		{"MerchantNameTooLong", func(m *EmvMerchantPresented) { m.MerchantName = strings.Repeat("a", 26) },
+			"invalid EMV payload: merchant name must be at most 25 characters"},
+		{"AdditionalDataTooLong", func(m *EmvMerchantPresented) { m.AdditionalData = []DataObject{{5, strings.Repeat("a", 96)}} },
+			"invalid EMV payload: additional data must be at most 99 characters"},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			modified := merchant
+			test.modify(&modified)
+			_, err := Encode(modified)
+			assert.EqualError(err, test.err, "error messages should match")
+		})
+	}
+}
+
+func TestCrc16(t *testing.T) {
+	assert := assert.New(t)
+
+	assert.Equal(uint16(0x29b1), crc16("123456789"), "checksum should match the CRC-16/CCITT-FALSE check value")
+}
+
+func TestGenerateByteModePayloads(t *testing.T) {
+	assert := assert.New(t)
+
+	// The 73 characters of the payload would fit a version 3 symbol in alphanumeric mode,
+	// they must still be encoded in byte mode
+	matrix, err := Generate(EmvMerchantPresented{
+		Accounts:     []MerchantAccount{{ID: 2, Value: "4111111111111111"}},
+		CategoryCode: "5411",
+		Currency:     "978",
+		CountryCode:  "DE",
+		MerchantName: "ACME",
+		MerchantCity: "BERLIN",
+	}, versioner.QrEcMedium)
+	assert.NoError(err)
+	assert.Equal(37+8, len(matrix.GetMatrix()), "symbol sizes should match the byte mode version")
+
+	matrix, err = Generate(SepaCreditTransfer{Version: Epc002, Name: "Zoë Łódź", IBAN: "DE89370400440532013000", Amount: 1230}, versioner.QrEcMedium)
+	assert.NoError(err)
+	assert.NotNil(matrix)
+}
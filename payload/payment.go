@@ -0,0 +1,371 @@
+package payload
+
+import (
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+	"unicode/utf8"
+)
+
+type EpcVersion string
+
+const (
+	Epc001 EpcVersion = "001"
+	Epc002 EpcVersion = "002"
+)
+
+// SepaCreditTransfer describes a SEPA credit transfer, serialized in the EPC069-12 format
+// (the "BCD" service tag) which banking apps scan to prefill a payment.
+// The amount is expressed in euro cents, a zero amount is left for the payer to fill in.
+// Only one of the structured creditor reference and the unstructured text may be set.
+type SepaCreditTransfer struct {
+	Version     EpcVersion
+	BIC         string
+	Name        string
+	IBAN        string
+	Amount      int64
+	Purpose     string
+	Reference   string
+	Text        string
+	Information string
+}
+
+// DataObject is an ID, length, value data object of the EMV merchant-presented format.
+type DataObject struct {
+	ID    int
+	Value string
+}
+
+// MerchantAccount holds the merchant account information of a payment network. IDs 2 to 25
+// are primitive data objects carrying a Value, while IDs 26 to 51 are templates whose Fields
+// start with the globally unique identifier of the network (ID 00).
+type MerchantAccount struct {
+	ID     int
+	Value  string
+	Fields []DataObject
+}
+
+type EmvInitiation string
+
+const (
+	EmvStatic  EmvInitiation = "11"
+	EmvDynamic EmvInitiation = "12"
+)
+
+// EmvMerchantPresented describes a merchant-presented payment code, serialized in the EMVCo
+// QR code specification format and terminated by its CRC-16/CCITT checksum.
+// The currency is the numeric ISO 4217 code and the country the ISO 3166-1 alpha-2 code.
+type EmvMerchantPresented struct {
+	Initiation     EmvInitiation
+	Accounts       []MerchantAccount
+	CategoryCode   string
+	Currency       string
+	Amount         string
+	CountryCode    string
+	MerchantName   string
+	MerchantCity   string
+	PostalCode     string
+	AdditionalData []DataObject
+}
+
+// Maximum length of an EPC069-12 payload, in bytes
+const epcMaxLength = 331
+
+// Largest amount of a SEPA credit transfer, 999999999.99 euro
+const epcMaxAmount = 99999999999
+
+const emvMaxValueLength = 99
+const emvCrcID = 63
+
+var ibanRegex = regexp.MustCompile(`^[A-Z]{2}\d{2}[A-Z0-9]{11,30}$`)
+var bicRegex = regexp.MustCompile(`^[A-Z]{4}[A-Z]{2}[A-Z0-9]{2}([A-Z0-9]{3})?$`)
+var emvAmountRegex = regexp.MustCompile(`^\d+(\.\d+)?$`)
+var countryCodeRegex = regexp.MustCompile(`^[A-Z]{2}$`)
+
+// Marks the payment payloads as byte mode payloads, since their readers parse the raw
+// bytes and expect them even when the text would fit a more compact mode.
+func (s SepaCreditTransfer) byteMode()   {}
+func (e EmvMerchantPresented) byteMode() {}
+
+func (s SepaCreditTransfer) Validate() error {
+	if s.Version != Epc001 && s.Version != Epc002 {
+		return &ValidationError{"EPC", "version", "must be 001 or 002"}
+	}
+
+	if s.BIC == "" && s.Version == Epc001 {
+		return &ValidationError{"EPC", "BIC", "is required by version 001"}
+	}
+	if s.BIC != "" && !bicRegex.MatchString(s.BIC) {
+		return &ValidationError{"EPC", "BIC", "is not a valid BIC"}
+	}
+
+	if !isIban(normalizeIban(s.IBAN)) {
+		return &ValidationError{"EPC", "IBAN", "is not a valid IBAN"}
+	}
+
+	if s.Amount < 0 || s.Amount > epcMaxAmount {
+		return &ValidationError{"EPC", "amount", "must be between 0.01 and 999999999.99 euro"}
+	}
+
+	if s.Reference != "" && s.Text != "" {
+		return &ValidationError{"EPC", "remittance", "must be either a reference or a text"}
+	}
+
+	fields := []struct {
+		name      string
+		value     string
+		maxLength int
+	}{
+		{"name", s.Name, 70},
+		{"purpose", s.Purpose, 4},
+		{"reference", s.Reference, 35},
+		{"text", s.Text, 140},
+		{"information", s.Information, 70},
+	}
+
+	for _, field := range fields {
+		if utf8.RuneCountInString(field.value) > field.maxLength {
+			return &ValidationError{"EPC", field.name, fmt.Sprintf("must be at most %d characters", field.maxLength)}
+		}
+		if strings.ContainsAny(field.value, "\r\n") {
+			return &ValidationError{"EPC", field.name, "must not contain line breaks"}
+		}
+	}
+
+	if s.Name == "" {
+		return &ValidationError{"EPC", "name", "is required"}
+	}
+
+	if len(s.String()) > epcMaxLength {
+		return &ValidationError{"EPC", "payload", fmt.Sprintf("must be at most %d bytes", epcMaxLength)}
+	}
+
+	return nil
+}
+
+// String returns the LF separated lines of the payload, with the trailing empty lines
+// omitted. The character set is always UTF-8.
+func (s SepaCreditTransfer) String() string {
+	amount := ""
+	if s.Amount > 0 {
+		amount = fmt.Sprintf("EUR%d.%02d", s.Amount/100, s.Amount%100)
+	}
+
+	lines := []string{
+		"BCD",
+		string(s.Version),
+		"1",
+		"SCT",
+		s.BIC,
+		s.Name,
+		normalizeIban(s.IBAN),
+		amount,
+		s.Purpose,
+		s.Reference,
+		s.Text,
+		s.Information,
+	}
+
+	return strings.TrimRight(strings.Join(lines, "\n"), "\n")
+}
+
+func (e EmvMerchantPresented) Validate() error {
+	if e.Initiation != "" && e.Initiation != EmvStatic && e.Initiation != EmvDynamic {
+		return &ValidationError{"EMV", "point of initiation", "must be 11 or 12"}
+	}
+
+	if len(e.Accounts) == 0 {
+		return &ValidationError{"EMV", "merchant account", "is required"}
+	}
+
+	ids := make(map[int]bool)
+	for _, account := range e.Accounts {
+		if err := validateMerchantAccount(account); err != nil {
+			return err
+		}
+		if ids[account.ID] {
+			return &ValidationError{"EMV", fmt.Sprintf("merchant account %02d", account.ID), "is duplicated"}
+		}
+		ids[account.ID] = true
+	}
+
+	if !isDigits(e.CategoryCode, 4) {
+		return &ValidationError{"EMV", "category code", "must be 4 digits"}
+	}
+
+	if !isDigits(e.Currency, 3) {
+		return &ValidationError{"EMV", "currency", "must be a 3 digit ISO 4217 code"}
+	}
+
+	if e.Amount != "" && (len(e.Amount) > 13 || !emvAmountRegex.MatchString(e.Amount)) {
+		return &ValidationError{"EMV", "amount", "must be a decimal number of at most 13 characters"}
+	}
+
+	if !countryCodeRegex.MatchString(e.CountryCode) {
+		return &ValidationError{"EMV", "country code", "must be a 2 letter ISO 3166-1 code"}
+	}
+
+	fields := []struct {
+		name      string
+		value     string
+		maxLength int
+	}{
+		{"merchant name", e.MerchantName, 25},
+		{"merchant city", e.MerchantCity, 15},
+		{"postal code", e.PostalCode, 10},
+	}
+
+	for _, field := range fields {
+		if utf8.RuneCountInString(field.value) > field.maxLength {
+			return &ValidationError{"EMV", field.name, fmt.Sprintf("must be at most %d characters", field.maxLength)}
+		}
+	}
+
+	if e.MerchantName == "" {
+		return &ValidationError{"EMV", "merchant name", "is required"}
+	}
+	if e.MerchantCity == "" {
+		return &ValidationError{"EMV", "merchant city", "is required"}
+	}
+
+	return validateTemplate("additional data", e.AdditionalData)
+}
+
+// String returns the data objects ordered by their IDs, followed by the CRC data object
+// whose checksum covers the whole payload, including the ID and length of the CRC itself.
+func (e EmvMerchantPresented) String() string {
+	var builder strings.Builder
+
+	builder.WriteString(emvDataObject(0, "01"))
+	if e.Initiation != "" {
+		builder.WriteString(emvDataObject(1, string(e.Initiation)))
+	}
+	accounts := append([]MerchantAccount(nil), e.Accounts...)
+	sort.Slice(accounts, func(i, j int) bool { return accounts[i].ID < accounts[j].ID })
+
+	for _, account := range accounts {
+		if account.ID <= 25 {
+			builder.WriteString(emvDataObject(account.ID, account.Value))
+		} else {
+			builder.WriteString(emvDataObject(account.ID, emvTemplate(account.Fields)))
+		}
+	}
+
+	builder.WriteString(emvDataObject(52, e.CategoryCode))
+	builder.WriteString(emvDataObject(53, e.Currency))
+	if e.Amount != "" {
+		builder.WriteString(emvDataObject(54, e.Amount))
+	}
+	builder.WriteString(emvDataObject(58, e.CountryCode))
+	builder.WriteString(emvDataObject(59, e.MerchantName))
+	builder.WriteString(emvDataObject(60, e.MerchantCity))
+	if e.PostalCode != "" {
+		builder.WriteString(emvDataObject(61, e.PostalCode))
+	}
+	if len(e.AdditionalData) > 0 {
+		builder.WriteString(emvDataObject(62, emvTemplate(e.AdditionalData)))
+	}
+
+	builder.WriteString(fmt.Sprintf("%02d04", emvCrcID))
+
+	return builder.String() + fmt.Sprintf("%04X", crc16(builder.String()))
+}
+
+func validateMerchantAccount(account MerchantAccount) error {
+	field := fmt.Sprintf("merchant account %02d", account.ID)
+
+	switch {
+	case account.ID < 2 || account.ID > 51:
+		return &ValidationError{"EMV", field, "must have an ID between 02 and 51"}
+	case account.ID <= 25:
+		if account.Value == "" || len(account.Fields) > 0 {
+			return &ValidationError{"EMV", field, "must have a value and no fields"}
+		}
+		if utf8.RuneCountInString(account.Value) > emvMaxValueLength {
+			return &ValidationError{"EMV", field, fmt.Sprintf("must be at most %d characters", emvMaxValueLength)}
+		}
+		return nil
+	default:
+		if account.Value != "" || len(account.Fields) == 0 || account.Fields[0].ID != 0 {
+			return &ValidationError{"EMV", field, "must start with a globally unique identifier field"}
+		}
+		return validateTemplate(field, account.Fields)
+	}
+}
+
+// Checks the IDs and lengths of the data objects of a template, and the length of the template itself
+func validateTemplate(field string, objects []DataObject) error {
+	for _, object := range objects {
+		if object.ID < 0 || object.ID > 99 {
+			return &ValidationError{"EMV", field, "must have field IDs between 00 and 99"}
+		}
+		if object.Value == "" || utf8.RuneCountInString(object.Value) > emvMaxValueLength {
+			return &ValidationError{"EMV", field, fmt.Sprintf("must have field values of 1 to %d characters", emvMaxValueLength)}
+		}
+	}
+
+	if utf8.RuneCountInString(emvTemplate(objects)) > emvMaxValueLength {
+		return &ValidationError{"EMV", field, fmt.Sprintf("must be at most %d characters", emvMaxValueLength)}
+	}
+
+	return nil
+}
+
+// Serializes a data object as its two digit ID, its two digit length and its value.
+// The length counts characters rather than bytes.
+func emvDataObject(id int, value string) string {
+	return fmt.Sprintf("%02d%02d%s", id, utf8.RuneCountInString(value), value)
+}
+
+func emvTemplate(objects []DataObject) string {
+	var builder strings.Builder
+
+	for _, object := range objects {
+		builder.WriteString(emvDataObject(object.ID, object.Value))
+	}
+
+	return builder.String()
+}
+
+// Computes the CRC-16/CCITT-FALSE checksum (polynomial 0x1021, initial value 0xFFFF)
+func crc16(s string) uint16 {
+	crc := uint16(0xffff)
+
+	for i := 0; i < len(s); i++ {
+		crc ^= uint16(s[i]) << 8
+		for bit := 0; bit < 8; bit++ {
+			if crc&0x8000 != 0 {
+				crc = crc<<1 ^ 0x1021
+			} else {
+				crc <<= 1
+			}
+		}
+	}
+
+	return crc
+}
+
+func normalizeIban(iban string) string {
+	return strings.ToUpper(strings.ReplaceAll(iban, " ", ""))
+}
+
+// Checks the format of the IBAN and its ISO 7064 MOD 97-10 check digits: once the first
+// four characters are moved to the end and letters replaced by 10 to 35, the number
+// must leave a remainder of 1 when divided by 97.
+func isIban(iban string) bool {
+	if !ibanRegex.MatchString(iban) {
+		return false
+	}
+
+	remainder := 0
+	for _, r := range iban[4:] + iban[:4] {
+		if r >= 'A' && r <= 'Z' {
+			remainder = (remainder*100 + int(r-'A'+10)) % 97
+		} else {
+			remainder = (remainder*10 + int(r-'0')) % 97
+		}
+	}
+
+	return remainder == 1
+}
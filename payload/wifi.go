@@ -0,0 +1,91 @@
+package payload
+
+import (
+	"encoding/hex"
+	"strings"
+)
+
+type WifiSecurity string
+
+const (
+	WifiWPA    WifiSecurity = "WPA"
+	WifiWEP    WifiSecurity = "WEP"
+	WifiNoPass WifiSecurity = "nopass"
+)
+
+// Wifi describes a network configuration, serialized as WIFI:T:WPA;S:ssid;P:password;H:true;;
+// WPA covers WPA, WPA2 and WPA3 personal networks.
+type Wifi struct {
+	SSID     string
+	Password string
+	Security WifiSecurity
+	Hidden   bool
+}
+
+const wifiSpecials = `\;,":`
+
+func (w Wifi) Validate() error {
+	if w.SSID == "" {
+		return &ValidationError{"Wi-Fi", "SSID", "is required"}
+	}
+
+	switch w.Security {
+	case WifiNoPass:
+		if w.Password != "" {
+			return &ValidationError{"Wi-Fi", "password", "is not allowed for open networks"}
+		}
+	case WifiWPA:
+		if !isWpaKey(w.Password) {
+			return &ValidationError{"Wi-Fi", "password", "must be between 8 and 63 characters, or 64 hex digits for WPA"}
+		}
+	case WifiWEP:
+		if !isWepKey(w.Password) {
+			return &ValidationError{"Wi-Fi", "password", "must be 5 or 13 characters, or 10 or 26 hex digits for WEP"}
+		}
+	default:
+		return &ValidationError{"Wi-Fi", "security", "must be WPA, WEP or nopass"}
+	}
+
+	return nil
+}
+
+func (w Wifi) String() string {
+	var builder strings.Builder
+
+	builder.WriteString("WIFI:T:" + string(w.Security) + ";")
+	builder.WriteString("S:" + escapeFields(w.SSID, wifiSpecials) + ";")
+	if w.Security != WifiNoPass {
+		builder.WriteString("P:" + escapeFields(w.Password, wifiSpecials) + ";")
+	}
+	if w.Hidden {
+		builder.WriteString("H:true;")
+	}
+	builder.WriteString(";")
+
+	return builder.String()
+}
+
+// A WPA key is either a passphrase or the 256 bit pre-shared key written in hex digits
+func isWpaKey(key string) bool {
+	switch {
+	case len(key) >= 8 && len(key) <= 63:
+		return true
+	case len(key) == 64:
+		_, err := hex.DecodeString(key)
+		return err == nil
+	default:
+		return false
+	}
+}
+
+func isWepKey(key string) bool {
+	switch len(key) {
+	case 5, 13:
+		return true
+	case 10, 26:
+		_, err := hex.DecodeString(key)
+		return err == nil
+	default:
+		return false
+	}
+}
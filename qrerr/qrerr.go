@@ -0,0 +1,58 @@
+// Package qrerr holds the errors returned by the stages of the pipeline. The sentinel
+// errors are matched with errors.Is, the struct errors carry the details of the failure
+// and are extracted with errors.As, while also matching their sentinel with errors.Is.
+package qrerr
+
+import (
+	"errors"
+	"fmt"
+)
+
+var (
+	ErrEmptyInput        = errors.New("Empty input")
+	ErrInvalidInput      = errors.New("Invalid input")
+	ErrInvalidLevel      = errors.New("Invalid error correction level")
+	ErrInvalidMode       = errors.New("Invalid encoding mode")
+	ErrInvalidVersion    = errors.New("Invalid version")
+	ErrCapacityExceeded  = errors.New("Capacity exceeded")
+	ErrInvalidLength     = errors.New("Invalid data length")
+	ErrOutOfRange        = errors.New("Index out of range")
+	ErrDimensionMismatch = errors.New("Dimensions do not match")
+	ErrUnreadable        = errors.New("Unreadable symbol")
+	ErrInvalidSignature  = errors.New("Invalid signature")
+	ErrUnknownKey        = errors.New("Unknown key")
+	ErrDecryptionFailed  = errors.New("Decryption failed")
+	ErrKeyExhausted      = errors.New("Key exhausted")
+)
+
+// ErrDataTooLong reports an input which does not fit the largest symbol at the error
+// correction level. Needed and Max are numbers of data bits.
+type ErrDataTooLong struct {
+	Needed int
+	Max    int
+	Level  rune
+}
+
+func (e *ErrDataTooLong) Error() string {
+	return fmt.Sprintf("Data too long: %d bits needed, %d bits available at level %c", e.Needed, e.Max, e.Level)
+}
+
+func (e *ErrDataTooLong) Is(target error) bool {
+	return target == ErrCapacityExceeded
+}
+
+// ErrInvalidCharacter reports a character which cannot be encoded in the mode. Pos is
+// the byte offset of the character in the input.
+type ErrInvalidCharacter struct {
+	Pos  int
+	Rune rune
+	Mode string
+}
+
+func (e *ErrInvalidCharacter) Error() string {
+	return fmt.Sprintf("Invalid character %q at position %d in %s mode", e.Rune, e.Pos, e.Mode)
+}
+
+func (e *ErrInvalidCharacter) Is(target error) bool {
+	return target == ErrInvalidInput
+}
@@ -0,0 +1,337 @@
+package rmqr
+
+import (
+	"fmt"
+	"qr/qr-gen/bitbuf"
+	"qr/qr-gen/matrix"
+	"qr/qr-gen/qrerr"
+	"qr/qr-gen/util"
+	"qr/qr-gen/versioner"
+)
+
+type RmqrModulerInterface interface {
+	CreateModuleMatrix(data string) (*matrix.Matrix[util.Module], error)
+	CreateModuleMatrixFromBits(data *bitbuf.Buffer) (*matrix.Matrix[util.Module], error)
+}
+
+// RmqrModuler builds rMQR symbols of a single version and level. It is safe for concurrent use.
+type RmqrModuler struct {
+	version      RmqrVersion
+	ecLevel      versioner.QrEcLevel
+	options      Options
+	moduleMatrix *matrix.Matrix[util.Module]
+}
+
+// Options configures the symbols built by the moduler. Start from DefaultOptions, as the
+// zero value has no quiet zone.
+type Options struct {
+	// QuietZone is the width in modules of the light margin around the symbol
+	QuietZone int
+}
+
+const finderPatternSize = 7
+const subFinderPatternSize = 5
+
+// DefaultQuietZone is the quiet zone width required by the specification
+const DefaultQuietZone = 2
+
+const formatInformationLength = 18
+const formatInformationGenerator = 0x1f25
+const formatInformationLeftMask = 0x1fab2
+const formatInformationRightMask = 0x20a7b
+
+// Column centers of the alignment patterns, indexed by the symbol width
+var alignmentPatternColumns = map[int][]int{
+	27:  {},
+	43:  {21},
+	59:  {19, 39},
+	77:  {25, 51},
+	99:  {23, 49, 75},
+	139: {27, 55, 83, 111},
+}
+
+// DefaultOptions surrounds the symbol with the quiet zone of the specification
+func DefaultOptions() Options {
+	return Options{QuietZone: DefaultQuietZone}
+}
+
+func NewModuler(version RmqrVersion, ecLevel versioner.QrEcLevel) RmqrModulerInterface {
+	return NewModulerWithOptions(version, ecLevel, DefaultOptions())
+}
+
+func NewModulerWithOptions(version RmqrVersion, ecLevel versioner.QrEcLevel, options Options) RmqrModulerInterface {
+	return &RmqrModuler{
+		version: version,
+		ecLevel: ecLevel,
+		options: options,
+	}
+}
+
+// CreateModuleMatrix places the data in the symbol. rMQR symbols use a single
+// fixed mask pattern, so no mask evaluation takes place.
+func (m *RmqrModuler) CreateModuleMatrix(data string) (*matrix.Matrix[util.Module], error) {
+	b, err := bitbuf.FromString(data)
+	if err != nil {
+		return nil, err
+	}
+	return m.CreateModuleMatrixFromBits(b)
+}
+
+// CreateModuleMatrixFromBits places the final message in the symbol. The data must fill
+// every data module of the symbol, remainder bits included.
+func (m *RmqrModuler) CreateModuleMatrixFromBits(data *bitbuf.Buffer) (*matrix.Matrix[util.Module], error) {
+	if err := m.validate(); err != nil {
+		return nil, err
+	}
+
+	return m.copy().createModuleMatrix(data)
+}
+
+func (m *RmqrModuler) validate() error {
+	info, ok := rmqrVersionInfos[m.version]
+	if !ok {
+		return fmt.Errorf("%w %d for rMQR", qrerr.ErrInvalidVersion, int(m.version))
+	}
+
+	if _, ok := info.Blocks[m.ecLevel]; !ok {
+		return fmt.Errorf("%w %q for rMQR", qrerr.ErrInvalidLevel, m.ecLevel)
+	}
+
+	return nil
+}
+
+func (m *RmqrModuler) copy() *RmqrModuler {
+	return &RmqrModuler{version: m.version, ecLevel: m.ecLevel, options: m.options}
+}
+
+func (m *RmqrModuler) createModuleMatrix(data *bitbuf.Buffer) (*matrix.Matrix[util.Module], error) {
+	m.prepareModuleMatrix()
+
+	if capacity := m.dataModulesCount(); data.Len() != capacity {
+		return nil, fmt.Errorf("%w: %d data bits for %d data modules", qrerr.ErrInvalidLength, data.Len(), capacity)
+	}
+
+	m.placeDataBits(data)
+	m.setFormatInformationModules()
+
+	if err := m.moduleMatrix.Expand(m.options.QuietZone, util.Module_QUIET_ZONE); err != nil {
+		return nil, err
+	}
+
+	return m.moduleMatrix, nil
+}
+
+func (m *RmqrModuler) dataModulesCount() int {
+	return m.moduleMatrix.Count(func(module util.Module) bool {
+		return module == util.Module_EMPTY
+	})
+}
+
+func (m *RmqrModuler) prepareModuleMatrix() {
+	m.moduleMatrix = matrix.NewMatrix[util.Module](m.version.Width(), m.version.Height())
+	m.moduleMatrix.Init(util.Module_EMPTY)
+
+	m.setFinderPattern()
+	m.setSubFinderPattern()
+	m.setCornerFinderPatterns()
+	m.setAlignmentPatterns()
+	m.setTimingPatterns()
+	m.reserveFormatArea()
+}
+
+// Sets the finder pattern in the top left corner and its separator
+func (m *RmqrModuler) setFinderPattern() {
+	for i := 0; i < finderPatternSize; i++ {
+		for j := 0; j < finderPatternSize; j++ {
+			isBorder := i == 0 || j == 0 || i == finderPatternSize-1 || j == finderPatternSize-1
+			isCenter := i >= 2 && i <= finderPatternSize-3 && j >= 2 && j <= finderPatternSize-3
+
+			if isBorder || isCenter {
+				m.moduleMatrix.Set(i, j, util.Module_FINDER_DARKEN)
+			} else {
+				m.moduleMatrix.Set(i, j, util.Module_FINDER_LIGHTEN)
+			}
+		}
+	}
+
+	// R7 symbols have no room for the separator below the finder pattern
+	for i := 0; i <= finderPatternSize; i++ {
+		m.moduleMatrix.Set(i, finderPatternSize, util.Module_SEPARATOR)
+		m.moduleMatrix.Set(finderPatternSize, i, util.Module_SEPARATOR)
+	}
+}
+
+// Sets the finder sub pattern in the bottom right corner
+func (m *RmqrModuler) setSubFinderPattern() {
+	top, left := m.version.Height()-subFinderPatternSize, m.version.Width()-subFinderPatternSize
+
+	for i := 0; i < subFinderPatternSize; i++ {
+		for j := 0; j < subFinderPatternSize; j++ {
+			isBorder := i == 0 || j == 0 || i == subFinderPatternSize-1 || j == subFinderPatternSize-1
+
+			if isBorder || (i == 2 && j == 2) {
+				m.moduleMatrix.Set(top+i, left+j, util.Module_FINDER_DARKEN)
+			} else {
+				m.moduleMatrix.Set(top+i, left+j, util.Module_FINDER_LIGHTEN)
+			}
+		}
+	}
+}
+
+// Sets the corner finder patterns in the top right and bottom left corners
+func (m *RmqrModuler) setCornerFinderPatterns() {
+	height, width := m.version.Height(), m.version.Width()
+
+	m.moduleMatrix.Set(0, width-2, util.Module_FINDER_DARKEN)
+	m.moduleMatrix.Set(0, width-1, util.Module_FINDER_DARKEN)
+	m.moduleMatrix.Set(1, width-2, util.Module_FINDER_LIGHTEN)
+	m.moduleMatrix.Set(1, width-1, util.Module_FINDER_DARKEN)
+
+	// In R7 symbols the bottom left corner belongs to the finder pattern, and in R9
+	// symbols the row above it belongs to the separator
+	for j := 0; j < 3; j++ {
+		if val, _ := m.moduleMatrix.At(height-1, j); val == util.Module_EMPTY {
+			m.moduleMatrix.Set(height-1, j, util.Module_FINDER_DARKEN)
+		}
+	}
+
+	if height > finderPatternSize+2 {
+		m.moduleMatrix.Set(height-2, 0, util.Module_FINDER_DARKEN)
+		m.moduleMatrix.Set(height-2, 1, util.Module_FINDER_LIGHTEN)
+	}
+}
+
+// Sets the alignment patterns at the top and bottom edges
+func (m *RmqrModuler) setAlignmentPatterns() {
+	height := m.version.Height()
+
+	for _, col := range alignmentPatternColumns[m.version.Width()] {
+		for _, row := range []int{1, height - 2} {
+			for i := row - 1; i <= row+1; i++ {
+				for j := col - 1; j <= col+1; j++ {
+					if i == row && j == col {
+						m.moduleMatrix.Set(i, j, util.Module_ALIGNMENT_LIGHTEN)
+					} else {
+						m.moduleMatrix.Set(i, j, util.Module_ALIGNMENT_DARKEN)
+					}
+				}
+			}
+		}
+	}
+}
+
+// Sets the timing patterns along the edges and through the alignment pattern columns
+func (m *RmqrModuler) setTimingPatterns() {
+	height, width := m.version.Height(), m.version.Width()
+
+	for j := 0; j < width; j++ {
+		m.setTimingModule(0, j, j)
+		m.setTimingModule(height-1, j, j)
+	}
+
+	columns := append([]int{0, width - 1}, alignmentPatternColumns[width]...)
+	for _, j := range columns {
+		for i := 0; i < height; i++ {
+			m.setTimingModule(i, j, i)
+		}
+	}
+}
+
+func (m *RmqrModuler) setTimingModule(row, col, index int) {
+	if val, _ := m.moduleMatrix.At(row, col); val != util.Module_EMPTY {
+		return
+	}
+
+	if index%2 == 0 {
+		m.moduleMatrix.Set(row, col, util.Module_TIMING_DARKEN)
+	} else {
+		m.moduleMatrix.Set(row, col, util.Module_TIMING_LIGHTEN)
+	}
+}
+
+// Reserves the two format information areas next to the finder pattern and the finder sub pattern
+func (m *RmqrModuler) reserveFormatArea() {
+	for _, c := range m.formatInformationCoordinates() {
+		m.moduleMatrix.Set(c[0], c[1], util.Module_RESERVED)
+		m.moduleMatrix.Set(c[2], c[3], util.Module_RESERVED)
+	}
+}
+
+// Places the encoded data bits in two module wide columns, starting from the bottom right
+// corner and moving upwards, masking the data modules with the single rMQR mask pattern
+func (m *RmqrModuler) placeDataBits(data *bitbuf.Buffer) {
+	height, width := m.version.Height(), m.version.Width()
+	indexInBits := 0
+	isUpwardMovement := true
+
+	for col := width - 2; col > 0; col -= 2 {
+		for k := 0; k < height; k++ {
+			row := k
+			if isUpwardMovement {
+				row = height - 1 - k
+			}
+
+			for _, c := range []int{col, col - 1} {
+				if val, _ := m.moduleMatrix.At(row, c); val != util.Module_EMPTY {
+					continue
+				}
+
+				bit := data.Bit(indexInBits)
+				if (row/2+c/3)%2 == 0 {
+					bit ^= 1
+				}
+
+				m.moduleMatrix.Set(row, c, util.GetDataModule(bit))
+				indexInBits += 1
+			}
+		}
+
+		isUpwardMovement = !isUpwardMovement
+	}
+}
+
+// Sets both copies of the format information, each masked with its own pattern
+func (m *RmqrModuler) setFormatInformationModules() {
+	format := m.computeFormatInformation()
+	left, right := format^formatInformationLeftMask, format^formatInformationRightMask
+
+	for n, c := range m.formatInformationCoordinates() {
+		m.moduleMatrix.Set(c[0], c[1], util.GetFormatModule((left>>n)&1))
+		m.moduleMatrix.Set(c[2], c[3], util.GetFormatModule((right>>n)&1))
+	}
+}
+
+// Computes the 18 bit format information, made of the error correction level bit,
+// the 5 bit version indicator and 12 BCH error correction bits
+func (m *RmqrModuler) computeFormatInformation() int {
+	data := int(m.version)
+	if m.ecLevel == versioner.QrECHigh {
+		data |= 1 << 5
+	}
+
+	remainder := data << 12
+	for i := formatInformationLength - 1; i >= 12; i-- {
+		if remainder&(1<<i) != 0 {
+			remainder ^= formatInformationGenerator << (i - 12)
+		}
+	}
+
+	return data<<12 | remainder
+}
+
+// Lists, for every format information bit from the least significant one, the coordinates
+// of the module next to the finder pattern followed by the one next to the finder sub pattern
+func (m *RmqrModuler) formatInformationCoordinates() [][4]int {
+	height, width := m.version.Height(), m.version.Width()
+	coords := make([][4]int, formatInformationLength)
+
+	for n := 0; n < 15; n++ {
+		coords[n] = [4]int{1 + n%5, 8 + n/5, height - 6 + n%5, width - 8 + n/5}
+	}
+
+	for n := 15; n < formatInformationLength; n++ {
+		coords[n] = [4]int{1 + n - 15, 11, height - 6, width - 5 + n - 15}
+	}
+
+	return coords
+}
@@ -0,0 +1,457 @@
+package rmqr
+
+import (
+	"fmt"
+	"qr/qr-gen/bitbuf"
+	"qr/qr-gen/ec"
+	"qr/qr-gen/encoder"
+	"qr/qr-gen/matrix"
+	"qr/qr-gen/qrerr"
+	"qr/qr-gen/util"
+	"qr/qr-gen/versioner"
+	"strconv"
+)
+
+// RmqrVersion is the 5 bit version indicator of a rectangular Micro QR symbol,
+// ranging from R7x43 (0) to R17x139 (31).
+type RmqrVersion int
+
+type RmqrEncoder interface {
+	GetVersion(s string, mode versioner.QrMode, lvl versioner.QrEcLevel) (RmqrVersion, error)
+	GetVersionWithHeight(s string, mode versioner.QrMode, lvl versioner.QrEcLevel, height int) (RmqrVersion, error)
+	GetCountIndicator(s string, version RmqrVersion, mode versioner.QrMode) (string, error)
+	Encode(s string, version RmqrVersion) (string, error)
+	AugmentEncodedInput(s string, version RmqrVersion, lvl versioner.QrEcLevel) (string, error)
+	GetFinalMessage(encoded string, version RmqrVersion, lvl versioner.QrEcLevel) (string, error)
+	EncodeBits(s string, version RmqrVersion) (*bitbuf.Buffer, error)
+	AugmentEncodedBits(b *bitbuf.Buffer, version RmqrVersion, lvl versioner.QrEcLevel) error
+	GetFinalMessageBits(encoded *bitbuf.Buffer, version RmqrVersion, lvl versioner.QrEcLevel) (*bitbuf.Buffer, error)
+}
+
+type QrRmqrEncoder struct{}
+
+type rmqrBlock struct {
+	Count          int
+	TotalCodewords int
+	DataCodewords  int
+}
+
+type rmqrVersionInfo struct {
+	Height          int
+	Width           int
+	RemainderBits   int
+	CountIndLengths []int
+	Blocks          map[versioner.QrEcLevel][]rmqrBlock
+}
+
+const rmqrTerminatorLength = 3
+
+func NewEncoder() RmqrEncoder {
+	return &QrRmqrEncoder{}
+}
+
+// Generate encodes the input into the smallest rMQR symbol, by area, supporting
+// the given error correction level. Only the medium and high levels are available.
+func Generate(s string, lvl versioner.QrEcLevel) (*matrix.Matrix[util.Module], error) {
+	return generate(s, lvl, 0, DefaultOptions())
+}
+
+// GenerateWithOptions encodes the input like Generate, building the symbol with the given
+// options.
+func GenerateWithOptions(s string, lvl versioner.QrEcLevel, options Options) (*matrix.Matrix[util.Module], error) {
+	return generate(s, lvl, 0, options)
+}
+
+// GenerateWithHeight encodes the input into the narrowest rMQR symbol of the given height.
+func GenerateWithHeight(s string, lvl versioner.QrEcLevel, height int) (*matrix.Matrix[util.Module], error) {
+	return generate(s, lvl, height, DefaultOptions())
+}
+
+func generate(s string, lvl versioner.QrEcLevel, height int, options Options) (*matrix.Matrix[util.Module], error) {
+	e := NewEncoder()
+
+	mode, err := versioner.New().GetMode(s)
+	if err != nil {
+		return nil, fmt.Errorf("Error on computing the encoding mode: %w", err)
+	}
+
+	var version RmqrVersion
+	if height == 0 {
+		version, err = e.GetVersion(s, mode, lvl)
+	} else {
+		version, err = e.GetVersionWithHeight(s, mode, lvl, height)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("Error on computing the encoding version: %w", err)
+	}
+
+	encoded, err := e.EncodeBits(s, version)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := e.AugmentEncodedBits(encoded, version, lvl); err != nil {
+		return nil, fmt.Errorf("Error on augmenting the encoded data: %w", err)
+	}
+
+	data, err := e.GetFinalMessageBits(encoded, version, lvl)
+	if err != nil {
+		return nil, fmt.Errorf("Error on computing the final message: %w", err)
+	}
+
+	matrix, err := NewModulerWithOptions(version, lvl, options).CreateModuleMatrixFromBits(data)
+	if err != nil {
+		return nil, fmt.Errorf("Error on placing the modules: %w", err)
+	}
+
+	return matrix, nil
+}
+
+func (e *QrRmqrEncoder) GetVersion(s string, mode versioner.QrMode, lvl versioner.QrEcLevel) (RmqrVersion, error) {
+	best := RmqrVersion(-1)
+
+	for version := RmqrVersion(0); int(version) < len(rmqrVersionInfos); version++ {
+		if !e.fits(s, mode, lvl, version) {
+			continue
+		}
+
+		if best == -1 || version.Height()*version.Width() < best.Height()*best.Width() {
+			best = version
+		}
+	}
+
+	if best == -1 {
+		return best, e.versionError(s, mode, lvl, 0)
+	}
+	return best, nil
+}
+
+func (e *QrRmqrEncoder) GetVersionWithHeight(s string, mode versioner.QrMode, lvl versioner.QrEcLevel, height int) (RmqrVersion, error) {
+	for version := RmqrVersion(0); int(version) < len(rmqrVersionInfos); version++ {
+		if version.Height() == height && e.fits(s, mode, lvl, version) {
+			return version, nil
+		}
+	}
+
+	return RmqrVersion(-1), e.versionError(s, mode, lvl, height)
+}
+
+// Reports why no symbol, of the given height unless it is zero, holds the input: the
+// level or the height may not exist, otherwise the input exceeds the largest symbol
+func (e *QrRmqrEncoder) versionError(s string, mode versioner.QrMode, lvl versioner.QrEcLevel, height int) error {
+	if _, ok := rmqrModeIndices[mode]; !ok {
+		return fmt.Errorf("%w %q", qrerr.ErrInvalidMode, mode)
+	}
+
+	largest := RmqrVersion(-1)
+	for version := RmqrVersion(0); int(version) < len(rmqrVersionInfos); version++ {
+		if _, ok := rmqrVersionInfos[version].Blocks[lvl]; !ok || (height != 0 && version.Height() != height) {
+			continue
+		}
+
+		if largest == -1 || rmqrDataCodewords(version, lvl) > rmqrDataCodewords(largest, lvl) {
+			largest = version
+		}
+	}
+
+	if largest == -1 && height != 0 {
+		return fmt.Errorf("%w: no rMQR symbol of height %d at level %c", qrerr.ErrInvalidVersion, height, lvl)
+	} else if largest == -1 {
+		return fmt.Errorf("%w %q for rMQR", qrerr.ErrInvalidLevel, lvl)
+	}
+
+	countIndLength := rmqrVersionInfos[largest].CountIndLengths[rmqrModeIndices[mode]]
+	return &qrerr.ErrDataTooLong{
+		Needed: len(rmqrModeIndicators[mode]) + countIndLength + versioner.New().GetDataLength(s, mode),
+		Max:    util.QrCodewordSize * rmqrDataCodewords(largest, lvl),
+		Level:  rune(lvl),
+	}
+}
+
+func (e *QrRmqrEncoder) GetCountIndicator(s string, version RmqrVersion, mode versioner.QrMode) (string, error) {
+	length, err := e.getCountIndicatorLength(s, version, mode)
+	if err != nil {
+		return "", err
+	}
+
+	sLenBin := strconv.FormatInt(int64(len(s)), 2)
+	return util.PadLeft(sLenBin, "0", length), nil
+}
+
+func (e *QrRmqrEncoder) getCountIndicatorLength(s string, version RmqrVersion, mode versioner.QrMode) (int, error) {
+	length, err := countIndicatorLength(version, mode)
+	if err != nil {
+		return 0, err
+	}
+	if len(s) >= 1<<length {
+		return 0, fmt.Errorf("%w: input length %d exceeds the count indicator of %s", qrerr.ErrCapacityExceeded, len(s), version)
+	}
+	return length, nil
+}
+
+func (e *QrRmqrEncoder) Encode(s string, version RmqrVersion) (string, error) {
+	b, err := e.EncodeBits(s, version)
+	if err != nil {
+		return "", err
+	}
+	return b.String(), nil
+}
+
+// EncodeBits encodes the input, preceded by its mode and count indicators, in the most
+// compact mode
+func (e *QrRmqrEncoder) EncodeBits(s string, version RmqrVersion) (*bitbuf.Buffer, error) {
+	mode, err := versioner.New().GetMode(s)
+	if err != nil {
+		return nil, fmt.Errorf("Error on computing the encoding mode: %w", err)
+	}
+
+	countIndLength, err := e.getCountIndicatorLength(s, version, mode)
+	if err != nil {
+		return nil, fmt.Errorf("Error on computing the encoding count indicator: %w", err)
+	}
+
+	encodedInput, err := encoder.New().EncodeInputBits(s, mode)
+	if err != nil {
+		return nil, err
+	}
+
+	b := bitbuf.New()
+	b.AppendString(rmqrModeIndicators[mode])
+	b.AppendBits(len(s), countIndLength)
+	b.AppendBuffer(encodedInput)
+
+	return b, nil
+}
+
+func (e *QrRmqrEncoder) AugmentEncodedInput(s string, version RmqrVersion, lvl versioner.QrEcLevel) (string, error) {
+	b, err := bitbuf.FromString(s)
+	if err != nil {
+		return "", err
+	}
+
+	if err := e.AugmentEncodedBits(b, version, lvl); err != nil {
+		return "", err
+	}
+	return b.String(), nil
+}
+
+// AugmentEncodedBits fills the encoded data up to the data codewords of the symbol with the
+// terminator, the zero bits completing the last codeword and the padding codewords.
+// Encoded data exceeding the capacity is rejected.
+func (e *QrRmqrEncoder) AugmentEncodedBits(b *bitbuf.Buffer, version RmqrVersion, lvl versioner.QrEcLevel) error {
+	if _, ok := rmqrVersionInfos[version].Blocks[lvl]; !ok {
+		return fmt.Errorf("%w %s-%c", qrerr.ErrInvalidVersion, version, lvl)
+	}
+
+	requiredBitsCount := util.QrCodewordSize * rmqrDataCodewords(version, lvl)
+	if b.Len() > requiredBitsCount {
+		return &qrerr.ErrDataTooLong{Needed: b.Len(), Max: requiredBitsCount, Level: rune(lvl)}
+	}
+
+	b.AppendBits(0, util.Min(rmqrTerminatorLength, requiredBitsCount-b.Len()))
+	b.AppendBits(0, (b.Len()+util.QrCodewordSize-1)/util.QrCodewordSize*util.QrCodewordSize-b.Len())
+
+	for paddingByteIndex := 0; b.Len() < requiredBitsCount; paddingByteIndex++ {
+		b.AppendString(encoder.QR_PADDING_BYTES[encoder.QrPaddingByte(paddingByteIndex%2)])
+	}
+
+	return nil
+}
+
+func (e *QrRmqrEncoder) GetFinalMessage(encoded string, version RmqrVersion, lvl versioner.QrEcLevel) (string, error) {
+	b, err := bitbuf.FromString(encoded)
+	if err != nil {
+		return "", err
+	}
+
+	result, err := e.GetFinalMessageBits(b, version, lvl)
+	if err != nil {
+		return "", err
+	}
+	return result.String(), nil
+}
+
+// GetFinalMessageBits splits the data codewords into blocks, computes the error correction
+// codewords of each block and interleaves both, followed by the remainder bits. The encoded
+// data must already be augmented up to the data codewords of the symbol.
+func (e *QrRmqrEncoder) GetFinalMessageBits(encoded *bitbuf.Buffer, version RmqrVersion, lvl versioner.QrEcLevel) (*bitbuf.Buffer, error) {
+	blocks, ok := rmqrVersionInfos[version].Blocks[lvl]
+	if !ok {
+		return nil, fmt.Errorf("%w %s-%c", qrerr.ErrInvalidVersion, version, lvl)
+	}
+
+	if required := util.QrCodewordSize * rmqrDataCodewords(version, lvl); encoded.Len() != required {
+		return nil, fmt.Errorf("%w: %d encoded bits instead of %d", qrerr.ErrInvalidLength, encoded.Len(), required)
+	}
+
+	corrector := ec.New()
+	codewords := encoded.Bytes()
+	var dataBlocks, ecBlocks [][]byte
+
+	for _, block := range blocks {
+		for i := 0; i < block.Count; i++ {
+			blockCodewords := codewords[:block.DataCodewords]
+			codewords = codewords[block.DataCodewords:]
+
+			errCorrCodewords, err := corrector.GetErrorCorrectionBytes(blockCodewords, block.TotalCodewords-block.DataCodewords)
+			if err != nil {
+				return nil, err
+			}
+			dataBlocks = append(dataBlocks, blockCodewords)
+			ecBlocks = append(ecBlocks, errCorrCodewords)
+		}
+	}
+
+	result := bitbuf.New()
+	result.AppendBytes(e.interleaveCodewords(dataBlocks))
+	result.AppendBytes(e.interleaveCodewords(ecBlocks))
+	result.AppendBits(0, rmqrVersionInfos[version].RemainderBits)
+
+	return result, nil
+}
+
+func (e *QrRmqrEncoder) interleaveCodewords(blocks [][]byte) []byte {
+	var result []byte
+
+	for i := 0; ; i++ {
+		written := false
+		for _, block := range blocks {
+			if i < len(block) {
+				result = append(result, block[i])
+				written = true
+			}
+		}
+
+		if !written {
+			return result
+		}
+	}
+}
+
+func (e *QrRmqrEncoder) fits(s string, mode versioner.QrMode, lvl versioner.QrEcLevel, version RmqrVersion) bool {
+	if _, ok := rmqrVersionInfos[version].Blocks[lvl]; !ok {
+		return false
+	}
+
+	capacity, err := GetCharacterCapacity(version, lvl, mode)
+	return err == nil && len(s) <= capacity
+}
+
+// GetCharacterCapacity computes the number of characters of the given mode
+// that fit in the data codewords of a symbol.
+func GetCharacterCapacity(version RmqrVersion, lvl versioner.QrEcLevel, mode versioner.QrMode) (int, error) {
+	countIndLength, err := countIndicatorLength(version, mode)
+	if err != nil {
+		return 0, err
+	}
+	if _, ok := rmqrVersionInfos[version].Blocks[lvl]; !ok {
+		return 0, fmt.Errorf("%w %s-%c", qrerr.ErrInvalidVersion, version, lvl)
+	}
+
+	bits := util.QrCodewordSize*rmqrDataCodewords(version, lvl) - len(rmqrModeIndicators[mode]) - countIndLength
+
+	capacity := 0
+	switch mode {
+	case versioner.QrNumericMode:
+		capacity = bits / 10 * 3
+		if rest := bits % 10; rest >= 7 {
+			capacity += 2
+		} else if rest >= 4 {
+			capacity += 1
+		}
+	case versioner.QrAlphanumericMode:
+		capacity = bits / 11 * 2
+		if bits%11 >= 6 {
+			capacity += 1
+		}
+	case versioner.QrByteMode:
+		capacity = bits / 8
+	}
+
+	if maxCount := 1<<countIndLength - 1; capacity > maxCount {
+		return maxCount, nil
+	}
+	return capacity, nil
+}
+
+// Gets the length of the count indicator of the mode in the symbols of the version
+func countIndicatorLength(version RmqrVersion, mode versioner.QrMode) (int, error) {
+	info, ok := rmqrVersionInfos[version]
+	if !ok {
+		return 0, fmt.Errorf("%w %d for rMQR", qrerr.ErrInvalidVersion, int(version))
+	}
+
+	index, ok := rmqrModeIndices[mode]
+	if !ok {
+		return 0, fmt.Errorf("%w %q", qrerr.ErrInvalidMode, mode)
+	}
+	return info.CountIndLengths[index], nil
+}
+
+func rmqrDataCodewords(version RmqrVersion, lvl versioner.QrEcLevel) int {
+	count := 0
+	for _, block := range rmqrVersionInfos[version].Blocks[lvl] {
+		count += block.Count * block.DataCodewords
+	}
+	return count
+}
+
+func (v RmqrVersion) Height() int {
+	return rmqrVersionInfos[v].Height
+}
+
+func (v RmqrVersion) Width() int {
+	return rmqrVersionInfos[v].Width
+}
+
+func (v RmqrVersion) String() string {
+	return fmt.Sprintf("R%dx%d", v.Height(), v.Width())
+}
+
+var rmqrModeIndices = map[versioner.QrMode]int{
+	versioner.QrNumericMode:      0,
+	versioner.QrAlphanumericMode: 1,
+	versioner.QrByteMode:         2,
+}
+
+var rmqrModeIndicators = map[versioner.QrMode]string{
+	versioner.QrNumericMode:      "001",
+	versioner.QrAlphanumericMode: "010",
+	versioner.QrByteMode:         "011",
+}
+
+// Count indicator lengths are listed in numeric, alphanumeric, byte order.
+var rmqrVersionInfos = map[RmqrVersion]rmqrVersionInfo{
+	0:  {7, 43, 0, []int{4, 3, 3}, map[versioner.QrEcLevel][]rmqrBlock{versioner.QrEcMedium: {{1, 13, 6}}, versioner.QrECHigh: {{1, 13, 3}}}},
+	1:  {7, 59, 3, []int{5, 5, 4}, map[versioner.QrEcLevel][]rmqrBlock{versioner.QrEcMedium: {{1, 21, 12}}, versioner.QrECHigh: {{1, 21, 7}}}},
+	2:  {7, 77, 5, []int{6, 5, 5}, map[versioner.QrEcLevel][]rmqrBlock{versioner.QrEcMedium: {{1, 32, 20}}, versioner.QrECHigh: {{1, 32, 10}}}},
+	3:  {7, 99, 6, []int{7, 6, 5}, map[versioner.QrEcLevel][]rmqrBlock{versioner.QrEcMedium: {{1, 44, 28}}, versioner.QrECHigh: {{1, 44, 14}}}},
+	4:  {7, 139, 1, []int{7, 6, 6}, map[versioner.QrEcLevel][]rmqrBlock{versioner.QrEcMedium: {{1, 68, 44}}, versioner.QrECHigh: {{2, 34, 12}}}},
+	5:  {9, 43, 2, []int{5, 5, 4}, map[versioner.QrEcLevel][]rmqrBlock{versioner.QrEcMedium: {{1, 21, 12}}, versioner.QrECHigh: {{1, 21, 7}}}},
+	6:  {9, 59, 3, []int{6, 5, 5}, map[versioner.QrEcLevel][]rmqrBlock{versioner.QrEcMedium: {{1, 33, 21}}, versioner.QrECHigh: {{1, 33, 11}}}},
+	7:  {9, 77, 1, []int{7, 6, 5}, map[versioner.QrEcLevel][]rmqrBlock{versioner.QrEcMedium: {{1, 49, 31}}, versioner.QrECHigh: {{1, 24, 8}, {1, 25, 9}}}},
+	8:  {9, 99, 4, []int{7, 6, 6}, map[versioner.QrEcLevel][]rmqrBlock{versioner.QrEcMedium: {{1, 66, 42}}, versioner.QrECHigh: {{2, 33, 11}}}},
+	9:  {9, 139, 5, []int{8, 7, 6}, map[versioner.QrEcLevel][]rmqrBlock{versioner.QrEcMedium: {{1, 49, 31}, {1, 50, 32}}, versioner.QrECHigh: {{3, 33, 11}}}},
+	10: {11, 27, 2, []int{4, 4, 3}, map[versioner.QrEcLevel][]rmqrBlock{versioner.QrEcMedium: {{1, 15, 7}}, versioner.QrECHigh: {{1, 15, 5}}}},
+	11: {11, 43, 1, []int{6, 5, 5}, map[versioner.QrEcLevel][]rmqrBlock{versioner.QrEcMedium: {{1, 31, 19}}, versioner.QrECHigh: {{1, 31, 11}}}},
+	12: {11, 59, 0, []int{7, 6, 5}, map[versioner.QrEcLevel][]rmqrBlock{versioner.QrEcMedium: {{1, 47, 31}}, versioner.QrECHigh: {{1, 23, 7}, {1, 24, 8}}}},
+	13: {11, 77, 2, []int{7, 6, 6}, map[versioner.QrEcLevel][]rmqrBlock{versioner.QrEcMedium: {{1, 67, 43}}, versioner.QrECHigh: {{1, 33, 11}, {1, 34, 12}}}},
+	14: {11, 99, 7, []int{8, 7, 6}, map[versioner.QrEcLevel][]rmqrBlock{versioner.QrEcMedium: {{1, 44, 28}, {1, 45, 29}}, versioner.QrECHigh: {{1, 44, 14}, {1, 45, 15}}}},
+	15: {11, 139, 6, []int{8, 7, 7}, map[versioner.QrEcLevel][]rmqrBlock{versioner.QrEcMedium: {{2, 66, 42}}, versioner.QrECHigh: {{3, 44, 14}}}},
+	16: {13, 27, 4, []int{5, 5, 4}, map[versioner.QrEcLevel][]rmqrBlock{versioner.QrEcMedium: {{1, 21, 12}}, versioner.QrECHigh: {{1, 21, 7}}}},
+	17: {13, 43, 1, []int{6, 6, 5}, map[versioner.QrEcLevel][]rmqrBlock{versioner.QrEcMedium: {{1, 41, 27}}, versioner.QrECHigh: {{1, 41, 13}}}},
+	18: {13, 59, 6, []int{7, 6, 6}, map[versioner.QrEcLevel][]rmqrBlock{versioner.QrEcMedium: {{1, 60, 38}}, versioner.QrECHigh: {{2, 30, 10}}}},
+	19: {13, 77, 4, []int{7, 7, 6}, map[versioner.QrEcLevel][]rmqrBlock{versioner.QrEcMedium: {{1, 42, 26}, {1, 43, 27}}, versioner.QrECHigh: {{1, 42, 14}, {1, 43, 15}}}},
+	20: {13, 99, 3, []int{8, 7, 7}, map[versioner.QrEcLevel][]rmqrBlock{versioner.QrEcMedium: {{1, 56, 36}, {1, 57, 37}}, versioner.QrECHigh: {{1, 37, 11}, {2, 38, 12}}}},
+	21: {13, 139, 0, []int{8, 8, 7}, map[versioner.QrEcLevel][]rmqrBlock{versioner.QrEcMedium: {{2, 55, 35}, {1, 56, 36}}, versioner.QrECHigh: {{2, 41, 13}, {2, 42, 14}}}},
+	22: {15, 43, 1, []int{7, 6, 6}, map[versioner.QrEcLevel][]rmqrBlock{versioner.QrEcMedium: {{1, 51, 33}}, versioner.QrECHigh: {{1, 25, 7}, {1, 26, 8}}}},
+	23: {15, 59, 4, []int{7, 7, 6}, map[versioner.QrEcLevel][]rmqrBlock{versioner.QrEcMedium: {{1, 74, 48}}, versioner.QrECHigh: {{2, 37, 13}}}},
+	24: {15, 77, 6, []int{8, 7, 7}, map[versioner.QrEcLevel][]rmqrBlock{versioner.QrEcMedium: {{1, 51, 33}, {1, 52, 34}}, versioner.QrECHigh: {{2, 34, 10}, {1, 35, 11}}}},
+	25: {15, 99, 7, []int{8, 7, 7}, map[versioner.QrEcLevel][]rmqrBlock{versioner.QrEcMedium: {{2, 68, 44}}, versioner.QrECHigh: {{4, 34, 12}}}},
+	26: {15, 139, 2, []int{9, 8, 7}, map[versioner.QrEcLevel][]rmqrBlock{versioner.QrEcMedium: {{2, 66, 42}, {1, 67, 43}}, versioner.QrECHigh: {{1, 39, 13}, {4, 40, 14}}}},
+	27: {17, 43, 1, []int{7, 6, 6}, map[versioner.QrEcLevel][]rmqrBlock{versioner.QrEcMedium: {{1, 61, 39}}, versioner.QrECHigh: {{1, 30, 10}, {1, 31, 11}}}},
+	28: {17, 59, 2, []int{8, 7, 6}, map[versioner.QrEcLevel][]rmqrBlock{versioner.QrEcMedium: {{2, 44, 28}}, versioner.QrECHigh: {{2, 44, 14}}}},
+	29: {17, 77, 0, []int{8, 7, 7}, map[versioner.QrEcLevel][]rmqrBlock{versioner.QrEcMedium: {{2, 61, 39}}, versioner.QrECHigh: {{1, 40, 12}, {2, 41, 13}}}},
+	30: {17, 99, 3, []int{8, 8, 7}, map[versioner.QrEcLevel][]rmqrBlock{versioner.QrEcMedium: {{2, 53, 33}, {1, 54, 34}}, versioner.QrECHigh: {{4, 40, 14}}}},
+	31: {17, 139, 4, []int{9, 8, 8}, map[versioner.QrEcLevel][]rmqrBlock{versioner.QrEcMedium: {{4, 58, 38}}, versioner.QrECHigh: {{2, 38, 12}, {4, 39, 13}}}},
+}
@@ -0,0 +1,238 @@
+package rmqr
+
+import (
+	"qr/qr-gen/qrerr"
+	"qr/qr-gen/util"
+	"qr/qr-gen/versioner"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRmqrVersionTables(t *testing.T) {
+	assert := assert.New(t)
+
+	for version, info := range rmqrVersionInfos {
+		m := &RmqrModuler{version: version, ecLevel: versioner.QrEcMedium}
+		m.prepareModuleMatrix()
+
+		dataModules := 0
+		for _, row := range m.moduleMatrix.GetMatrix() {
+			for _, module := range row {
+				if module == util.Module_EMPTY {
+					dataModules += 1
+				}
+			}
+		}
+
+		for lvl, blocks := range info.Blocks {
+			totalCodewords := 0
+			for _, block := range blocks {
+				totalCodewords += block.Count * block.TotalCodewords
+				assert.Equal(blocks[0].TotalCodewords-blocks[0].DataCodewords, block.TotalCodewords-block.DataCodewords,
+					"error correction codewords should be equal in every block of %s-%c", version, lvl)
+			}
+
+			assert.Equal(dataModules, totalCodewords*util.QrCodewordSize+info.RemainderBits,
+				"codewords should fill the data modules of %s-%c", version, lvl)
+		}
+	}
+}
+
+func TestRmqrCharacterCapacity(t *testing.T) {
+	assert := assert.New(t)
+
+	tests := []struct {
+		version  RmqrVersion
+		lvl      versioner.QrEcLevel
+		mode     versioner.QrMode
+		expected int
+	}{
+		{0, versioner.QrEcMedium, versioner.QrNumericMode, 12},
+		{0, versioner.QrEcMedium, versioner.QrByteMode, 5},
+		{0, versioner.QrECHigh, versioner.QrNumericMode, 5},
+		{4, versioner.QrEcMedium, versioner.QrAlphanumericMode, 62},
+		{7, versioner.QrEcMedium, versioner.QrNumericMode, 71},
+		{31, versioner.QrEcMedium, versioner.QrByteMode, 150},
+	}
+
+	for _, test := range tests {
+		actual, err := GetCharacterCapacity(test.version, test.lvl, test.mode)
+		assert.NoError(err)
+		assert.Equal(test.expected, actual, "capacity of %s-%c should match", test.version, test.lvl)
+	}
+
+	_, err := GetCharacterCapacity(99, versioner.QrEcMedium, versioner.QrNumericMode)
+	assert.ErrorIs(err, qrerr.ErrInvalidVersion, "unknown versions should be rejected")
+	_, err = GetCharacterCapacity(0, versioner.QrEcLow, versioner.QrNumericMode)
+	assert.ErrorIs(err, qrerr.ErrInvalidVersion, "unavailable levels should be rejected")
+}
+
+func TestRmqrUnknownVersion(t *testing.T) {
+	assert := assert.New(t)
+	e := NewEncoder()
+
+	_, err := e.GetCountIndicator("12", 99, versioner.QrNumericMode)
+	assert.ErrorIs(err, qrerr.ErrInvalidVersion)
+	_, err = e.GetCountIndicator("12", -1, versioner.QrNumericMode)
+	assert.ErrorIs(err, qrerr.ErrInvalidVersion)
+	_, err = e.Encode("12", 99)
+	assert.ErrorIs(err, qrerr.ErrInvalidVersion)
+	_, err = e.AugmentEncodedInput("0010", 99, versioner.QrEcMedium)
+	assert.ErrorIs(err, qrerr.ErrInvalidVersion)
+	_, err = e.GetFinalMessage("0010", 99, versioner.QrEcMedium)
+	assert.ErrorIs(err, qrerr.ErrInvalidVersion)
+}
+
+func TestRmqrVersion(t *testing.T) {
+	assert := assert.New(t)
+	e := NewEncoder()
+
+	version, err := e.GetVersion("123456", versioner.QrNumericMode, versioner.QrEcMedium)
+	assert.NoError(err)
+	assert.Equal("R11x27", version.String(), "smallest symbol should be selected")
+
+	version, err = e.GetVersionWithHeight("HELLO WORLD", versioner.QrAlphanumericMode, versioner.QrEcMedium, 7)
+	assert.NoError(err)
+	assert.Equal("R7x59", version.String(), "narrowest symbol of the given height should be selected")
+
+	_, err = e.GetVersion("123456", versioner.QrNumericMode, versioner.QrEcLow)
+	assert.ErrorIs(err, qrerr.ErrInvalidLevel, "low error correction is not available for rMQR")
+
+	_, err = e.GetVersionWithHeight("HELLO WORLD", versioner.QrAlphanumericMode, versioner.QrEcMedium, 8)
+	assert.ErrorIs(err, qrerr.ErrInvalidVersion, "there are no symbols of even height")
+
+	_, err = e.GetVersionWithHeight(strings.Repeat("A", 100), versioner.QrAlphanumericMode, versioner.QrEcMedium, 7)
+	assert.ErrorIs(err, qrerr.ErrCapacityExceeded, "input should not fit in the symbols of height 7")
+}
+
+func TestRmqrEncoding(t *testing.T) {
+	assert := assert.New(t)
+	e := NewEncoder()
+
+	encoded, err := e.Encode("123", 0)
+	assert.NoError(err)
+	assert.Equal("00100110001111011", encoded, "encoded input should match")
+
+	augmented, err := e.AugmentEncodedInput(encoded, 0, versioner.QrEcMedium)
+	assert.NoError(err)
+	assert.Equal("001001100011110110000000111011000001000111101100", augmented, "augmented input should match")
+
+	final, _ := e.GetFinalMessage(augmented, 0, versioner.QrEcMedium)
+	assert.Equal(13*util.QrCodewordSize, len(final), "final message should hold every codeword")
+	assert.Equal(augmented, final[:len(augmented)], "single block messages should not be interleaved")
+
+	_, err = e.AugmentEncodedInput(augmented+"0", 0, versioner.QrEcMedium)
+	assert.ErrorIs(err, qrerr.ErrCapacityExceeded, "encoded data exceeding the symbol should be rejected")
+
+	_, err = e.GetFinalMessage(encoded, 0, versioner.QrEcMedium)
+	assert.ErrorIs(err, qrerr.ErrInvalidLength, "encoded data should be augmented first")
+}
+
+func TestRmqrFormatInformation(t *testing.T) {
+	assert := assert.New(t)
+
+	for version := range rmqrVersionInfos {
+		for _, lvl := range []versioner.QrEcLevel{versioner.QrEcMedium, versioner.QrECHigh} {
+			m := &RmqrModuler{version: version, ecLevel: lvl}
+			format := m.computeFormatInformation()
+
+			assert.Equal(int(version), format>>12&0x1f, "version indicator should match")
+			assert.Equal(lvl == versioner.QrECHigh, format>>17 == 1, "error correction bit should match")
+
+			remainder := format
+			for i := formatInformationLength - 1; i >= 12; i-- {
+				if remainder&(1<<i) != 0 {
+					remainder ^= formatInformationGenerator << (i - 12)
+				}
+			}
+			assert.Zero(remainder, "format information should be a BCH codeword")
+		}
+	}
+}
+
+func TestRmqrModuleMatrix(t *testing.T) {
+	assert := assert.New(t)
+
+	for _, height := range []int{7, 9, 11, 13, 15, 17} {
+		matrix, err := GenerateWithHeight("HELLO WORLD", versioner.QrECHigh, height)
+		assert.NoError(err)
+		assert.Equal(height+2*DefaultQuietZone, len(matrix.GetMatrix()), "symbol heights should match")
+
+		counts := map[util.ModuleRole]int{}
+		util.ForEachModule(matrix.GetMatrix(), func(row, col int, dark bool, role util.ModuleRole) {
+			counts[role] += 1
+		})
+
+		assert.Equal(2*formatInformationLength, counts[util.ModuleRole_FORMAT], "format modules should match")
+		assert.Zero(counts[util.ModuleRole_EMPTY], "no module should stay empty")
+		assert.Zero(counts[util.ModuleRole_RESERVED], "no module should stay reserved")
+	}
+}
+
+func TestRmqrModulerErrors(t *testing.T) {
+	assert := assert.New(t)
+	e := NewEncoder()
+
+	encoded, _ := e.EncodeBits("HELLO", 0)
+	e.AugmentEncodedBits(encoded, 0, versioner.QrEcMedium)
+	data, _ := e.GetFinalMessageBits(encoded, 0, versioner.QrEcMedium)
+
+	_, err := NewModuler(0, versioner.QrEcMedium).CreateModuleMatrixFromBits(data)
+	assert.NoError(err)
+
+	_, err = NewModuler(40, versioner.QrEcMedium).CreateModuleMatrixFromBits(data)
+	assert.ErrorIs(err, qrerr.ErrInvalidVersion, "unknown versions should be rejected")
+	_, err = NewModuler(0, versioner.QrEcLow).CreateModuleMatrixFromBits(data)
+	assert.ErrorIs(err, qrerr.ErrInvalidLevel, "levels other than M and H should be rejected")
+
+	_, err = NewModuler(0, versioner.QrEcMedium).CreateModuleMatrix(data.String()[1:])
+	assert.ErrorIs(err, qrerr.ErrInvalidLength, "short data should be rejected")
+	_, err = NewModuler(0, versioner.QrEcMedium).CreateModuleMatrix(data.String() + "0")
+	assert.ErrorIs(err, qrerr.ErrInvalidLength, "long data should be rejected")
+}
+
+func TestRmqrQuietZone(t *testing.T) {
+	assert := assert.New(t)
+	expected, _ := Generate("HELLO WORLD", versioner.QrEcMedium)
+
+	for _, quietZone := range []int{0, 4} {
+		matrix, err := GenerateWithOptions("HELLO WORLD", versioner.QrEcMedium, Options{QuietZone: quietZone})
+		assert.NoError(err)
+		assert.Equal(expected.Width()-2*DefaultQuietZone+2*quietZone, matrix.Width(), "symbol widths should match")
+		assert.Equal(expected.Height()-2*DefaultQuietZone+2*quietZone, matrix.Height(), "symbol heights should match")
+	}
+
+	_, err := GenerateWithOptions("HELLO WORLD", versioner.QrEcMedium, Options{QuietZone: -1})
+	assert.ErrorIs(err, qrerr.ErrInvalidInput, "negative quiet zones should be rejected")
+}
+
+func TestRmqrConcurrentGeneration(t *testing.T) {
+	assert := assert.New(t)
+	inputs := []string{"1", "HELLO WORLD", "https://example.com/", strings.Repeat("rmqr", 20)}
+
+	expected := make([][][]util.Module, len(inputs))
+	for i, input := range inputs {
+		matrix, err := Generate(input, versioner.QrEcMedium)
+		assert.NoError(err)
+		expected[i] = matrix.GetMatrix()
+	}
+
+	actual := make([][][]util.Module, 500)
+	var wg sync.WaitGroup
+	for n := range actual {
+		wg.Add(1)
+		go func(n int) {
+			defer wg.Done()
+			matrix, _ := Generate(inputs[n%len(inputs)], versioner.QrEcMedium)
+			actual[n] = matrix.GetMatrix()
+		}(n)
+	}
+	wg.Wait()
+
+	for n := range actual {
+		assert.Equal(expected[n%len(inputs)], actual[n], "concurrent and serial symbols should match")
+	}
+}
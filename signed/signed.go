@@ -0,0 +1,242 @@
+// Package signed wraps payloads in envelopes signed with Ed25519 or ECDSA P-256, so that
+// readers can tell whether the content of a symbol, such as a ticket, was issued by the
+// holder of the key and left untouched. The payload is stored in clear after the header of
+// the envelope package, whose single field is the algorithm, and followed by a signature of
+// 64 bytes:
+//
+//	header | payload | signature
+//
+// The signature covers the header and the payload, so that the algorithm and the key ID
+// cannot be swapped. ECDSA signatures are stored as the fixed-size r and s rather than in
+// ASN.1, with s in the lower half of the group order: both (r, s) and (r, n-s) verify the
+// same payload, so only the low one is accepted to give every envelope a single encoding.
+package signed
+
+import (
+	"bytes"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/sha256"
+	"fmt"
+	"math/big"
+	"qr/qr-gen/envelope"
+	"qr/qr-gen/qrerr"
+)
+
+// Algorithm is the signature algorithm of an envelope
+type Algorithm byte
+
+const (
+	Algorithm_ED25519 Algorithm = iota + 1
+	// Algorithm_ECDSA_P256 signs the SHA-256 digest of the envelope
+	Algorithm_ECDSA_P256
+)
+
+var algorithmNames = map[Algorithm]string{
+	Algorithm_ED25519:    "Ed25519",
+	Algorithm_ECDSA_P256: "ECDSA P-256",
+}
+
+func (a Algorithm) String() string {
+	if name, ok := algorithmNames[a]; ok {
+		return name
+	}
+	return "unknown"
+}
+
+// Version of the envelope format
+const envelopeVersion byte = 1
+
+// SignatureSize is the size of the signatures of both algorithms
+const SignatureSize = 64
+
+// Size of a coordinate of P-256
+const p256Size = 32
+
+// Signatures whose s is above half of the order of P-256 are rejected
+var p256HalfOrder = new(big.Int).Rsh(elliptic.P256().Params().N, 1)
+
+// TextPrefix precedes the Base45 encoding of the text envelopes, in alphanumeric characters
+const TextPrefix = "SIG1:"
+
+// Signer wraps payloads in envelopes signed with its key
+type Signer struct {
+	keyID     string
+	algorithm Algorithm
+	sign      func(message []byte) ([]byte, error)
+}
+
+// NewEd25519Signer returns a signer using the Ed25519 key, identified by keyID in the
+// envelopes
+func NewEd25519Signer(keyID string, key ed25519.PrivateKey) (*Signer, error) {
+	if err := envelope.ValidateKeyID(keyID); err != nil {
+		return nil, err
+	}
+	if len(key) != ed25519.PrivateKeySize {
+		return nil, fmt.Errorf("%w: invalid Ed25519 private key length %d", qrerr.ErrInvalidInput, len(key))
+	}
+
+	return &Signer{keyID: keyID, algorithm: Algorithm_ED25519, sign: func(message []byte) ([]byte, error) {
+		return ed25519.Sign(key, message), nil
+	}}, nil
+}
+
+// NewECDSASigner returns a signer using the ECDSA key, which must be on the P-256 curve,
+// identified by keyID in the envelopes
+func NewECDSASigner(keyID string, key *ecdsa.PrivateKey) (*Signer, error) {
+	if err := envelope.ValidateKeyID(keyID); err != nil {
+		return nil, err
+	}
+	if key == nil || key.Curve != elliptic.P256() {
+		return nil, fmt.Errorf("%w: ECDSA keys must be on the P-256 curve", qrerr.ErrInvalidInput)
+	}
+
+	return &Signer{keyID: keyID, algorithm: Algorithm_ECDSA_P256, sign: func(message []byte) ([]byte, error) {
+		digest := sha256.Sum256(message)
+		r, s, err := ecdsa.Sign(rand.Reader, key, digest[:])
+		if err != nil {
+			return nil, fmt.Errorf("Error on signing the payload: %w", err)
+		}
+		if s.Cmp(p256HalfOrder) > 0 {
+			s.Sub(key.Curve.Params().N, s)
+		}
+
+		signature := make([]byte, SignatureSize)
+		r.FillBytes(signature[:p256Size])
+		s.FillBytes(signature[p256Size:])
+		return signature, nil
+	}}, nil
+}
+
+func (s *Signer) KeyID() string {
+	return s.keyID
+}
+
+func (s *Signer) Algorithm() Algorithm {
+	return s.algorithm
+}
+
+// Sign returns the envelope of the payload, to be encoded in byte mode
+func (s *Signer) Sign(data []byte) ([]byte, error) {
+	if len(data) == 0 {
+		return nil, qrerr.ErrEmptyInput
+	}
+
+	header := s.header()
+	signed := make([]byte, 0, header.Len()+len(data)+SignatureSize)
+	signed = header.Append(signed)
+	signed = append(signed, data...)
+
+	signature, err := s.sign(signed)
+	if err != nil {
+		return nil, err
+	}
+	return append(signed, signature...), nil
+}
+
+// SignText returns the envelope of the payload Base45 encoded after TextPrefix, to be
+// encoded in alphanumeric mode
+func (s *Signer) SignText(data []byte) (string, error) {
+	signed, err := s.Sign(data)
+	if err != nil {
+		return "", err
+	}
+	return envelope.EncodeText(TextPrefix, signed), nil
+}
+
+func (s *Signer) header() *envelope.Header {
+	return &envelope.Header{Version: envelopeVersion, Fields: []byte{byte(s.algorithm)}, KeyID: s.keyID}
+}
+
+// Verified is the payload of an envelope whose signature was verified
+type Verified struct {
+	Data      []byte
+	KeyID     string
+	Algorithm Algorithm
+}
+
+// Verifier checks the envelopes against the public keys of their key IDs. Keys may be added
+// and removed while envelopes are verified by concurrent goroutines.
+type Verifier struct {
+	keys *envelope.Keyring[verificationKey]
+}
+
+type verificationKey struct {
+	algorithm Algorithm
+	verify    func(message, signature []byte) bool
+}
+
+func NewVerifier() *Verifier {
+	return &Verifier{keys: envelope.NewKeyring[verificationKey]()}
+}
+
+// AddEd25519Key registers the Ed25519 public key of the key ID
+func (v *Verifier) AddEd25519Key(keyID string, key ed25519.PublicKey) error {
+	if len(key) != ed25519.PublicKeySize {
+		return fmt.Errorf("%w: invalid Ed25519 public key length %d", qrerr.ErrInvalidInput, len(key))
+	}
+
+	return v.keys.Add(keyID, verificationKey{algorithm: Algorithm_ED25519, verify: func(message, signature []byte) bool {
+		return ed25519.Verify(key, message, signature)
+	}})
+}
+
+// AddECDSAKey registers the ECDSA P-256 public key of the key ID
+func (v *Verifier) AddECDSAKey(keyID string, key *ecdsa.PublicKey) error {
+	if key == nil || key.Curve != elliptic.P256() {
+		return fmt.Errorf("%w: ECDSA keys must be on the P-256 curve", qrerr.ErrInvalidInput)
+	}
+
+	return v.keys.Add(keyID, verificationKey{algorithm: Algorithm_ECDSA_P256, verify: func(message, signature []byte) bool {
+		digest := sha256.Sum256(message)
+		r := new(big.Int).SetBytes(signature[:p256Size])
+		s := new(big.Int).SetBytes(signature[p256Size:])
+		return s.Cmp(p256HalfOrder) <= 0 && ecdsa.Verify(key, digest[:], r, s)
+	}})
+}
+
+// RemoveKey retires the key of the key ID, whose envelopes are then rejected
+func (v *Verifier) RemoveKey(keyID string) {
+	v.keys.Remove(keyID)
+}
+
+// Verify checks the signature of the envelope, such as the data of a decoded symbol, and
+// returns a copy of its payload. Envelopes of unknown keys, or whose algorithm is not the
+// one of the key, are rejected.
+func (v *Verifier) Verify(signed []byte) (*Verified, error) {
+	header, err := envelope.ParseHeader(signed, envelopeVersion, 1)
+	if err != nil {
+		return nil, err
+	}
+	// Sign refuses empty payloads, so that an envelope holds at least one byte of payload
+	if len(signed) <= header.Len()+SignatureSize {
+		return nil, fmt.Errorf("%w: envelope too short", qrerr.ErrInvalidInput)
+	}
+
+	algorithm := Algorithm(header.Fields[0])
+	key, err := v.keys.Get(header.KeyID)
+	if err != nil {
+		return nil, err
+	}
+	if key.algorithm != algorithm {
+		return nil, fmt.Errorf("%w: key %q does not use %s", qrerr.ErrInvalidSignature, header.KeyID, algorithm)
+	}
+
+	message, signature := signed[:len(signed)-SignatureSize], signed[len(signed)-SignatureSize:]
+	if !key.verify(message, signature) {
+		return nil, qrerr.ErrInvalidSignature
+	}
+
+	return &Verified{Data: bytes.Clone(message[header.Len():]), KeyID: header.KeyID, Algorithm: algorithm}, nil
+}
+
+// VerifyText checks an envelope returned by SignText
+func (v *Verifier) VerifyText(text string) (*Verified, error) {
+	signed, err := envelope.DecodeText(TextPrefix, text)
+	if err != nil {
+		return nil, err
+	}
+	return v.Verify(signed)
+}
@@ -0,0 +1,185 @@
+package signed
+
+import (
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rand"
+	"math/big"
+	"qr/qr-gen/qrerr"
+	"qr/qr-gen/versioner"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+var ticket = []byte(`{"event":"QR Conf","seat":"B12"}`)
+
+func newSigners(t testing.TB) ([]*Signer, *Verifier) {
+	edPublic, edPrivate, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ecPrivate, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	edSigner, err := NewEd25519Signer("ed-2024", edPrivate)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ecSigner, err := NewECDSASigner("ec-2025", ecPrivate)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	verifier := NewVerifier()
+	if err := verifier.AddEd25519Key("ed-2024", edPublic); err != nil {
+		t.Fatal(err)
+	}
+	if err := verifier.AddECDSAKey("ec-2025", &ecPrivate.PublicKey); err != nil {
+		t.Fatal(err)
+	}
+
+	return []*Signer{edSigner, ecSigner}, verifier
+}
+
+func TestSignAndVerify(t *testing.T) {
+	assert := assert.New(t)
+	signers, verifier := newSigners(t)
+
+	for _, signer := range signers {
+		envelope, err := signer.Sign(ticket)
+		assert.NoError(err)
+		assert.Len(envelope, 3+len(signer.KeyID())+len(ticket)+SignatureSize, "envelope lengths should match")
+
+		verified, err := verifier.Verify(envelope)
+		if assert.NoError(err) {
+			assert.Equal(Verified{Data: ticket, KeyID: signer.KeyID(), Algorithm: signer.Algorithm()}, *verified, "verified payloads should match")
+		}
+
+		text, err := signer.SignText(ticket)
+		assert.NoError(err)
+		mode, err := versioner.New().GetMode(text)
+		assert.NoError(err)
+		assert.Equal(versioner.QrAlphanumericMode, mode, "text envelopes should be alphanumeric")
+
+		verified, err = verifier.VerifyText(text)
+		if assert.NoError(err) {
+			assert.Equal(ticket, verified.Data, "verified payloads should match")
+		}
+	}
+}
+
+func TestVerifyErrors(t *testing.T) {
+	assert := assert.New(t)
+	signers, verifier := newSigners(t)
+
+	for _, signer := range signers {
+		envelope, _ := signer.Sign(ticket)
+
+		// The algorithm, the key ID, the payload and the signature are all covered
+		for _, i := range []int{1, 3, 3 + len(signer.KeyID()), len(envelope) - 1} {
+			tampered := append([]byte(nil), envelope...)
+			tampered[i] ^= 0x01
+			_, err := verifier.Verify(tampered)
+			assert.Error(err, "tampering byte %d should be detected", i)
+		}
+
+		_, err := verifier.Verify(envelope[:len(envelope)-1])
+		assert.ErrorIs(err, qrerr.ErrInvalidSignature)
+
+		_, err = verifier.Verify(envelope[:3+len(signer.KeyID())+SignatureSize-1])
+		assert.ErrorIs(err, qrerr.ErrInvalidInput, "envelopes shorter than a signature should be rejected")
+
+		// Envelopes without payload are rejected, even with a valid signature
+		empty := signer.header().Append(nil)
+		signature, err := signer.sign(empty)
+		assert.NoError(err)
+		_, err = verifier.Verify(append(empty, signature...))
+		assert.ErrorIs(err, qrerr.ErrInvalidInput, "envelopes without payload should be rejected")
+	}
+
+	// The retired keys are rejected
+	envelope, _ := signers[0].Sign(ticket)
+	verifier.RemoveKey(signers[0].KeyID())
+	_, err := verifier.Verify(envelope)
+	assert.ErrorIs(err, qrerr.ErrUnknownKey)
+
+	// The algorithm must be the one of the key
+	envelope, _ = signers[1].Sign(ticket)
+	envelope[1] = byte(Algorithm_ED25519)
+	_, err = verifier.Verify(envelope)
+	assert.ErrorIs(err, qrerr.ErrInvalidSignature)
+
+	_, err = signers[0].Sign(nil)
+	assert.ErrorIs(err, qrerr.ErrEmptyInput)
+}
+
+// Only the ECDSA signatures whose s is in the lower half of the order are produced and
+// accepted, their high twins verifying the same payload
+func TestECDSALowS(t *testing.T) {
+	assert := assert.New(t)
+	signers, verifier := newSigners(t)
+	n := elliptic.P256().Params().N
+
+	for i := 0; i < 32; i++ {
+		envelope, err := signers[1].Sign(ticket)
+		assert.NoError(err)
+
+		signature := envelope[len(envelope)-SignatureSize:]
+		s := new(big.Int).SetBytes(signature[p256Size:])
+		assert.True(s.Cmp(p256HalfOrder) <= 0, "signatures should have a low s")
+
+		new(big.Int).Sub(n, s).FillBytes(signature[p256Size:])
+		_, err = verifier.Verify(envelope)
+		assert.ErrorIs(err, qrerr.ErrInvalidSignature, "signatures with a high s should be rejected")
+	}
+}
+
+func TestVerifiedDataCopy(t *testing.T) {
+	assert := assert.New(t)
+	signers, verifier := newSigners(t)
+
+	envelope, _ := signers[0].Sign(ticket)
+	verified, err := verifier.Verify(envelope)
+	assert.NoError(err)
+
+	for i := range envelope {
+		envelope[i] = 0
+	}
+	assert.Equal(ticket, verified.Data, "verified payloads should not share the envelope")
+}
+
+func TestNewSignerErrors(t *testing.T) {
+	assert := assert.New(t)
+
+	_, edPrivate, _ := ed25519.GenerateKey(rand.Reader)
+	_, err := NewEd25519Signer("", edPrivate)
+	assert.ErrorIs(err, qrerr.ErrInvalidInput)
+	_, err = NewEd25519Signer("k", edPrivate[:10])
+	assert.ErrorIs(err, qrerr.ErrInvalidInput)
+
+	p384, _ := ecdsa.GenerateKey(elliptic.P384(), rand.Reader)
+	_, err = NewECDSASigner("k", p384)
+	assert.ErrorIs(err, qrerr.ErrInvalidInput)
+	assert.ErrorIs(NewVerifier().AddECDSAKey("k", &p384.PublicKey), qrerr.ErrInvalidInput)
+	assert.ErrorIs(NewVerifier().AddEd25519Key("k", nil), qrerr.ErrInvalidInput)
+	assert.ErrorIs(NewVerifier().AddEd25519Key("", make(ed25519.PublicKey, ed25519.PublicKeySize)), qrerr.ErrInvalidInput)
+}
+
+func FuzzVerify(f *testing.F) {
+	signers, verifier := newSigners(f)
+	for _, signer := range signers {
+		envelope, _ := signer.Sign(ticket)
+		f.Add(envelope)
+	}
+
+	f.Fuzz(func(t *testing.T, envelope []byte) {
+		// Never panics, and only accepts envelopes of the registered keys
+		if verified, err := verifier.Verify(envelope); err == nil && verified.KeyID != "ed-2024" && verified.KeyID != "ec-2025" {
+			t.Fatalf("unexpected key %q", verified.KeyID)
+		}
+	})
+}
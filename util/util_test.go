@@ -1,6 +1,7 @@
 package util
 
 import (
+	"strings"
 	"testing"
 
 	"github.com/stretchr/testify/assert"
@@ -90,6 +91,7 @@ func TestSplitInGroups(t *testing.T) {
 		{"abcd", 2, []string{"ab", "cd"}},
 		{"", 3, nil},
 		{"abc", 1, []string{"a", "b", "c"}},
+		{"ab", 3, []string{"ab"}},
 	}
 
 	for _, test := range tests {
@@ -135,3 +137,67 @@ func TestConvertIntListToCodewords(t *testing.T) {
 		assert.Equal(test.expected, actual, "string of codewords should match")
 	}
 }
+
+func TestGetModuleRole(t *testing.T) {
+	assert := assert.New(t)
+
+	tests := []struct {
+		input    Module
+		expected ModuleRole
+	}{
+		{Module_LIGHTEN, ModuleRole_DATA},
+		{Module_DARKEN, ModuleRole_DATA},
+		{Module_FINDER_DARKEN, ModuleRole_FINDER},
+		{Module_SEPARATOR, ModuleRole_SEPARATOR},
+		{Module_ALIGNMENT_LIGHTEN, ModuleRole_ALIGNMENT},
+		{Module_TIMING_DARKEN, ModuleRole_TIMING},
+		{Module_DARK, ModuleRole_DARK_MODULE},
+		{Module_FORMAT_LIGHTEN, ModuleRole_FORMAT},
+		{Module_VERSION_DARKEN, ModuleRole_VERSION},
+		{Module_QUIET_ZONE, ModuleRole_QUIET_ZONE},
+		{Module_RESERVED, ModuleRole_RESERVED},
+		{Module_EMPTY, ModuleRole_EMPTY},
+	}
+
+	for _, test := range tests {
+		actual := GetModuleRole(test.input)
+		assert.Equal(test.expected, actual, "Module roles should match")
+	}
+}
+
+func TestForEachModule(t *testing.T) {
+	assert := assert.New(t)
+
+	modules := [][]Module{
+		{Module_QUIET_ZONE, Module_FORMAT_DARKEN},
+		{Module_DARKEN, Module_TIMING_LIGHTEN},
+	}
+
+	var dark []bool
+	var roles []ModuleRole
+	ForEachModule(modules, func(row, col int, d bool, role ModuleRole) {
+		dark = append(dark, d)
+		roles = append(roles, role)
+	})
+
+	assert.Equal([]bool{false, true, true, false}, dark, "Module colors should match")
+	assert.Equal([]ModuleRole{ModuleRole_QUIET_ZONE, ModuleRole_FORMAT, ModuleRole_DATA, ModuleRole_TIMING}, roles, "Module roles should match")
+}
+
+func FuzzSplitInGroups(f *testing.F) {
+	f.Add("abcdefgh", 3)
+	f.Add("", 1)
+
+	f.Fuzz(func(t *testing.T, s string, n int) {
+		groups := SplitInGroups(s, n)
+		if strings.Join(groups, "") != s {
+			t.Fatalf("groups %q do not join back to %q", groups, s)
+		}
+
+		for i, group := range groups {
+			if group == "" || (n > 0 && len(group) > n) || (n > 0 && i < len(groups)-1 && len(group) != n) {
+				t.Fatalf("invalid group %q of %q split by %d", group, s, n)
+			}
+		}
+	})
+}
@@ -0,0 +1,178 @@
+package versioner
+
+import (
+	"fmt"
+	"io"
+	"qr/qr-gen/qrerr"
+	"qr/qr-gen/util"
+	"strings"
+	"text/tabwriter"
+	"unicode/utf8"
+)
+
+// Segment is a part of the input encoded in a single mode, preceded by its own mode and
+// count indicators
+type Segment struct {
+	Data string
+	Mode QrMode
+}
+
+// Capacity reports how an input, split in segments, fits in the symbols of every version
+// and error correction level
+type Capacity struct {
+	Segments []Segment
+}
+
+// QrEcLevels lists the error correction levels, from the lowest recovery capacity
+var QrEcLevels = []QrEcLevel{QrEcLow, QrEcMedium, QrEcQuartile, QrECHigh}
+
+// QrModes lists the modes, from the most compact one
+var QrModes = []QrMode{QrNumericMode, QrAlphanumericMode, QrByteMode}
+
+const (
+	MinQrVersion QrVersion = 1
+	MaxQrVersion QrVersion = 40
+)
+
+// Size returns the number of modules on each side of the symbol, quiet zone excluded
+func (v QrVersion) Size() int {
+	return (int(v)-1)*4 + 21
+}
+
+// GetVersionForSize returns the version of the symbols of the given size, quiet zone excluded
+func GetVersionForSize(size int) (QrVersion, error) {
+	version := QrVersion((size-21)/4 + 1)
+	if size < MinQrVersion.Size() || size > MaxQrVersion.Size() || version.Size() != size {
+		return QrVersion(-1), fmt.Errorf("%w: no symbol of size %d", qrerr.ErrInvalidVersion, size)
+	}
+	return version, nil
+}
+
+// GetCapacity reports the capacity of the input encoded as a single segment, in the most
+// compact mode able to hold it
+func (v *QrVersioner) GetCapacity(s string) (*Capacity, error) {
+	mode, err := v.GetMode(s)
+	if err != nil {
+		return nil, err
+	}
+	return v.GetSegmentsCapacity([]Segment{{Data: s, Mode: mode}})
+}
+
+// GetSegmentsCapacity reports the capacity of the input split in the given segments.
+// Every segment must only hold characters of its mode.
+func (v *QrVersioner) GetSegmentsCapacity(segments []Segment) (*Capacity, error) {
+	for _, segment := range segments {
+		if _, ok := qrModeIndices[segment.Mode]; !ok {
+			return nil, fmt.Errorf("%w %q", qrerr.ErrInvalidMode, segment.Mode)
+		}
+
+		for pos, r := range segment.Data {
+			if !isModeCharacter(r, segment.Mode) {
+				return nil, &qrerr.ErrInvalidCharacter{Pos: pos, Rune: r, Mode: string(segment.Mode)}
+			}
+		}
+	}
+
+	return &Capacity{Segments: segments}, nil
+}
+
+// BitLength returns the number of bits of the encoded segments, which depends on the count
+// indicator lengths of the version
+func (c *Capacity) BitLength(version QrVersion) int {
+	v := New()
+	bits := 0
+
+	for _, segment := range c.Segments {
+		bits += len(qrModeIndicators[segment.Mode]) + v.GetCountIndicatorLength(version, segment.Mode) + v.GetDataLength(segment.Data, segment.Mode)
+	}
+
+	return bits
+}
+
+// DataBits returns the number of data bits of the symbol
+func (c *Capacity) DataBits(version QrVersion, lvl QrEcLevel) int {
+	return util.QrCodewordSize * util.QrEcInfo[util.GetECMappingKey(int(version), string(lvl))].TotalDataCodewords
+}
+
+// RemainingBits returns the number of data bits left after the segments, negative when
+// they do not fit the symbol
+func (c *Capacity) RemainingBits(version QrVersion, lvl QrEcLevel) int {
+	return c.DataBits(version, lvl) - c.BitLength(version)
+}
+
+// Fits returns whether the segments fit the symbol
+func (c *Capacity) Fits(version QrVersion, lvl QrEcLevel) bool {
+	return c.RemainingBits(version, lvl) >= 0
+}
+
+// MinVersion returns the smallest version holding the segments at the level, or -1
+func (c *Capacity) MinVersion(lvl QrEcLevel) QrVersion {
+	for version := MinQrVersion; version <= MaxQrVersion; version++ {
+		if c.Fits(version, lvl) {
+			return version
+		}
+	}
+	return QrVersion(-1)
+}
+
+// RemainingCharacters returns the number of characters of the mode which would still fit
+// the symbol, in a segment appended after the others
+func (c *Capacity) RemainingCharacters(version QrVersion, lvl QrEcLevel, mode QrMode) int {
+	countIndLength := New().GetCountIndicatorLength(version, mode)
+	bits := c.RemainingBits(version, lvl) - len(qrModeIndicators[mode]) - countIndLength
+	if bits < 0 {
+		return 0
+	}
+
+	characters := 0
+	switch mode {
+	case QrNumericMode:
+		characters = bits / 10 * 3
+		if rest := bits % 10; rest >= 7 {
+			characters += 2
+		} else if rest >= 4 {
+			characters += 1
+		}
+	case QrAlphanumericMode:
+		characters = bits / 11 * 2
+		if bits%11 >= 6 {
+			characters += 1
+		}
+	case QrByteMode:
+		characters = bits / util.QrCodewordSize
+	}
+
+	return util.Min(characters, 1<<countIndLength-1)
+}
+
+// WriteTable writes the capacity of every version and level as a table: the data bits of
+// the symbol, the bits left after the segments and the characters left in every mode
+func (c *Capacity) WriteTable(w io.Writer) error {
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', tabwriter.AlignRight)
+	fmt.Fprintln(tw, "version\tsize\tlevel\tdata bits\tused bits\tremaining bits\tnumeric\talphanumeric\tbyte\t")
+
+	for version := MinQrVersion; version <= MaxQrVersion; version++ {
+		for _, lvl := range QrEcLevels {
+			fmt.Fprintf(tw, "%d\t%dx%d\t%c\t%d\t%d\t%d", version, version.Size(), version.Size(), lvl,
+				c.DataBits(version, lvl), c.BitLength(version), c.RemainingBits(version, lvl))
+			for _, mode := range QrModes {
+				fmt.Fprintf(tw, "\t%d", c.RemainingCharacters(version, lvl, mode))
+			}
+			fmt.Fprintln(tw, "\t")
+		}
+	}
+
+	return tw.Flush()
+}
+
+func isModeCharacter(r rune, mode QrMode) bool {
+	switch mode {
+	case QrNumericMode:
+		return r >= '0' && r <= '9'
+	case QrAlphanumericMode:
+		return strings.ContainsRune(util.QrAlphanumericCharacters, r)
+	default:
+		// Byte mode holds the UTF-8 bytes of any character
+		return r != utf8.RuneError
+	}
+}
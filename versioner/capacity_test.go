@@ -0,0 +1,92 @@
+package versioner
+
+import (
+	"bytes"
+	"qr/qr-gen/qrerr"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestEmptyCapacity(t *testing.T) {
+	assert := assert.New(t)
+	c, err := New().GetSegmentsCapacity(nil)
+	assert.NoError(err)
+
+	// Without segments, the remaining characters are the capacities of the symbols
+	for version := MinQrVersion; version <= MaxQrVersion; version++ {
+		for _, lvl := range QrEcLevels {
+			for _, mode := range QrModes {
+				assert.Equal(qrCapacities[version][lvl][qrModeIndices[mode]], c.RemainingCharacters(version, lvl, mode),
+					"capacities of %d-%c in %s mode should match", version, lvl, mode)
+			}
+		}
+	}
+}
+
+func TestCapacity(t *testing.T) {
+	assert := assert.New(t)
+	v := New()
+
+	c, err := v.GetCapacity("HELLO WORLD")
+	assert.NoError(err)
+	assert.Equal(4+9+61, c.BitLength(1), "bit lengths should match")
+	assert.Equal(4+11+61, c.BitLength(10), "bit lengths should match")
+	assert.Equal(QrVersion(1), c.MinVersion(QrEcQuartile), "minimum versions should match")
+	assert.Equal(QrVersion(2), c.MinVersion(QrECHigh), "minimum versions should match")
+	assert.Equal(104-74, c.RemainingBits(1, QrEcQuartile), "remaining bits should match")
+	assert.Equal(3, c.RemainingCharacters(1, QrEcQuartile, QrAlphanumericMode), "remaining characters should match")
+	assert.Equal(0, c.RemainingCharacters(1, QrECHigh, QrByteMode), "no character should fit a full symbol")
+
+	c, err = v.GetCapacity(strings.Repeat("a", 2954))
+	assert.NoError(err)
+	assert.Equal(QrVersion(-1), c.MinVersion(QrEcLow), "input should not fit any version")
+	assert.Less(c.RemainingBits(40, QrEcLow), 0, "remaining bits should be negative")
+}
+
+func TestSegmentsCapacity(t *testing.T) {
+	assert := assert.New(t)
+	v := New()
+
+	segments := []Segment{{Data: "0123456789012", Mode: QrNumericMode}, {Data: "ABC", Mode: QrAlphanumericMode}}
+	c, err := v.GetSegmentsCapacity(segments)
+	assert.NoError(err)
+	assert.Equal((4+10+44)+(4+9+17), c.BitLength(1), "bit lengths should match")
+	assert.True(c.Fits(1, QrEcMedium), "segments should fit the symbol")
+
+	_, err = v.GetSegmentsCapacity([]Segment{{Data: "12a", Mode: QrNumericMode}})
+	var invalid *qrerr.ErrInvalidCharacter
+	if assert.ErrorAs(err, &invalid) {
+		assert.Equal(qrerr.ErrInvalidCharacter{Pos: 2, Rune: 'a', Mode: "numeric"}, *invalid, "error details should match")
+	}
+
+	_, err = v.GetSegmentsCapacity([]Segment{{Data: "12", Mode: "kanji"}})
+	assert.ErrorIs(err, qrerr.ErrInvalidMode)
+}
+
+func TestGetVersionForSize(t *testing.T) {
+	assert := assert.New(t)
+
+	version, err := GetVersionForSize(25)
+	assert.NoError(err)
+	assert.Equal(QrVersion(2), version, "versions should match")
+	assert.Equal(177, MaxQrVersion.Size(), "sizes should match")
+
+	for _, size := range []int{17, 26, 181} {
+		_, err = GetVersionForSize(size)
+		assert.ErrorIs(err, qrerr.ErrInvalidVersion, "size %d should not match any version", size)
+	}
+}
+
+func TestWriteTable(t *testing.T) {
+	assert := assert.New(t)
+	c, _ := New().GetCapacity("HELLO WORLD")
+
+	var b bytes.Buffer
+	assert.NoError(c.WriteTable(&b))
+
+	lines := strings.Split(strings.TrimSuffix(b.String(), "\n"), "\n")
+	assert.Len(lines, 1+40*4, "every version and level should have a line")
+	assert.Equal([]string{"1", "21x21", "Q", "104", "74", "30", "4", "3", "2"}, strings.Fields(lines[3]), "table cells should match")
+}
@@ -2,9 +2,12 @@ package versioner
 
 import (
 	"fmt"
+	"qr/qr-gen/qrerr"
 	"qr/qr-gen/util"
 	"regexp"
 	"strconv"
+	"unicode"
+	"unicode/utf8"
 )
 
 type QrVersion int
@@ -17,6 +20,13 @@ type Versioner interface {
 	GetVersion(s string, mode QrMode, lvl QrEcLevel) (QrVersion, error)
 	GetModeIndicator(mode QrMode) string
 	GetCountIndicator(s string, version QrVersion, mode QrMode) (string, error)
+	GetCountIndicatorLength(version QrVersion, mode QrMode) int
+	GetDataLength(s string, mode QrMode) int
+	GetByteModeVersion(s string, lvl QrEcLevel, eci bool) (QrVersion, error)
+	GetByteLengthVersion(length int, lvl QrEcLevel, eci bool) (QrVersion, error)
+	GetEciHeader(assignment int) string
+	GetCapacity(s string) (*Capacity, error)
+	GetSegmentsCapacity(segments []Segment) (*Capacity, error)
 }
 
 type QrVersioner struct{}
@@ -25,7 +35,13 @@ func New() Versioner {
 	return &QrVersioner{}
 }
 
+// GetMode returns the most compact mode able to encode every character of the input.
+// Characters out of the ISO 8859-1 range are reported as invalid in byte mode.
 func (v *QrVersioner) GetMode(s string) (QrMode, error) {
+	if len(s) == 0 {
+		return QrMode(""), qrerr.ErrEmptyInput
+	}
+
 	if matched, _ := regexp.MatchString(qrModeRegexes[QrNumericMode], s); matched {
 		return QrNumericMode, nil
 	}
@@ -38,10 +54,19 @@ func (v *QrVersioner) GetMode(s string) (QrMode, error) {
 		return QrByteMode, nil
 	}
 
-	return QrMode(""), fmt.Errorf("Invalid input pattern")
+	for pos, r := range s {
+		if r > unicode.MaxLatin1 || r == utf8.RuneError {
+			return QrMode(""), &qrerr.ErrInvalidCharacter{Pos: pos, Rune: r, Mode: string(QrByteMode)}
+		}
+	}
+	return QrMode(""), qrerr.ErrInvalidInput
 }
 
 func (v *QrVersioner) GetVersion(s string, mode QrMode, lvl QrEcLevel) (QrVersion, error) {
+	if err := validateModeAndLevel(mode, lvl); err != nil {
+		return QrVersion(-1), err
+	}
+
 	version := 1
 
 	for version <= len(qrCapacities) {
@@ -51,16 +76,111 @@ func (v *QrVersioner) GetVersion(s string, mode QrMode, lvl QrEcLevel) (QrVersio
 		version += 1
 	}
 
-	return QrVersion(-1), fmt.Errorf("Cannot compute QR version")
+	return QrVersion(-1), v.dataTooLong(len(qrModeIndicators[mode])+v.GetDataLength(s, mode), mode, lvl)
 }
 
 func (v *QrVersioner) GetModeIndicator(mode QrMode) string {
 	return qrModeIndicators[mode]
 }
 
+// GetCountIndicator returns the number of characters of the input on the count indicator
+// length of the version. Inputs whose length overflows the indicator are rejected.
 func (v *QrVersioner) GetCountIndicator(s string, version QrVersion, mode QrMode) (string, error) {
+	length := v.GetCountIndicatorLength(version, mode)
+	if len(s) >= 1<<length {
+		return "", fmt.Errorf("%w: input length %d exceeds the count indicator of %d bits", qrerr.ErrCapacityExceeded, len(s), length)
+	}
+
 	sLenBin := strconv.FormatInt(int64(len(s)), 2)
-	return util.PadLeft(sLenBin, "0", qrCountIndLengths[mode]), nil
+	return util.PadLeft(sLenBin, "0", length), nil
+}
+
+// GetCountIndicatorLength returns the number of bits of the count indicator, which
+// grows with the version
+func (v *QrVersioner) GetCountIndicatorLength(version QrVersion, mode QrMode) int {
+	switch {
+	case version < 10:
+		return qrCountIndLengths[mode][0]
+	case version < 27:
+		return qrCountIndLengths[mode][1]
+	default:
+		return qrCountIndLengths[mode][2]
+	}
+}
+
+// GetDataLength returns the number of bits of the input characters encoded in the mode,
+// without the mode and count indicators
+func (v *QrVersioner) GetDataLength(s string, mode QrMode) int {
+	switch mode {
+	case QrNumericMode:
+		return len(s)/3*10 + []int{0, 4, 7}[len(s)%3]
+	case QrAlphanumericMode:
+		return len(s)/2*11 + len(s)%2*6
+	default:
+		return len(s) * util.QrCodewordSize
+	}
+}
+
+// GetByteModeVersion returns the smallest version able to hold the input in byte mode,
+// whatever its characters are. When eci is set, room is also left for the ECI header
+// which precedes the byte mode segment.
+func (v *QrVersioner) GetByteModeVersion(s string, lvl QrEcLevel, eci bool) (QrVersion, error) {
+	return v.GetByteLengthVersion(len(s), lvl, eci)
+}
+
+// GetByteLengthVersion returns the smallest version able to hold the given number of bytes
+// in byte mode, such as a binary payload
+func (v *QrVersioner) GetByteLengthVersion(length int, lvl QrEcLevel, eci bool) (QrVersion, error) {
+	if err := validateModeAndLevel(QrByteMode, lvl); err != nil {
+		return QrVersion(-1), err
+	}
+
+	bits := len(qrByteInd) + util.QrCodewordSize*length
+	if eci {
+		bits += len(qrEciInd) + qrEciDesignatorLength
+	}
+
+	for version := 1; version <= len(qrCapacities); version++ {
+		key := util.GetECMappingKey(version, string(lvl))
+		countIndLength := v.GetCountIndicatorLength(QrVersion(version), QrByteMode)
+		if bits+countIndLength <= util.QrCodewordSize*util.QrEcInfo[key].TotalDataCodewords {
+			return QrVersion(version), nil
+		}
+	}
+
+	return QrVersion(-1), v.dataTooLong(bits, QrByteMode, lvl)
+}
+
+// Reports an input of the given number of bits, count indicator excluded, not fitting the
+// largest version
+func (v *QrVersioner) dataTooLong(bits int, mode QrMode, lvl QrEcLevel) error {
+	version := QrVersion(len(qrCapacities))
+	key := util.GetECMappingKey(int(version), string(lvl))
+
+	return &qrerr.ErrDataTooLong{
+		Needed: bits + v.GetCountIndicatorLength(version, mode),
+		Max:    util.QrCodewordSize * util.QrEcInfo[key].TotalDataCodewords,
+		Level:  rune(lvl),
+	}
+}
+
+func validateModeAndLevel(mode QrMode, lvl QrEcLevel) error {
+	if _, ok := qrModeIndices[mode]; !ok {
+		return fmt.Errorf("%w %q", qrerr.ErrInvalidMode, mode)
+	}
+
+	if _, ok := qrCapacities[1][lvl]; !ok {
+		return fmt.Errorf("%w %q", qrerr.ErrInvalidLevel, lvl)
+	}
+
+	return nil
+}
+
+// GetEciHeader returns the ECI mode indicator followed by the designator of the
+// character set assignment. Assignments below 128 are designated on a single byte.
+func (v *QrVersioner) GetEciHeader(assignment int) string {
+	designator := strconv.FormatInt(int64(assignment), 2)
+	return qrEciInd + util.PadLeft(designator, "0", qrEciDesignatorLength)
 }
 
 const (
@@ -81,8 +201,15 @@ const (
 	qrNumericInd      string = "0001"
 	qrAlphanumericInd string = "0010"
 	qrByteInd         string = "0100"
+	qrEciInd          string = "0111"
 )
 
+// ECI assignment of the UTF-8 character set. Without an ECI header, readers
+// interpret byte mode data as ISO 8859-1.
+const QrEciUTF8 = 26
+
+const qrEciDesignatorLength = 8
+
 var qrModeRegexes = map[QrMode]string{
 	QrNumericMode:      "^\\d+$",
 	QrAlphanumericMode: "^[\\dA-Z $%*+\\-./:]+$",
@@ -101,10 +228,11 @@ var qrModeIndicators = map[QrMode]string{
 	QrByteMode:         qrByteInd,
 }
 
-var qrCountIndLengths = map[QrMode]int{
-	QrNumericMode:      10,
-	QrAlphanumericMode: 9,
-	QrByteMode:         8,
+// Count indicator lengths for the versions 1 to 9, 10 to 26 and 27 to 40
+var qrCountIndLengths = map[QrMode][]int{
+	QrNumericMode:      {10, 12, 14},
+	QrAlphanumericMode: {9, 11, 13},
+	QrByteMode:         {8, 16, 16},
 }
 
 var qrCapacities = map[QrVersion]map[QrEcLevel][]int{
@@ -138,4 +266,214 @@ var qrCapacities = map[QrVersion]map[QrEcLevel][]int{
 		QrEcQuartile: {144, 87, 60},
 		QrECHigh:     {106, 64, 44},
 	},
+	6: {
+		QrEcLow:      {322, 195, 134},
+		QrEcMedium:   {255, 154, 106},
+		QrEcQuartile: {178, 108, 74},
+		QrECHigh:     {139, 84, 58},
+	},
+	7: {
+		QrEcLow:      {370, 224, 154},
+		QrEcMedium:   {293, 178, 122},
+		QrEcQuartile: {207, 125, 86},
+		QrECHigh:     {154, 93, 64},
+	},
+	8: {
+		QrEcLow:      {461, 279, 192},
+		QrEcMedium:   {365, 221, 152},
+		QrEcQuartile: {259, 157, 108},
+		QrECHigh:     {202, 122, 84},
+	},
+	9: {
+		QrEcLow:      {552, 335, 230},
+		QrEcMedium:   {432, 262, 180},
+		QrEcQuartile: {312, 189, 130},
+		QrECHigh:     {235, 143, 98},
+	},
+	10: {
+		QrEcLow:      {652, 395, 271},
+		QrEcMedium:   {513, 311, 213},
+		QrEcQuartile: {364, 221, 151},
+		QrECHigh:     {288, 174, 119},
+	},
+	11: {
+		QrEcLow:      {772, 468, 321},
+		QrEcMedium:   {604, 366, 251},
+		QrEcQuartile: {427, 259, 177},
+		QrECHigh:     {331, 200, 137},
+	},
+	12: {
+		QrEcLow:      {883, 535, 367},
+		QrEcMedium:   {691, 419, 287},
+		QrEcQuartile: {489, 296, 203},
+		QrECHigh:     {374, 227, 155},
+	},
+	13: {
+		QrEcLow:      {1022, 619, 425},
+		QrEcMedium:   {796, 483, 331},
+		QrEcQuartile: {580, 352, 241},
+		QrECHigh:     {427, 259, 177},
+	},
+	14: {
+		QrEcLow:      {1101, 667, 458},
+		QrEcMedium:   {871, 528, 362},
+		QrEcQuartile: {621, 376, 258},
+		QrECHigh:     {468, 283, 194},
+	},
+	15: {
+		QrEcLow:      {1250, 758, 520},
+		QrEcMedium:   {991, 600, 412},
+		QrEcQuartile: {703, 426, 292},
+		QrECHigh:     {530, 321, 220},
+	},
+	16: {
+		QrEcLow:      {1408, 854, 586},
+		QrEcMedium:   {1082, 656, 450},
+		QrEcQuartile: {775, 470, 322},
+		QrECHigh:     {602, 365, 250},
+	},
+	17: {
+		QrEcLow:      {1548, 938, 644},
+		QrEcMedium:   {1212, 734, 504},
+		QrEcQuartile: {876, 531, 364},
+		QrECHigh:     {674, 408, 280},
+	},
+	18: {
+		QrEcLow:      {1725, 1046, 718},
+		QrEcMedium:   {1346, 816, 560},
+		QrEcQuartile: {948, 574, 394},
+		QrECHigh:     {746, 452, 310},
+	},
+	19: {
+		QrEcLow:      {1903, 1153, 792},
+		QrEcMedium:   {1500, 909, 624},
+		QrEcQuartile: {1063, 644, 442},
+		QrECHigh:     {813, 493, 338},
+	},
+	20: {
+		QrEcLow:      {2061, 1249, 858},
+		QrEcMedium:   {1600, 970, 666},
+		QrEcQuartile: {1159, 702, 482},
+		QrECHigh:     {919, 557, 382},
+	},
+	21: {
+		QrEcLow:      {2232, 1352, 929},
+		QrEcMedium:   {1708, 1035, 711},
+		QrEcQuartile: {1224, 742, 509},
+		QrECHigh:     {969, 587, 403},
+	},
+	22: {
+		QrEcLow:      {2409, 1460, 1003},
+		QrEcMedium:   {1872, 1134, 779},
+		QrEcQuartile: {1358, 823, 565},
+		QrECHigh:     {1056, 640, 439},
+	},
+	23: {
+		QrEcLow:      {2620, 1588, 1091},
+		QrEcMedium:   {2059, 1248, 857},
+		QrEcQuartile: {1468, 890, 611},
+		QrECHigh:     {1108, 672, 461},
+	},
+	24: {
+		QrEcLow:      {2812, 1704, 1171},
+		QrEcMedium:   {2188, 1326, 911},
+		QrEcQuartile: {1588, 963, 661},
+		QrECHigh:     {1228, 744, 511},
+	},
+	25: {
+		QrEcLow:      {3057, 1853, 1273},
+		QrEcMedium:   {2395, 1451, 997},
+		QrEcQuartile: {1718, 1041, 715},
+		QrECHigh:     {1286, 779, 535},
+	},
+	26: {
+		QrEcLow:      {3283, 1990, 1367},
+		QrEcMedium:   {2544, 1542, 1059},
+		QrEcQuartile: {1804, 1094, 751},
+		QrECHigh:     {1425, 864, 593},
+	},
+	27: {
+		QrEcLow:      {3517, 2132, 1465},
+		QrEcMedium:   {2701, 1637, 1125},
+		QrEcQuartile: {1933, 1172, 805},
+		QrECHigh:     {1501, 910, 625},
+	},
+	28: {
+		QrEcLow:      {3669, 2223, 1528},
+		QrEcMedium:   {2857, 1732, 1190},
+		QrEcQuartile: {2085, 1263, 868},
+		QrECHigh:     {1581, 958, 658},
+	},
+	29: {
+		QrEcLow:      {3909, 2369, 1628},
+		QrEcMedium:   {3035, 1839, 1264},
+		QrEcQuartile: {2181, 1322, 908},
+		QrECHigh:     {1677, 1016, 698},
+	},
+	30: {
+		QrEcLow:      {4158, 2520, 1732},
+		QrEcMedium:   {3289, 1994, 1370},
+		QrEcQuartile: {2358, 1429, 982},
+		QrECHigh:     {1782, 1080, 742},
+	},
+	31: {
+		QrEcLow:      {4417, 2677, 1840},
+		QrEcMedium:   {3486, 2113, 1452},
+		QrEcQuartile: {2473, 1499, 1030},
+		QrECHigh:     {1897, 1150, 790},
+	},
+	32: {
+		QrEcLow:      {4686, 2840, 1952},
+		QrEcMedium:   {3693, 2238, 1538},
+		QrEcQuartile: {2670, 1618, 1112},
+		QrECHigh:     {2022, 1226, 842},
+	},
+	33: {
+		QrEcLow:      {4965, 3009, 2068},
+		QrEcMedium:   {3909, 2369, 1628},
+		QrEcQuartile: {2805, 1700, 1168},
+		QrECHigh:     {2157, 1307, 898},
+	},
+	34: {
+		QrEcLow:      {5253, 3183, 2188},
+		QrEcMedium:   {4134, 2506, 1722},
+		QrEcQuartile: {2949, 1787, 1228},
+		QrECHigh:     {2301, 1394, 958},
+	},
+	35: {
+		QrEcLow:      {5529, 3351, 2303},
+		QrEcMedium:   {4343, 2632, 1809},
+		QrEcQuartile: {3081, 1867, 1283},
+		QrECHigh:     {2361, 1431, 983},
+	},
+	36: {
+		QrEcLow:      {5836, 3537, 2431},
+		QrEcMedium:   {4588, 2780, 1911},
+		QrEcQuartile: {3244, 1966, 1351},
+		QrECHigh:     {2524, 1530, 1051},
+	},
+	37: {
+		QrEcLow:      {6153, 3729, 2563},
+		QrEcMedium:   {4775, 2894, 1989},
+		QrEcQuartile: {3417, 2071, 1423},
+		QrECHigh:     {2625, 1591, 1093},
+	},
+	38: {
+		QrEcLow:      {6479, 3927, 2699},
+		QrEcMedium:   {5039, 3054, 2099},
+		QrEcQuartile: {3599, 2181, 1499},
+		QrECHigh:     {2735, 1658, 1139},
+	},
+	39: {
+		QrEcLow:      {6743, 4087, 2809},
+		QrEcMedium:   {5313, 3220, 2213},
+		QrEcQuartile: {3791, 2298, 1579},
+		QrECHigh:     {2927, 1774, 1219},
+	},
+	40: {
+		QrEcLow:      {7089, 4296, 2953},
+		QrEcMedium:   {5596, 3391, 2331},
+		QrEcQuartile: {3993, 2420, 1663},
+		QrECHigh:     {3057, 1852, 1273},
+	},
 }
@@ -1,6 +1,8 @@
 package versioner
 
 import (
+	"qr/qr-gen/qrerr"
+	"strings"
 	"testing"
 
 	"github.com/stretchr/testify/assert"
@@ -112,10 +114,49 @@ func TestComputeQrVersion(t *testing.T) {
 	input = "this is a very long text fragment for which we cannot compute a compatible qr version" +
 		"this is a very long text fragment for which we cannot compute a compatible qr version" +
 		"this is a very long text fragment for which we cannot compute a compatible qr version"
+	actual, _ = v.GetVersion(input, QrByteMode, QrEcLow)
+	assert.Equal(QrVersion(10), actual, "Input should be version 10")
+
+	input = strings.Repeat("a", 2954)
 	actual, err = v.GetVersion(input, QrByteMode, QrEcLow)
 	assert.Error(err)
 }
 
+func TestVersionerErrors(t *testing.T) {
+	assert := assert.New(t)
+	v := New()
+
+	_, err := v.GetMode("")
+	assert.ErrorIs(err, qrerr.ErrEmptyInput)
+
+	_, err = v.GetMode("caf\u00e9 \u2615")
+	var invalid *qrerr.ErrInvalidCharacter
+	if assert.ErrorAs(err, &invalid) {
+		assert.Equal(qrerr.ErrInvalidCharacter{Pos: 6, Rune: '\u2615', Mode: "byte"}, *invalid, "error details should match")
+	}
+	assert.ErrorIs(err, qrerr.ErrInvalidInput)
+
+	_, err = v.GetVersion(strings.Repeat("A", 4297), QrAlphanumericMode, QrEcLow)
+	var tooLong *qrerr.ErrDataTooLong
+	if assert.ErrorAs(err, &tooLong) {
+		assert.Equal(qrerr.ErrDataTooLong{Needed: 4 + 13 + 2148*11 + 6, Max: 23648, Level: 'L'}, *tooLong, "error details should match")
+	}
+
+	_, err = v.GetByteModeVersion(strings.Repeat("a", 2953), QrEcLow, true)
+	if assert.ErrorAs(err, &tooLong) {
+		assert.Equal(qrerr.ErrDataTooLong{Needed: 12 + 4 + 16 + 2953*8, Max: 23648, Level: 'L'}, *tooLong, "error details should match")
+	}
+
+	_, err = v.GetVersion("HELLO", QrAlphanumericMode, 'X')
+	assert.ErrorIs(err, qrerr.ErrInvalidLevel)
+
+	_, err = v.GetVersion("HELLO", "kanji", QrEcLow)
+	assert.ErrorIs(err, qrerr.ErrInvalidMode)
+
+	_, err = v.GetCountIndicator(strings.Repeat("1", 1024), 1, QrNumericMode)
+	assert.ErrorIs(err, qrerr.ErrCapacityExceeded)
+}
+
 func TestGetModeIndicator(t *testing.T) {
 	assert := assert.New(t)
 	v := New()
@@ -163,3 +204,73 @@ func TestGetCountIndicator(t *testing.T) {
 	actual, _ = v.GetCountIndicator(input, version, mode)
 	assert.Equal("00010010", actual, "Input should match binary representation")
 }
+
+func TestGetByteModeVersion(t *testing.T) {
+	assert := assert.New(t)
+	v := New()
+
+	tests := []struct {
+		length   int
+		eci      bool
+		expected QrVersion
+	}{
+		{17, false, 1},
+		{18, false, 2},
+		{16, true, 1},
+		{17, true, 2},
+		{106, false, 5},
+		{106, true, 6},
+		{2953, false, 40},
+		{2952, true, 40},
+		{2953, true, -1},
+	}
+
+	for _, test := range tests {
+		// Digits would be encoded in numeric mode by GetVersion, the byte mode capacity must apply nonetheless
+		actual, err := v.GetByteModeVersion(strings.Repeat("1", test.length), QrEcLow, test.eci)
+		assert.Equal(test.expected, actual, "Versions should match")
+		assert.Equal(test.expected == -1, err != nil, "Only too long inputs should fail")
+
+		actual, _ = v.GetByteLengthVersion(test.length, QrEcLow, test.eci)
+		assert.Equal(test.expected, actual, "Versions of the byte lengths should match")
+	}
+
+	_, err := v.GetByteLengthVersion(10, 'X', false)
+	assert.ErrorIs(err, qrerr.ErrInvalidLevel)
+}
+
+func TestGetEciHeader(t *testing.T) {
+	assert := assert.New(t)
+
+	assert.Equal("011100011010", New().GetEciHeader(QrEciUTF8), "Header should match the UTF-8 designator")
+}
+
+// The version picked from the capacity tables is the smallest one the computed capacity fits in
+func FuzzGetVersion(f *testing.F) {
+	f.Add("01234567", byte(1))
+	f.Add("HELLO WORLD", byte(2))
+	f.Add("https://www.qrcode.com/", byte(0))
+
+	f.Fuzz(func(t *testing.T, s string, lvl byte) {
+		v := New()
+		level := QrEcLevels[int(lvl)%len(QrEcLevels)]
+
+		mode, err := v.GetMode(s)
+		if err != nil {
+			return
+		}
+
+		capacity, err := v.GetCapacity(s)
+		if err != nil {
+			t.Fatalf("capacity of %q should be computed: %v", s, err)
+		}
+
+		version, err := v.GetVersion(s, mode, level)
+		if err != nil {
+			version = -1
+		}
+		if expected := capacity.MinVersion(level); version != expected {
+			t.Fatalf("version of %q at level %c should be %d, got %d", s, level, expected, version)
+		}
+	})
+}